@@ -4,7 +4,12 @@
 // Implementing a backend requires implementing both the BooleanQuery interface and the Compiler interface.
 package backend
 
-import "github.com/hscells/transmute/ir"
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/ir"
+	"sort"
+	"strings"
+)
 
 // BooleanQuery is an interface for handling the queries in a query language. The most important method is String(),
 // which will output an appropriate query suitable for a search engine.
@@ -20,3 +25,74 @@ type Compiler interface {
 	// is the reason both the backend and query interfaces must be implemented for this package.
 	Compile(ir ir.BooleanQuery) (BooleanQuery, error)
 }
+
+// applyLimits rewrites every limited query into an `and` query of the query and the keywords which satisfy each of its
+// limits. This is used by backends for search engines which do not have a dedicated way to limit a query.
+func applyLimits(q ir.BooleanQuery) ir.BooleanQuery {
+	if len(q.Children) > 0 {
+		children := make([]ir.BooleanQuery, len(q.Children))
+		for i, child := range q.Children {
+			children[i] = applyLimits(child)
+		}
+		q.Children = children
+	}
+
+	if len(q.Limits) == 0 {
+		return q
+	}
+
+	limits := q.Limits
+	q.Limits = nil
+	limited := ir.BooleanQuery{Operator: cqr.AND, Children: []ir.BooleanQuery{q}}
+	for _, limit := range limits {
+		limited.Children = append(limited.Children, ir.BooleanQuery{Operator: cqr.OR, Keywords: limit.Keywords()})
+	}
+	return limited
+}
+
+// keywordFrequency returns the minimum number of times a keyword must occur in its fields, or zero when it has no
+// minimum. The option may have been decoded from JSON, in which case it is a float64.
+func keywordFrequency(keyword ir.Keyword) int {
+	switch frequency := keyword.Options[ir.FrequencyOption].(type) {
+	case int:
+		return frequency
+	case float64:
+		return int(frequency)
+	}
+	return 0
+}
+
+// sharedFields returns the fields searched by every keyword in a query, or false if the keywords are searched in
+// different fields, or if any of them are subject headings. Backends for search engines which apply fields to an
+// entire group of keywords (e.g. `(memory NEAR/3 loss):ti,ab`) use this to avoid repeating the fields.
+func sharedFields(q ir.BooleanQuery) ([]string, bool) {
+	var shared []string
+	found := false
+	key := func(f []string) string {
+		sorted := make([]string, len(f))
+		copy(sorted, f)
+		sort.Strings(sorted)
+		return strings.Join(sorted, ",")
+	}
+	var visit func(q ir.BooleanQuery) bool
+	visit = func(q ir.BooleanQuery) bool {
+		for _, keyword := range q.Keywords {
+			if len(keyword.Fields) == 1 && subjectHeadingFields[keyword.Fields[0]] {
+				return false
+			}
+			if !found {
+				shared = keyword.Fields
+				found = true
+			} else if key(shared) != key(keyword.Fields) {
+				return false
+			}
+		}
+		for _, child := range q.Children {
+			if !visit(child) {
+				return false
+			}
+		}
+		return true
+	}
+	return shared, visit(q) && found
+}
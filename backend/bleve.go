@@ -0,0 +1,242 @@
+package backend
+
+import (
+	"encoding/json"
+	"github.com/blevesearch/bleve/search/query"
+	"github.com/hscells/cqr"
+	"github.com/hscells/meshexp"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"strings"
+	"time"
+)
+
+// BleveBackend compiles queries into Bleve query objects, which can be searched against a Bleve index directly, e.g.
+// `index.Search(bleve.NewSearchRequest(q))` where q is the Representation of the compiled query.
+type BleveBackend struct {
+	// Fields maps fields onto the fields of the index. Fields which are mapped onto no fields are searched in the
+	// default field of the index mapping, and fields which are not mapped are searched in a field with the same name.
+	Fields map[string][]string
+	tree   *meshexp.MeSHTree
+}
+
+// BleveQuery is the transmute representation of a Bleve query.
+type BleveQuery struct {
+	query query.Query
+}
+
+// Representation returns the query.Query of the Bleve query.
+func (q BleveQuery) Representation() (interface{}, error) {
+	return q.query, nil
+}
+
+// String returns the JSON encoding of the query, which can be read with query.ParseQuery.
+func (q BleveQuery) String() (string, error) {
+	b, err := json.Marshal(q.query)
+	return string(b), err
+}
+
+func (q BleveQuery) StringPretty() (string, error) {
+	b, err := json.MarshalIndent(q.query, "", "    ")
+	return string(b), err
+}
+
+// indexFields maps fields onto the fields of the index. The empty field is the default field.
+func (b BleveBackend) indexFields(keywordFields []string) []string {
+	var indexFields []string
+	seen := make(map[string]bool)
+	for _, field := range keywordFields {
+		f, ok := b.Fields[field]
+		if !ok {
+			f = []string{field}
+		}
+		if len(f) == 0 {
+			return []string{""}
+		}
+		for _, indexField := range f {
+			if !seen[indexField] {
+				seen[indexField] = true
+				indexFields = append(indexFields, indexField)
+			}
+		}
+	}
+	if len(indexFields) == 0 {
+		return []string{""}
+	}
+	return indexFields
+}
+
+// fielded creates a query for each of the fields, which are combined in a disjunction.
+func (b BleveBackend) fielded(keywordFields []string, newQuery func() query.FieldableQuery) query.Query {
+	var queries []query.Query
+	for _, field := range b.indexFields(keywordFields) {
+		q := newQuery()
+		if len(field) > 0 {
+			q.SetField(field)
+		}
+		queries = append(queries, q)
+	}
+	if len(queries) == 1 {
+		return queries[0]
+	}
+	return query.NewDisjunctionQuery(queries)
+}
+
+// bleveWildcard writes the truncation characters of a term as Bleve wildcards. Wildcard queries are not analysed, so
+// the term is lower cased like the terms of the index.
+func bleveWildcard(term string) string {
+	return strings.NewReplacer("$", "*", "#", "?").Replace(strings.ToLower(term))
+}
+
+// keyword compiles a keyword into a date range, match, match_phrase, prefix or wildcard query. An exploded subject
+// heading is searched in a disjunction with the headings beneath it.
+func (b BleveBackend) keyword(keyword ir.Keyword) query.Query {
+	if isExplodedHeading(keyword) {
+		headings := explodedHeadings(b.tree, keyword.QueryString)
+		heading := keyword
+		heading.Exploded = false
+		heading.Options = nil
+		queries := []query.Query{b.keyword(heading)}
+		for _, h := range headings[1:] {
+			queries = append(queries, b.keyword(ir.Keyword{QueryString: h, Fields: keyword.Fields}))
+		}
+		if len(queries) == 1 {
+			return boostBleve(queries[0], keyword.Options)
+		}
+		return boostBleve(query.NewDisjunctionQuery(queries), keyword.Options)
+	}
+
+	qs := strings.TrimSpace(keyword.QueryString)
+	var newQuery func() query.FieldableQuery
+	switch {
+	case keyword.Range != nil:
+		start, end := keyword.Range.Start, keyword.Range.End
+		if !end.IsZero() {
+			// The end of a range is excluded, and the range includes the entire last day.
+			end = end.Add(24 * time.Hour)
+		}
+		newQuery = func() query.FieldableQuery { return query.NewDateRangeQuery(start, end) }
+	case strings.HasPrefix(qs, `"`) || strings.ContainsAny(qs, " \t"):
+		if keyword.Truncated {
+			log.Printf("WARNING: phrases cannot be truncated, searching for %v without truncation\n", qs)
+		}
+		newQuery = func() query.FieldableQuery { return query.NewMatchPhraseQuery(strings.Trim(qs, `"`)) }
+	case keyword.Truncated:
+		wildcard := bleveWildcard(qs)
+		prefix := strings.TrimSuffix(wildcard, "*")
+		if !strings.ContainsAny(prefix, "*?") {
+			newQuery = func() query.FieldableQuery { return query.NewPrefixQuery(prefix) }
+		} else {
+			newQuery = func() query.FieldableQuery { return query.NewWildcardQuery(wildcard) }
+		}
+	default:
+		newQuery = func() query.FieldableQuery { return query.NewMatchQuery(qs) }
+	}
+	return boostBleve(b.fielded(keyword.Fields, newQuery), keyword.Options)
+}
+
+// boostBleve sets the boost of a query from the options of a keyword or query.
+func boostBleve(q query.Query, options map[string]interface{}) query.Query {
+	boostable, ok := q.(query.BoostableQuery)
+	if !ok {
+		return q
+	}
+	switch boost := options[ir.BoostOption].(type) {
+	case float64:
+		boostable.SetBoost(boost)
+	case int:
+		boostable.SetBoost(float64(boost))
+	}
+	return q
+}
+
+// phrase compiles an adjacency query into a match_phrase query, or reports that the query cannot be written as a
+// phrase. Bleve phrases have no slop, so only queries of adjacent terms searched in the same fields can be.
+func (b BleveBackend) phrase(q ir.BooleanQuery) (query.Query, bool) {
+	if len(q.Children) > 0 || len(q.Keywords) == 0 {
+		return nil, false
+	}
+	switch strings.TrimPrefix(strings.ToLower(q.Operator), "adj") {
+	case "", "0", "1":
+	default:
+		return nil, false
+	}
+	var terms []string
+	for _, keyword := range q.Keywords {
+		if keyword.Truncated || keyword.Range != nil || strings.Join(keyword.Fields, ",") != strings.Join(q.Keywords[0].Fields, ",") {
+			return nil, false
+		}
+		terms = append(terms, strings.Trim(keyword.QueryString, `"`))
+	}
+	phrase := strings.Join(terms, " ")
+	return b.fielded(q.Keywords[0].Fields, func() query.FieldableQuery { return query.NewMatchPhraseQuery(phrase) }), true
+}
+
+// compile compiles a query into conjunction, disjunction and boolean queries. The queries excluded by a not query are
+// the must_not clauses of a boolean query.
+func (b BleveBackend) compile(q ir.BooleanQuery) query.Query {
+	if q.Keywords == nil && len(q.Operator) == 0 {
+		var queries []query.Query
+		for _, child := range q.Children {
+			queries = append(queries, b.compile(child))
+		}
+		if len(queries) == 1 {
+			return queries[0]
+		}
+		return query.NewConjunctionQuery(queries)
+	}
+
+	operator := strings.ToLower(q.Operator)
+	if strings.HasPrefix(operator, "adj") {
+		if phrase, ok := b.phrase(q); ok {
+			return boostBleve(phrase, q.Options)
+		}
+		log.Printf("WARNING: could not write the %v query as a phrase, searching for all of its terms instead\n", q.Operator)
+		operator = cqr.AND
+	}
+
+	var queries []query.Query
+	for _, keyword := range q.Keywords {
+		queries = append(queries, b.keyword(keyword))
+	}
+	for _, child := range q.Children {
+		queries = append(queries, b.compile(child))
+	}
+	if len(queries) == 1 && operator != cqr.NOT {
+		return boostBleve(queries[0], q.Options)
+	}
+
+	var compiled query.Query
+	switch operator {
+	case cqr.AND:
+		compiled = query.NewConjunctionQuery(queries)
+	case cqr.OR:
+		compiled = query.NewDisjunctionQuery(queries)
+	case cqr.NOT:
+		compiled = query.NewBooleanQuery(queries[:1], nil, queries[1:])
+	default:
+		log.Printf("WARNING: unsupported operator %v, using AND instead\n", q.Operator)
+		compiled = query.NewConjunctionQuery(queries)
+	}
+	return boostBleve(compiled, q.Options)
+}
+
+// Compile transforms an immediate representation of a query into a Bleve query.
+func (b BleveBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
+	return BleveQuery{query: b.compile(applyLimits(q))}, nil
+}
+
+// NewBleveBackend returns a new backend for compiling Bleve queries. Fields are searched in index fields with the
+// same name, except that all fields are searched in the default field. Exploded subject headings are expanded with the
+// default MeSH tree.
+func NewBleveBackend() BleveBackend {
+	tree, err := meshexp.Default()
+	if err != nil {
+		panic(err)
+	}
+	return BleveBackend{
+		Fields: map[string][]string{fields.AllFields: {}},
+		tree:   tree,
+	}
+}
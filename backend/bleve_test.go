@@ -0,0 +1,108 @@
+package backend
+
+import (
+	"github.com/blevesearch/bleve/search/query"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBleveBackend_Compile(t *testing.T) {
+	match := func(term string) query.Query {
+		q := query.NewMatchQuery(term)
+		q.SetField(fields.Title)
+		return q
+	}
+	heading := func(term string) query.Query {
+		var q query.FieldableQuery = query.NewMatchQuery(term)
+		if strings.Contains(term, " ") {
+			q = query.NewMatchPhraseQuery(term)
+		}
+		q.SetField(fields.MeshHeadings)
+		return q
+	}
+	keyword := func(term string) ir.Keyword {
+		return ir.Keyword{QueryString: term, Fields: []string{fields.Title}}
+	}
+	start, end := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2010, 12, 31, 0, 0, 0, 0, time.UTC)
+	dateRange := query.NewDateRangeQuery(start, end.Add(24*time.Hour))
+	dateRange.SetField(fields.PublicationDate)
+	phrase := query.NewMatchPhraseQuery("memory loss")
+	phrase.SetField(fields.Title)
+
+	tests := []struct {
+		name     string
+		query    ir.BooleanQuery
+		expected query.Query
+	}{
+		{
+			name: "not",
+			query: ir.BooleanQuery{
+				Operator: cqr.NOT,
+				Keywords: []ir.Keyword{keyword("dementia")},
+				Children: []ir.BooleanQuery{{Operator: cqr.OR, Keywords: []ir.Keyword{keyword("mice"), keyword("rats")}}},
+			},
+			expected: query.NewBooleanQuery(
+				[]query.Query{match("dementia")},
+				nil,
+				[]query.Query{query.NewDisjunctionQuery([]query.Query{match("mice"), match("rats")})},
+			),
+		},
+		{
+			name:     "adj1",
+			query:    ir.BooleanQuery{Operator: "adj1", Keywords: []ir.Keyword{keyword("memory"), keyword("loss")}},
+			expected: phrase,
+		},
+		{
+			// Bleve phrases have no slop, so the terms of adjN are only required.
+			name:     "adj3",
+			query:    ir.BooleanQuery{Operator: "adj3", Keywords: []ir.Keyword{keyword("memory"), keyword("loss")}},
+			expected: query.NewConjunctionQuery([]query.Query{match("memory"), match("loss")}),
+		},
+		{
+			// There are no headings beneath a leaf of the tree.
+			name:     "exploded leaf heading",
+			query:    ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{{QueryString: "Amnesia, Retrograde", Fields: []string{fields.MeshHeadings}, Exploded: true}}},
+			expected: heading("Amnesia, Retrograde"),
+		},
+		{
+			name:     "exploded heading",
+			query:    ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{{QueryString: "Amnesia", Fields: []string{fields.MeshHeadings}, Exploded: true}}},
+			expected: query.NewDisjunctionQuery([]query.Query{heading("Amnesia"), heading("Alcoholic Korsakoff Syndrome"), heading("Amnesia, Anterograde"), heading("Amnesia, Retrograde"), heading("Amnesia, Transient Global")}),
+		},
+		{
+			// The PubMed parser marks free text keywords as exploded, which are not subject headings.
+			name:     "exploded free text",
+			query:    ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{{QueryString: "dementia", Fields: []string{fields.Title}, Exploded: true}}},
+			expected: match("dementia"),
+		},
+		{
+			name: "date range",
+			query: ir.BooleanQuery{
+				Operator: cqr.AND,
+				Keywords: []ir.Keyword{
+					keyword("dementia"),
+					{Fields: []string{fields.PublicationDate}, Range: &ir.DateRange{Start: start, End: end}},
+				},
+			},
+			expected: query.NewConjunctionQuery([]query.Query{match("dementia"), dateRange}),
+		},
+	}
+	for _, test := range tests {
+		q, err := NewBleveBackend().Compile(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := q.Representation()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%v: expected %#v, got %#v", test.name, test.expected, got)
+		}
+	}
+}
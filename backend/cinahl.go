@@ -0,0 +1,265 @@
+package backend
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"strings"
+)
+
+// CINAHLFieldCodes maps fields onto the EBSCOhost CINAHL field codes which search them. Subject heading fields are
+// mapped onto the code used to search their headings, e.g. `(MH "Dementia+")`.
+var CINAHLFieldCodes = map[string][]string{
+	fields.Title:                   {"TI"},
+	fields.Abstract:                {"AB"},
+	fields.TitleAbstract:           {"TI", "AB"},
+	fields.TextWord:                {"TI", "AB"},
+	fields.AllFields:               {"TX"},
+	fields.Keywords:                {"KW"},
+	fields.CINAHLHeadings:          {"MH"},
+	fields.MajorFocusCINAHLHeading: {"MM"},
+	fields.MeshHeadings:            {"MH"},
+	fields.MajorFocusMeshHeading:   {"MM"},
+	fields.SubjectHeadings:         {"MH"},
+	fields.Authors:                 {"AU"},
+	fields.Author:                  {"AU"},
+	fields.AuthorFull:              {"AU"},
+	fields.AuthorLast:              {"AU"},
+	fields.Affiliation:             {"AF"},
+	fields.Journal:                 {"SO"},
+	fields.Language:                {"LA"},
+	fields.PublicationType:         {"PT"},
+	fields.PublicationDate:         {"DT"},
+	fields.ISBN:                    {"IB"},
+	fields.PMID:                    {"PM"},
+}
+
+// CINAHLBackend compiles queries into the S-numbered search lines of EBSCOhost CINAHL, e.g.
+//
+//	S1 (MH "Dementia+")
+//	S2 TI (memory N3 loss) OR AB (memory N3 loss)
+//	S3 S1 OR S2
+type CINAHLBackend struct {
+	// FieldCodes maps fields onto the field codes which search them.
+	FieldCodes map[string][]string
+}
+
+// CINAHLQuery is the transmute representation of a CINAHL search strategy.
+type CINAHLQuery struct {
+	repr string
+}
+
+func (q CINAHLQuery) Representation() (interface{}, error) {
+	return q.repr, nil
+}
+
+func (q CINAHLQuery) String() (string, error) {
+	return q.repr, nil
+}
+
+func (q CINAHLQuery) StringPretty() (string, error) {
+	return q.repr, nil
+}
+
+// codes maps fields onto field codes. Fields which cannot be mapped are searched in all text instead.
+func (b CINAHLBackend) codes(keywordFields []string) []string {
+	var codes []string
+	seen := make(map[string]bool)
+	for _, field := range keywordFields {
+		c, ok := b.FieldCodes[field]
+		if !ok {
+			log.Printf("WARNING: could not map the field %v, searching all text instead\n", field)
+			c = []string{"TX"}
+		}
+		for _, code := range c {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+	if len(codes) == 0 {
+		return []string{"TX"}
+	}
+	return codes
+}
+
+// heading writes a subject heading keyword, e.g. `(MH "Dementia+/DT")`, or reports that the keyword is not a subject
+// heading.
+func (b CINAHLBackend) heading(keyword ir.Keyword) (string, bool) {
+	if len(keyword.Fields) != 1 || !subjectHeadingFields[keyword.Fields[0]] {
+		return "", false
+	}
+	code := "MH"
+	if c, ok := b.FieldCodes[keyword.Fields[0]]; ok && len(c) > 0 {
+		code = c[0]
+	} else {
+		log.Printf("WARNING: could not map the field %v, searching the headings of %v instead\n", keyword.Fields[0], code)
+	}
+	heading := strings.Trim(keyword.QueryString, `"`)
+	if keyword.Exploded {
+		heading += "+"
+	}
+	for _, subheading := range keywordSubheadings(keyword) {
+		heading += "/" + strings.ToUpper(subheading)
+	}
+	return fmt.Sprintf(`(%s "%s")`, code, heading), true
+}
+
+// fielded writes an expression (or a term) in each of the field codes, e.g. `TI dementia OR AB dementia`. When group is
+// set, an expression searched in several field codes is parenthesised.
+func (b CINAHLBackend) fielded(codes []string, expression string, group bool) string {
+	terms := make([]string, len(codes))
+	for i, code := range codes {
+		terms[i] = fmt.Sprintf("%s %s", code, expression)
+	}
+	if len(terms) > 1 && group {
+		return fmt.Sprintf("(%s)", strings.Join(terms, " OR "))
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// cinahlTerm formats the query string of a keyword, quoting phrases.
+func cinahlTerm(keyword ir.Keyword) string {
+	if keyword.Range != nil {
+		return compileCINAHLDateRange(*keyword.Range)
+	}
+	qs := keyword.QueryString
+	if strings.ContainsAny(qs, " \t") && !strings.HasPrefix(qs, `"`) {
+		qs = fmt.Sprintf(`"%s"`, qs)
+	}
+	return qs
+}
+
+// compileCINAHLDateRange formats a date range as a range of publication dates, e.g. `20000101-20101231`. Ranges which
+// are open are closed with the years 1000 and 3000.
+func compileCINAHLDateRange(r ir.DateRange) string {
+	start, end := "10000101", "30001231"
+	if !r.Start.IsZero() {
+		start = r.Start.Format("20060102")
+	}
+	if !r.End.IsZero() {
+		end = r.End.Format("20060102")
+	}
+	return fmt.Sprintf("%s-%s", start, end)
+}
+
+// cinahlOperator formats the operator of a query. Adjacency is written as Nn, or Wn when the keywords must appear in
+// order.
+func cinahlOperator(q ir.BooleanQuery) string {
+	operator := strings.ToLower(q.Operator)
+	if strings.HasPrefix(operator, "adj") {
+		distance := operator[3:]
+		if len(distance) == 0 {
+			return "W0"
+		}
+		if ordered, ok := q.Options[ir.OrderedOption].(bool); ok && ordered {
+			return "W" + distance
+		}
+		return "N" + distance
+	}
+	switch operator {
+	case cqr.AND, cqr.OR, cqr.NOT:
+		return strings.ToUpper(operator)
+	}
+	log.Printf("WARNING: unsupported operator %v, using AND instead\n", q.Operator)
+	return "AND"
+}
+
+// expression compiles a query into an expression which is searched on a single line. When inField is set, the
+// keywords are written without their fields, since the entire expression is already inside a field code.
+func (b CINAHLBackend) expression(q ir.BooleanQuery, inField bool) string {
+	// Expressions searching the same fields are written inside the field codes, e.g. `TI (memory N3 loss)`.
+	if !inField {
+		if f, ok := sharedFields(q); ok && len(q.Keywords)+len(q.Children) > 1 {
+			return b.fielded(b.codes(f), fmt.Sprintf("(%s)", b.expression(q, true)), true)
+		}
+	}
+
+	var terms []string
+	for _, keyword := range q.Keywords {
+		if heading, ok := b.heading(keyword); ok {
+			terms = append(terms, heading)
+		} else if inField {
+			terms = append(terms, cinahlTerm(keyword))
+		} else {
+			terms = append(terms, b.fielded(b.codes(keyword.Fields), cinahlTerm(keyword), true))
+		}
+	}
+	for _, child := range q.Children {
+		terms = append(terms, fmt.Sprintf("(%s)", b.expression(child, inField)))
+	}
+	return strings.Join(terms, fmt.Sprintf(" %s ", cinahlOperator(q)))
+}
+
+// line compiles a single keyword into the contents of a line.
+func (b CINAHLBackend) line(keyword ir.Keyword) string {
+	if heading, ok := b.heading(keyword); ok {
+		return heading
+	}
+	return b.fielded(b.codes(keyword.Fields), cinahlTerm(keyword), false)
+}
+
+// compile writes the lines of a query, starting at the line level. Like compileOvid, each keyword is searched on
+// a line of its own, and the lines are combined by referring to them, e.g. `S3 S1 OR S2`. Lines cannot be combined
+// with proximity operators, so a proximity query is searched on a single line.
+func (b CINAHLBackend) compile(q ir.BooleanQuery, level int) (int, string) {
+	repr := ""
+	if q.Keywords == nil && len(q.Operator) == 0 {
+		for _, child := range q.Children {
+			var comp string
+			level, comp = b.compile(child, level)
+			repr += comp
+		}
+		return level, repr
+	}
+
+	if strings.HasPrefix(strings.ToLower(q.Operator), "adj") {
+		var expression string
+		if f, ok := sharedFields(q); ok {
+			// A line does not need to be grouped, e.g. `TI (memory N3 loss) OR AB (memory N3 loss)`.
+			expression = b.fielded(b.codes(f), fmt.Sprintf("(%s)", b.expression(q, true)), false)
+		} else {
+			expression = b.expression(q, false)
+		}
+		return level + 1, fmt.Sprintf("S%d %s\n", level, expression)
+	}
+
+	// The keywords are the first operands of the query, so they are written first (see ir.BooleanQuery).
+	var op []int
+	for _, keyword := range q.Keywords {
+		repr += fmt.Sprintf("S%d %s\n", level, b.line(keyword))
+		op = append(op, level)
+		level++
+	}
+	for _, child := range q.Children {
+		l, comp := b.compile(child, level)
+		repr += comp
+		level = l
+		op = append(op, l-1)
+	}
+
+	if len(op) > 1 {
+		refs := make([]string, len(op))
+		for i, o := range op {
+			refs[i] = fmt.Sprintf("S%d", o)
+		}
+		repr += fmt.Sprintf("S%d %s\n", level, strings.Join(refs, fmt.Sprintf(" %s ", cinahlOperator(q))))
+		level++
+	}
+	return level, repr
+}
+
+// Compile transforms an immediate representation of a query into a CINAHL search strategy.
+func (b CINAHLBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
+	_, repr := b.compile(applyLimits(q), 1)
+	return CINAHLQuery{repr: repr}, nil
+}
+
+// NewCINAHLBackend returns a new backend for compiling CINAHL search strategies with the default field codes. Teams
+// whose subscription uses different field codes can create a CINAHLBackend with their own.
+func NewCINAHLBackend() CINAHLBackend {
+	return CINAHLBackend{FieldCodes: CINAHLFieldCodes}
+}
@@ -0,0 +1,35 @@
+package backend
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"testing"
+)
+
+func TestCINAHLBackend_Not(t *testing.T) {
+	q, err := NewCINAHLBackend().Compile(ir.BooleanQuery{
+		Operator: cqr.NOT,
+		Keywords: []ir.Keyword{{QueryString: "Dementia", Fields: []string{fields.CINAHLHeadings}, Exploded: true}},
+		Children: []ir.BooleanQuery{
+			{
+				Operator: cqr.OR,
+				Keywords: []ir.Keyword{
+					{QueryString: "mice", Fields: []string{fields.Title}},
+					{QueryString: "rats", Fields: []string{fields.Title}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := q.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "S1 (MH \"Dementia+\")\nS2 TI mice\nS3 TI rats\nS4 S2 OR S3\nS5 S1 NOT S4\n"
+	if got != expected {
+		t.Errorf("expected\n%v\ngot\n%v", expected, got)
+	}
+}
@@ -0,0 +1,247 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"strings"
+)
+
+// HeadingPolicy is how a backend for a citation database, which does not index a controlled vocabulary, handles the
+// subject heading keywords of a query.
+type HeadingPolicy int
+
+const (
+	// HeadingsAsPhrases searches for subject headings as phrases in the title, abstract and keywords.
+	HeadingsAsPhrases HeadingPolicy = iota
+	// OmitHeadings removes subject headings from the query, logging a warning for each.
+	OmitHeadings
+	// RejectHeadings fails to compile queries which contain subject headings.
+	RejectHeadings
+)
+
+// ParseHeadingPolicy parses the name of a heading policy, which is one of `phrase`, `omit` or `fail`.
+func ParseHeadingPolicy(name string) (HeadingPolicy, error) {
+	switch strings.ToLower(name) {
+	case "phrase", "":
+		return HeadingsAsPhrases, nil
+	case "omit":
+		return OmitHeadings, nil
+	case "fail":
+		return RejectHeadings, nil
+	}
+	return HeadingsAsPhrases, errors.New(fmt.Sprintf("unknown heading policy `%v`, expected one of phrase, omit or fail", name))
+}
+
+// applyHeadingPolicy rewrites the subject heading keywords of a query according to a policy. Queries which are left
+// empty by omitting headings are removed.
+func applyHeadingPolicy(q ir.BooleanQuery, policy HeadingPolicy, database string) (ir.BooleanQuery, error) {
+	var keywords []ir.Keyword
+	for _, keyword := range q.Keywords {
+		if len(keyword.Fields) != 1 || !subjectHeadingFields[keyword.Fields[0]] {
+			keywords = append(keywords, keyword)
+			continue
+		}
+		heading := strings.Trim(keyword.QueryString, `"`)
+		switch policy {
+		case RejectHeadings:
+			return q, errors.New(fmt.Sprintf("%v does not have subject headings, but the query contains the heading `%v`", database, heading))
+		case OmitHeadings:
+			log.Printf("WARNING: %v does not have subject headings, omitting the heading %v\n", database, heading)
+		default:
+			phrase := ir.Keyword{
+				QueryString: heading,
+				Fields:      []string{fields.TitleAbstract, fields.Keywords},
+			}
+			if strings.ContainsAny(heading, " \t") {
+				phrase.QueryString = fmt.Sprintf(`"%s"`, heading)
+				phrase.Options = map[string]interface{}{ir.PhraseOption: ir.LoosePhrase}
+			}
+			keywords = append(keywords, phrase)
+		}
+	}
+	q.Keywords = keywords
+
+	var children []ir.BooleanQuery
+	for _, child := range q.Children {
+		c, err := applyHeadingPolicy(child, policy, database)
+		if err != nil {
+			return q, err
+		}
+		if len(c.Keywords) > 0 || len(c.Children) > 0 {
+			children = append(children, c)
+		}
+	}
+	q.Children = children
+	return q, nil
+}
+
+// citationTag is a field tag which searches several other tags at once, e.g. `TS` searches `TI`, `AB` and `AK`.
+type citationTag struct {
+	tag   string
+	parts []string
+}
+
+// citationDialect describes how a citation database writes queries. Backends which share the structure of
+// `TS=(memory NEAR/3 loss) AND PY=(2000-2010)` are written by filling in a dialect for compileCitation.
+type citationDialect struct {
+	// tags maps fields onto the tags which search them.
+	tags map[string][]string
+	// combined tags replace their parts when all of them are searched, in order.
+	combined []citationTag
+	// text are the tags of the text of an article. Keywords which search text and other fields (e.g. `.mp.`) are only
+	// searched in the text.
+	text map[string]bool
+	// fallback is the tag used for keywords whose fields cannot be mapped.
+	fallback string
+	// all is the tag which searches all fields, which replaces any other tags.
+	all string
+	// field writes an expression in a tag, e.g. `TS=(dementia)`.
+	field func(tag, expression string) string
+	// term formats the query string of a keyword.
+	term func(keyword ir.Keyword) string
+	// dateRange writes a date range, e.g. `PY=(2000-2010)`.
+	dateRange func(r ir.DateRange) string
+	// operator formats the operator of a query.
+	operator func(q ir.BooleanQuery) string
+}
+
+// fieldTags maps fields onto the tags of a dialect, combining tags where possible.
+func (d citationDialect) fieldTags(keywordFields []string) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	text := false
+	for _, field := range keywordFields {
+		for _, tag := range d.tags[field] {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+				text = text || d.text[tag]
+			}
+		}
+	}
+	if len(tags) == 0 {
+		log.Printf("WARNING: could not map the fields %v, searching %v instead\n", keywordFields, d.fallback)
+		return []string{d.fallback}
+	}
+	if seen[d.all] {
+		return []string{d.all}
+	}
+	if text {
+		var textTags []string
+		for _, tag := range tags {
+			if d.text[tag] {
+				textTags = append(textTags, tag)
+			}
+		}
+		tags = textTags
+	}
+
+	for _, c := range d.combined {
+		all := true
+		for _, part := range c.parts {
+			all = all && seen[part]
+		}
+		if !all {
+			continue
+		}
+		var combined []string
+		replaced := false
+		for _, tag := range tags {
+			isPart := false
+			for _, part := range c.parts {
+				isPart = isPart || tag == part
+			}
+			if !isPart {
+				combined = append(combined, tag)
+			} else if !replaced {
+				combined = append(combined, c.tag)
+				replaced = true
+			}
+		}
+		tags = combined
+		seen[c.tag] = true
+	}
+	return tags
+}
+
+// fielded writes an expression in each of the tags, e.g. `(TI=(dementia) OR AB=(dementia))`.
+func (d citationDialect) fielded(tags []string, expression string) string {
+	terms := make([]string, len(tags))
+	for i, tag := range tags {
+		terms[i] = d.field(tag, expression)
+	}
+	if len(terms) > 1 {
+		return fmt.Sprintf("(%s)", strings.Join(terms, " OR "))
+	}
+	return terms[0]
+}
+
+// compileCitation compiles a query in a dialect. When fielded is set, the keywords are written without their fields,
+// since the entire query is already inside a tag.
+func compileCitation(q ir.BooleanQuery, d citationDialect, fielded bool) string {
+	if q.Keywords == nil && len(q.Operator) == 0 {
+		var repr string
+		for _, child := range q.Children {
+			repr += compileCitation(child, d, fielded)
+		}
+		return repr
+	}
+
+	// Queries searching the same fields are written inside a single tag, e.g. `TS=(memory NEAR/3 loss)`.
+	if !fielded {
+		if f, ok := sharedFields(q); ok && len(q.Keywords)+len(q.Children) > 1 && !hasDateRange(q) {
+			return d.fielded(d.fieldTags(f), compileCitation(q, d, true))
+		}
+	}
+
+	var terms []string
+	for _, keyword := range q.Keywords {
+		if keyword.Range != nil {
+			terms = append(terms, d.dateRange(*keyword.Range))
+		} else if fielded {
+			terms = append(terms, d.term(keyword))
+		} else {
+			terms = append(terms, d.fielded(d.fieldTags(keyword.Fields), d.term(keyword)))
+		}
+	}
+	for _, child := range q.Children {
+		if len(child.Keywords) == 1 && len(child.Children) == 0 {
+			terms = append(terms, compileCitation(child, d, fielded))
+			continue
+		}
+		// A child written inside its own tags does not need to be grouped.
+		if _, ok := sharedFields(child); ok && !fielded && !hasDateRange(child) {
+			terms = append(terms, compileCitation(child, d, false))
+			continue
+		}
+		terms = append(terms, fmt.Sprintf("(%s)", compileCitation(child, d, fielded)))
+	}
+	return strings.Join(terms, fmt.Sprintf(" %s ", d.operator(q)))
+}
+
+// hasDateRange reports whether a query contains a date range, which is written with its own tag.
+func hasDateRange(q ir.BooleanQuery) bool {
+	for _, keyword := range q.Keywords {
+		if keyword.Range != nil {
+			return true
+		}
+	}
+	for _, child := range q.Children {
+		if hasDateRange(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// citationTerm formats the query string of a keyword, quoting phrases.
+func citationTerm(keyword ir.Keyword) string {
+	qs := keyword.QueryString
+	if strings.ContainsAny(qs, " \t") && !strings.HasPrefix(qs, `"`) {
+		qs = fmt.Sprintf(`"%s"`, qs)
+	}
+	return qs
+}
@@ -0,0 +1,51 @@
+package backend
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"testing"
+)
+
+// citationQuery is `exp Memory Disorders/ or dementia.ti.`.
+var citationQuery = ir.BooleanQuery{
+	Operator: cqr.OR,
+	Keywords: []ir.Keyword{
+		{QueryString: "Memory Disorders", Fields: []string{fields.MeshHeadings}, Exploded: true},
+		{QueryString: "dementia", Fields: []string{fields.Title}},
+	},
+}
+
+func TestCitationBackends_HeadingPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		compiler Compiler
+		expected string
+	}{
+		{"wos phrase", NewWebOfScienceBackend(HeadingsAsPhrases), `TS=("Memory Disorders") OR TI=(dementia)`},
+		{"wos omit", NewWebOfScienceBackend(OmitHeadings), "TI=(dementia)"},
+		{"scopus phrase", NewScopusBackend(HeadingsAsPhrases), `TITLE-ABS-KEY("Memory Disorders") OR TITLE(dementia)`},
+		{"scopus omit", NewScopusBackend(OmitHeadings), "TITLE(dementia)"},
+	}
+	for _, test := range tests {
+		q, err := test.compiler.Compile(citationQuery)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := q.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expected {
+			t.Errorf("%v: expected\n%v\ngot\n%v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestCitationBackends_RejectHeadings(t *testing.T) {
+	for _, compiler := range []Compiler{NewWebOfScienceBackend(RejectHeadings), NewScopusBackend(RejectHeadings)} {
+		if _, err := compiler.Compile(citationQuery); err == nil {
+			t.Errorf("expected %T to fail to compile a query with subject headings", compiler)
+		}
+	}
+}
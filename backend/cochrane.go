@@ -0,0 +1,299 @@
+package backend
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"strings"
+)
+
+// CochraneLibBackend compiles queries into the numbered search lines of the Cochrane Library (CENTRAL), e.g.
+//
+//	#1 MeSH descriptor: [Dementia] explode all trees
+//	#2 (memory NEAR/3 loss):ti,ab,kw
+//	#3 #1 OR #2
+type CochraneLibBackend struct{}
+
+// CochraneLibQuery is the transmute representation of a Cochrane Library search strategy.
+type CochraneLibQuery struct {
+	repr string
+}
+
+// cochraneFieldCodes maps fields onto the Cochrane Library field codes which search them. Subject headings searched as
+// text are searched in the keyword field, which contains the MeSH and Emtree headings of records.
+var cochraneFieldCodes = map[string][]string{
+	fields.Title:                {"ti"},
+	fields.Abstract:             {"ab"},
+	fields.TitleAbstract:        {"ti", "ab"},
+	fields.TextWord:             {"ti", "ab", "kw"},
+	fields.AllFields:            {"ti", "ab", "kw"},
+	fields.Keywords:             {"kw"},
+	fields.MeshHeadings:         {"kw"},
+	fields.EmtreeHeadings:       {"kw"},
+	fields.FloatingMeshHeadings: {"kw"},
+	fields.Authors:              {"au"},
+	fields.Author:               {"au"},
+	fields.AuthorFull:           {"au"},
+	fields.AuthorLast:           {"au"},
+	fields.Journal:              {"so"},
+	fields.PublicationType:      {"pt"},
+}
+
+// cochraneCodeOrder is the order field codes are written in, e.g. `:ti,ab,kw`.
+var cochraneCodeOrder = []string{"ti", "ab", "kw", "au", "so", "pt"}
+
+func (q CochraneLibQuery) Representation() (interface{}, error) {
+	return q.repr, nil
+}
+
+func (q CochraneLibQuery) String() (string, error) {
+	return q.repr, nil
+}
+
+func (q CochraneLibQuery) StringPretty() (string, error) {
+	return q.repr, nil
+}
+
+// cochraneCodes maps fields onto a Cochrane Library field suffix, e.g. `:ti,ab,kw`. Fields which cannot be mapped are
+// ignored, and keywords without any mapped fields are searched in the title, abstract and keywords.
+func cochraneCodes(keywordFields []string) string {
+	seen := make(map[string]bool)
+	for _, field := range keywordFields {
+		for _, code := range cochraneFieldCodes[field] {
+			seen[code] = true
+		}
+	}
+	var codes []string
+	for _, code := range cochraneCodeOrder {
+		if seen[code] {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		log.Printf("WARNING: could not map the fields %v, searching the title, abstract and keywords instead\n", keywordFields)
+		return ":ti,ab,kw"
+	}
+	return ":" + strings.Join(codes, ",")
+}
+
+// isCochraneHeading reports whether a keyword is a subject heading, which is searched on a line of its own.
+func isCochraneHeading(keyword ir.Keyword) bool {
+	return len(keyword.Fields) == 1 && subjectHeadingFields[keyword.Fields[0]]
+}
+
+// containsCochraneHeading reports whether a query contains any subject headings or limits, which means it cannot be
+// searched on a single line.
+func containsCochraneHeading(q ir.BooleanQuery) bool {
+	if len(q.Limits) > 0 {
+		return true
+	}
+	for _, keyword := range q.Keywords {
+		if isCochraneHeading(keyword) {
+			return true
+		}
+	}
+	for _, child := range q.Children {
+		if containsCochraneHeading(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// cochraneHeading writes a subject heading as a MeSH descriptor, e.g.
+// `MeSH descriptor: [Dementia] explode all trees and with qualifier(s): [drug therapy - DT]`.
+func cochraneHeading(keyword ir.Keyword) string {
+	field := keyword.Fields[0]
+	heading := strings.Trim(keyword.QueryString, `"`)
+	if field != fields.MeshHeadings && field != fields.MajorFocusMeshHeading {
+		log.Printf("WARNING: writing the %v heading %v as a MeSH descriptor\n", field, heading)
+	}
+	if majorFocusFields[field] {
+		log.Printf("WARNING: the Cochrane Library does not have major headings, searching all of %v instead\n", heading)
+	}
+
+	repr := fmt.Sprintf("MeSH descriptor: [%s] this term only", heading)
+	if keyword.Exploded {
+		repr = fmt.Sprintf("MeSH descriptor: [%s] explode all trees", heading)
+	}
+
+	if subheadings := keywordSubheadings(keyword); len(subheadings) > 0 {
+		qualifiers := make([]string, len(subheadings))
+		for i, subheading := range subheadings {
+			qualifiers[i] = fmt.Sprintf("%s - %s", subheadingName(subheading), strings.ToUpper(subheading))
+		}
+		repr += fmt.Sprintf(" and with qualifier(s): [%s]", strings.Join(qualifiers, ", "))
+	}
+	return repr
+}
+
+// cochraneTerm formats the query string of a keyword, quoting phrases.
+func cochraneTerm(keyword ir.Keyword) string {
+	qs := keyword.QueryString
+	if strings.ContainsAny(qs, " \t") && !strings.HasPrefix(qs, `"`) {
+		qs = fmt.Sprintf(`"%s"`, qs)
+	}
+	return qs
+}
+
+// cochraneOperator formats the operator of a query. Adjacency is written as NEAR/n. The only ordered adjacency
+// operator of the Cochrane Library is NEXT, which finds terms next to each other.
+func cochraneOperator(q ir.BooleanQuery) string {
+	operator := strings.ToLower(q.Operator)
+	if strings.HasPrefix(operator, "adj") {
+		distance := operator[3:]
+		ordered, _ := q.Options[ir.OrderedOption].(bool)
+		if len(distance) == 0 || (ordered && distance == "1") {
+			return "NEXT"
+		}
+		if ordered {
+			log.Printf("WARNING: the Cochrane Library cannot search for terms in order within %v words, searching in any order instead\n", distance)
+		}
+		return "NEAR/" + distance
+	}
+	switch operator {
+	case cqr.AND, cqr.OR, cqr.NOT:
+		return strings.ToUpper(operator)
+	}
+	log.Printf("WARNING: unsupported operator %v, using AND instead\n", q.Operator)
+	return "AND"
+}
+
+// compileCochraneExpression compiles a query without subject headings into an expression which is searched on a
+// single line. When fielded is set, the keywords are written without their fields, since the fields are applied to
+// the entire expression.
+func compileCochraneExpression(q ir.BooleanQuery, fielded bool) string {
+	// Expressions searching the same fields are grouped under a single suffix, e.g. `(memory NEAR/3 loss):ti,ab,kw`.
+	if !fielded {
+		if f, ok := sharedFields(q); ok && len(q.Keywords)+len(q.Children) > 1 {
+			return fmt.Sprintf("(%s)%s", compileCochraneExpression(q, true), cochraneCodes(f))
+		}
+	}
+
+	var terms []string
+	for _, keyword := range q.Keywords {
+		if keyword.Range != nil {
+			log.Printf("WARNING: the Cochrane Library cannot search for the date range %v, ignoring it\n", keyword.Range)
+			continue
+		}
+		if fielded {
+			terms = append(terms, cochraneTerm(keyword))
+		} else {
+			terms = append(terms, cochraneTerm(keyword)+cochraneCodes(keyword.Fields))
+		}
+	}
+	for _, child := range q.Children {
+		if len(child.Keywords) == 1 && len(child.Children) == 0 {
+			terms = append(terms, compileCochraneExpression(child, fielded))
+			continue
+		}
+		if _, ok := sharedFields(child); ok && !fielded {
+			terms = append(terms, compileCochraneExpression(child, false))
+			continue
+		}
+		terms = append(terms, fmt.Sprintf("(%s)", compileCochraneExpression(child, fielded)))
+	}
+
+	return strings.Join(terms, fmt.Sprintf(" %s ", cochraneOperator(q)))
+}
+
+// compileCochrane writes the lines of a query, starting at the line level. The next line number is returned along
+// with the lines, and the number of the line which searches for the query (or zero when no line does).
+func compileCochrane(q ir.BooleanQuery, level int) (int, string, int) {
+	repr := ""
+	if q.Keywords == nil && len(q.Operator) == 0 {
+		target := 0
+		for _, child := range q.Children {
+			var comp string
+			level, comp, target = compileCochrane(child, level)
+			repr += comp
+		}
+		return level, repr, target
+	}
+
+	// Queries without subject headings are searched on a single line.
+	if !containsCochraneHeading(q) {
+		expression := compileCochraneExpression(q, false)
+		if len(expression) == 0 {
+			return level, repr, 0
+		}
+		return level + 1, fmt.Sprintf("#%d %s\n", level, expression), level
+	}
+
+	for _, limit := range q.Limits {
+		log.Printf("WARNING: the Cochrane Library cannot limit searches by %v, ignoring the limit\n", limit.Field)
+	}
+
+	if strings.HasPrefix(strings.ToLower(q.Operator), "adj") {
+		log.Printf("WARNING: the Cochrane Library cannot search for subject headings near other terms, using AND instead\n")
+		q.Operator = cqr.AND
+	}
+
+	// Each subject heading is searched on a line of its own, and consecutive free text keywords together on a single
+	// line. The lines are written in the order of the operands of the query (see ir.BooleanQuery).
+	var lines []int
+	var terms []ir.Keyword
+	searchTerms := func() {
+		if len(terms) == 0 {
+			return
+		}
+		operator := q.Operator
+		if len(lines) > 0 && strings.EqualFold(q.Operator, cqr.NOT) {
+			// Keywords after the first operand of a not query are all excluded from it.
+			operator = cqr.OR
+		}
+		l, comp, target := compileCochrane(ir.BooleanQuery{Operator: operator, Keywords: terms, Options: q.Options}, level)
+		repr += comp
+		level = l
+		if target > 0 {
+			lines = append(lines, target)
+		}
+		terms = nil
+	}
+	for _, keyword := range q.Keywords {
+		if isCochraneHeading(keyword) {
+			searchTerms()
+			repr += fmt.Sprintf("#%d %s\n", level, cochraneHeading(keyword))
+			lines = append(lines, level)
+			level++
+		} else {
+			terms = append(terms, keyword)
+		}
+	}
+	searchTerms()
+
+	for _, child := range q.Children {
+		l, comp, target := compileCochrane(child, level)
+		repr += comp
+		level = l
+		if target > 0 {
+			lines = append(lines, target)
+		}
+	}
+
+	switch len(lines) {
+	case 0:
+		return level, repr, 0
+	case 1:
+		return level, repr, lines[0]
+	}
+	refs := make([]string, len(lines))
+	for i, line := range lines {
+		refs[i] = fmt.Sprintf("#%d", line)
+	}
+	repr += fmt.Sprintf("#%d %s\n", level, strings.Join(refs, fmt.Sprintf(" %s ", cochraneOperator(q))))
+	return level + 1, repr, level
+}
+
+// Compile transforms an immediate representation of a query into a Cochrane Library search strategy.
+func (b CochraneLibBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
+	_, repr, _ := compileCochrane(q, 1)
+	return CochraneLibQuery{repr: repr}, nil
+}
+
+// NewCochraneLibBackend returns a new backend for compiling Cochrane Library search strategies.
+func NewCochraneLibBackend() CochraneLibBackend {
+	return CochraneLibBackend{}
+}
@@ -0,0 +1,53 @@
+package backend
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"testing"
+)
+
+func TestCochraneLibBackend_Not(t *testing.T) {
+	tests := []struct {
+		query    ir.BooleanQuery
+		expected string
+	}{
+		{
+			query: ir.BooleanQuery{
+				Operator: cqr.NOT,
+				Keywords: []ir.Keyword{
+					{QueryString: "dementia", Fields: []string{fields.Title}},
+					{QueryString: "Animals", Fields: []string{fields.MeshHeadings}, Exploded: true},
+				},
+			},
+			expected: "#1 dementia:ti\n#2 MeSH descriptor: [Animals] explode all trees\n#3 #1 NOT #2\n",
+		},
+		{
+			query: ir.BooleanQuery{
+				Operator: cqr.NOT,
+				Keywords: []ir.Keyword{
+					{QueryString: "Dementia", Fields: []string{fields.MeshHeadings}, Exploded: true},
+					{QueryString: "mice", Fields: []string{fields.Title}},
+					{QueryString: "rats", Fields: []string{fields.Title}},
+				},
+				Children: []ir.BooleanQuery{
+					{Operator: cqr.OR, Keywords: []ir.Keyword{{QueryString: "Animals", Fields: []string{fields.MeshHeadings}}}},
+				},
+			},
+			expected: "#1 MeSH descriptor: [Dementia] explode all trees\n#2 (mice OR rats):ti\n#3 MeSH descriptor: [Animals] this term only\n#4 #1 NOT #2 NOT #3\n",
+		},
+	}
+	for _, test := range tests {
+		q, err := NewCochraneLibBackend().Compile(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := q.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expected {
+			t.Errorf("expected\n%v\ngot\n%v", test.expected, got)
+		}
+	}
+}
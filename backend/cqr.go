@@ -31,12 +31,23 @@ func (q CommonQueryRepresentationQuery) StringPretty() (string, error) {
 	return string(b), err
 }
 
+// cqrQueryString returns the query string of a keyword. CQR cannot represent date ranges, so they are written in the
+// query string as `start:end` (e.g. `2000/01/01:2010/12/31`), like the publication date ranges of PubMed.
+func cqrQueryString(keyword ir.Keyword) string {
+	if keyword.Range != nil {
+		return keyword.Range.String()
+	}
+	return keyword.QueryString
+}
+
 // Compile transforms the transmute ir into CQR. The CQR is slightly different to the transmute ir, in that the
 // depth of the children is different. Take note of how the children of a transmute ir differs from the children of CQR.
+// Limits are applied to the query as keywords which must match.
 func (b CommonQueryRepresentationBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
+	q = applyLimits(q)
 	var children []cqr.CommonQueryRepresentation
 	for _, keyword := range q.Keywords {
-		k := cqr.NewKeyword(keyword.QueryString, keyword.Fields...)
+		k := cqr.NewKeyword(cqrQueryString(keyword), keyword.Fields...)
 		k.Options = keyword.Options
 		if k.Options == nil {
 			k.Options = make(map[string]interface{})
@@ -55,7 +66,7 @@ func (b CommonQueryRepresentationBackend) Compile(q ir.BooleanQuery) (BooleanQue
 			subChildren = append(subChildren, cqrSub)
 		}
 		for _, keyword := range child.Keywords {
-			k := cqr.NewKeyword(keyword.QueryString, keyword.Fields...).
+			k := cqr.NewKeyword(cqrQueryString(keyword), keyword.Fields...).
 				SetOption(cqr.ExplodedString, keyword.Exploded).
 				SetOption(cqr.TruncatedString, keyword.Truncated).(cqr.Keyword)
 			//if !keyword.Exploded {
@@ -82,7 +93,7 @@ func (b CommonQueryRepresentationBackend) Compile(q ir.BooleanQuery) (BooleanQue
 	if len(q.Operator) == 0 && len(q.Children) == 1 {
 		var keywords []cqr.CommonQueryRepresentation
 		for _, kw := range q.Children[0].Keywords {
-			keywords = append(keywords, cqr.NewKeyword(kw.QueryString, kw.Fields...).SetOption(cqr.ExplodedString, kw.Exploded).SetOption(cqr.TruncatedString, kw.Truncated))
+			keywords = append(keywords, cqr.NewKeyword(cqrQueryString(kw), kw.Fields...).SetOption(cqr.ExplodedString, kw.Exploded).SetOption(cqr.TruncatedString, kw.Truncated))
 		}
 
 		for _, child := range q.Children[0].Children {
@@ -0,0 +1,38 @@
+package backend
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"reflect"
+	"testing"
+)
+
+func TestCommonQueryRepresentationBackend_Limits(t *testing.T) {
+	q, err := NewCQRBackend().Compile(limitedQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := q.Representation()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bq, ok := got.(cqr.BooleanQuery)
+	if !ok || bq.Operator != cqr.AND || len(bq.Children) != 3 {
+		t.Fatalf("expected the query to be limited by two clauses, got %v", got)
+	}
+	expected := []cqr.Keyword{
+		cqr.NewKeyword("2000/01/01:2010/12/31", fields.PublicationDate),
+		cqr.NewKeyword("Humans", fields.MeshHeadings),
+	}
+	for i, e := range expected {
+		limit, ok := bq.Children[i+1].(cqr.BooleanQuery)
+		if !ok || len(limit.Children) != 1 {
+			t.Fatalf("expected a limit with a single keyword, got %v", bq.Children[i+1])
+		}
+		keyword, ok := limit.Children[0].(cqr.Keyword)
+		if !ok || keyword.QueryString != e.QueryString || !reflect.DeepEqual(keyword.Fields, e.Fields) {
+			t.Errorf("expected the limit %v, got %v", e, limit.Children[0])
+		}
+	}
+}
@@ -9,8 +9,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/hscells/meshexp"
+	"github.com/hscells/transmute/fields"
 	"github.com/hscells/transmute/ir"
 	"github.com/pkg/errors"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -19,6 +21,10 @@ import (
 type ElasticsearchQuery struct {
 	queryString string
 	fields      []string
+	dateRange   *ir.DateRange
+	subheadings []string
+	frequency   int
+	truncated   bool
 }
 
 // ElasticsearchBooleanQuery is the transmute representation of an Elasticsearch query.
@@ -52,6 +58,9 @@ func NewElasticsearchCompiler() ElasticsearchCompiler {
 func (b ElasticsearchCompiler) Compile(ir ir.BooleanQuery) (BooleanQuery, error) {
 	elasticSearchBooleanQuery := ElasticsearchBooleanQuery{}
 
+	// Limits are filters on the query.
+	ir = applyLimits(ir)
+
 	var queries []ElasticsearchQuery
 
 	// This is really the only thing that differs from the IR; Elasticsearch has funny boolean operators.
@@ -70,6 +79,12 @@ func (b ElasticsearchCompiler) Compile(ir ir.BooleanQuery) (BooleanQuery, error)
 		query := ElasticsearchQuery{}
 		query.queryString = keyword.QueryString
 		query.fields = keyword.Fields
+		query.dateRange = keyword.Range
+		query.frequency = keywordFrequency(keyword)
+		query.truncated = keyword.Truncated
+		if len(keyword.Fields) == 1 && subjectHeadingFields[keyword.Fields[0]] {
+			query.subheadings = keywordSubheadings(keyword)
+		}
 		queries = append(queries, query)
 
 		if keyword.Exploded {
@@ -78,6 +93,8 @@ func (b ElasticsearchCompiler) Compile(ir ir.BooleanQuery) (BooleanQuery, error)
 				queries = append(queries, ElasticsearchQuery{
 					queryString: term,
 					fields:      keyword.Fields,
+					subheadings: query.subheadings,
+					frequency:   query.frequency,
 				})
 			}
 		}
@@ -200,7 +217,7 @@ func (q ElasticsearchBooleanQuery) traverseGroup(node m) (m, error) {
 			if child.grouping != "should" {
 				s, err := child.StringPretty()
 				if err != nil {
-					return nil, errors.New(fmt.Sprintf("unsupported operator for slop `%v` (can't show query)", child.grouping, ))
+					return nil, errors.New(fmt.Sprintf("unsupported operator for slop `%v` (can't show query)", child.grouping))
 				}
 				return nil, errors.New(fmt.Sprintf("unsupported operator for slop `%v`\noffending query:\n%v", child.grouping, s))
 			}
@@ -306,6 +323,42 @@ func (q ElasticsearchBooleanQuery) traverseGroup(node m) (m, error) {
 
 			queryString := q.queries[i].queryString
 
+			// Date ranges are searched using range queries.
+			if q.queries[i].dateRange != nil {
+				if len(fields) == 0 {
+					return nil, errors.New(fmt.Sprintf("a date range `%v` did not contain any fields", q.queries[i].dateRange))
+				}
+				var queries []interface{}
+				for _, field := range fields {
+					queries = append(queries, q.queries[i].createRangeClause(field))
+				}
+				if len(queries) == 1 {
+					groups[subQuery] = queries[0]
+				} else {
+					groups[subQuery] = m{"bool": m{"should": queries}}
+				}
+				subQuery++
+				continue
+			}
+
+			// Keywords which must occur a minimum number of times are searched using span queries.
+			if q.queries[i].frequency > 1 {
+				if len(fields) == 0 {
+					return nil, errors.New(fmt.Sprintf("a query `%v` did not contain any fields", queryString))
+				}
+				var queries []interface{}
+				for _, field := range fields {
+					queries = append(queries, q.queries[i].createFrequencyClause(field))
+				}
+				if len(queries) == 1 {
+					groups[subQuery] = queries[0]
+				} else {
+					groups[subQuery] = m{"bool": m{"should": queries}}
+				}
+				subQuery++
+				continue
+			}
+
 			matchType := "match"
 			if strings.ContainsRune(queryString, ' ') {
 				matchType = "match_phrase"
@@ -317,13 +370,13 @@ func (q ElasticsearchBooleanQuery) traverseGroup(node m) (m, error) {
 				if strings.ContainsAny(queryString, "*?~") {
 					var queries []interface{}
 					/*
-										{
-					              			"query_string": {
-					                			"query": "text.stemmed:gonadotrop?in releasing hormone agonist*",
-					                			"analyze_wildcard": true,
-					               	 			"split_on_whitespace" : false
-					              			}
-										}
+											{
+						              			"query_string": {
+						                			"query": "text.stemmed:gonadotrop?in releasing hormone agonist*",
+						                			"analyze_wildcard": true,
+						               	 			"split_on_whitespace" : false
+						              			}
+											}
 					*/
 					for _, field := range fields {
 						queries = append(queries, m{
@@ -389,6 +442,15 @@ func (q ElasticsearchBooleanQuery) traverseGroup(node m) (m, error) {
 				return nil, errors.New(fmt.Sprintf("a query `%v` did not contain any fields", queryString))
 			}
 
+			// Headings restricted to subheadings must also match one of the subheadings.
+			if len(q.queries[i].subheadings) > 0 {
+				query = m{
+					"bool": m{
+						"filter": []interface{}{query, q.queries[i].createSubheadingClause()},
+					},
+				}
+			}
+
 			groups[subQuery] = query
 			subQuery++
 		}
@@ -413,6 +475,23 @@ func (q ElasticsearchBooleanQuery) traverseGroup(node m) (m, error) {
 	return node, nil
 }
 
+// createSubheadingClause creates a query matching any of the subheadings of a heading in the subheading field.
+func (q ElasticsearchQuery) createSubheadingClause() map[string]interface{} {
+	var queries []interface{}
+	for _, subheading := range q.subheadings {
+		queries = append(queries, m{
+			"match_phrase": m{
+				fields.MeSHSubheading: subheadingName(subheading),
+			},
+		})
+	}
+	return m{
+		"bool": m{
+			"should": queries,
+		},
+	}
+}
+
 // createAdjacentClause attempts to create an Elasticsearch version of the `adj` operator in Pubmed/Medline (slop).
 func (q ElasticsearchQuery) createAdjacentClause(field string) map[string]interface{} {
 	innerClauses := make(map[string]interface{})
@@ -491,6 +570,48 @@ func (q ElasticsearchQuery) createAdjacentClause(field string) map[string]interf
 	return innerClauses
 }
 
+// createFrequencyClause creates a query matching documents where the keyword occurs at least as many times as its
+// frequency in the field. Spans of an ordered span_near query cannot overlap, so repeating the keyword as many times as
+// the frequency, with an unlimited slop, requires that many distinct occurrences of the keyword. Only truncated
+// keywords and phrases are written as they are in an adjacency query; a term must match exactly.
+func (q ElasticsearchQuery) createFrequencyClause(field string) map[string]interface{} {
+	clause := m{
+		"span_term": m{
+			field: q.queryString,
+		},
+	}
+	if q.truncated || strings.Contains(q.queryString, " ") {
+		clause = q.createAdjacentClause(field)
+	}
+	clauses := make([]interface{}, q.frequency)
+	for i := range clauses {
+		clauses[i] = clause
+	}
+	return m{
+		"span_near": m{
+			"clauses":  clauses,
+			"in_order": true,
+			"slop":     math.MaxInt32,
+		},
+	}
+}
+
+// createRangeClause creates a range query for the dates of a query. Open bounds of the range are left out.
+func (q ElasticsearchQuery) createRangeClause(field string) map[string]interface{} {
+	bounds := m{"format": "yyyy-MM-dd"}
+	if !q.dateRange.Start.IsZero() {
+		bounds["gte"] = q.dateRange.Start.Format("2006-01-02")
+	}
+	if !q.dateRange.End.IsZero() {
+		bounds["lte"] = q.dateRange.End.Format("2006-01-02")
+	}
+	return m{
+		"range": m{
+			field: bounds,
+		},
+	}
+}
+
 // String creates a machine-readable JSON Elasticsearch query.
 func (q ElasticsearchBooleanQuery) String() (string, error) {
 	r, err := q.Representation()
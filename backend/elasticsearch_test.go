@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"testing"
+	"time"
+)
+
+func TestElasticsearchCompiler_Clauses(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    ir.BooleanQuery
+		expected string
+	}{
+		{
+			// Each occurrence of the keyword is a clause of an ordered span_near with an unlimited slop.
+			name: "frequency",
+			query: ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{
+				{QueryString: "dementia", Fields: []string{fields.Title}, Options: map[string]interface{}{ir.FrequencyOption: 2}},
+			}},
+			expected: `{"query":{"constant_score":{"filter":{"bool":{"disable_coord":true,"should":[{"span_near":{"clauses":[{"span_term":{"title":"dementia"}},{"span_term":{"title":"dementia"}}],"in_order":true,"slop":2147483647}}]}}}}}`,
+		},
+		{
+			name: "truncated frequency",
+			query: ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{
+				{QueryString: "dement*", Fields: []string{fields.Title}, Truncated: true, Options: map[string]interface{}{ir.FrequencyOption: 2}},
+			}},
+			expected: `{"query":{"constant_score":{"filter":{"bool":{"disable_coord":true,"should":[{"span_near":{"clauses":[{"span_multi":{"match":{"wildcard":{"title":"dement*"}}}},{"span_multi":{"match":{"wildcard":{"title":"dement*"}}}}],"in_order":true,"slop":2147483647}}]}}}}}`,
+		},
+		{
+			name: "subheadings",
+			query: ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{
+				{QueryString: "Dementia", Fields: []string{fields.MeshHeadings}, Options: map[string]interface{}{ir.SubheadingsOption: []string{"dt"}}},
+			}},
+			expected: `{"query":{"constant_score":{"filter":{"bool":{"disable_coord":true,"should":[{"bool":{"filter":[{"match":{"mesh_headings":"Dementia"}},{"bool":{"should":[{"match_phrase":{"mesh_subheading":"drug therapy"}}]}}]}}]}}}}}`,
+		},
+		{
+			name: "date range",
+			query: ir.BooleanQuery{
+				Operator: cqr.AND,
+				Keywords: []ir.Keyword{{QueryString: "dementia", Fields: []string{fields.Title}}},
+				Limits: []ir.Limit{{Field: fields.PublicationDate, Range: &ir.DateRange{
+					Start: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+					End:   time.Date(2010, 12, 31, 0, 0, 0, 0, time.UTC),
+				}}},
+			},
+			expected: `{"query":{"constant_score":{"filter":{"bool":{"disable_coord":true,"filter":[{"bool":{"disable_coord":true,"filter":[{"match":{"title":"dementia"}}]}},{"bool":{"disable_coord":true,"should":[{"range":{"publication_date":{"format":"yyyy-MM-dd","gte":"2000-01-01","lte":"2010-12-31"}}}]}}]}}}}}`,
+		},
+	}
+	b := NewElasticsearchCompiler()
+	for _, test := range tests {
+		q, err := b.Compile(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := q.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expected {
+			t.Errorf("%v: expected\n%v\ngot\n%v", test.name, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package backend
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"strings"
+)
+
+// EmbaseComBackend compiles queries into the Embase.com (Elsevier) search syntax, e.g.
+// `'dementia'/exp OR (memory NEAR/3 loss):ti,ab,kw`.
+type EmbaseComBackend struct{}
+
+// EmbaseComQuery is the transmute representation of an Embase.com query.
+type EmbaseComQuery struct {
+	repr string
+}
+
+// embaseComFieldCodes maps fields onto the Embase.com field codes which search them. Fields which are searched
+// everywhere are not given a code.
+var embaseComFieldCodes = map[string][]string{
+	fields.Title:              {"ti"},
+	fields.Abstract:           {"ab"},
+	fields.TitleAbstract:      {"ti", "ab"},
+	fields.TextWord:           {"ti", "ab"},
+	fields.Keywords:           {"kw"},
+	fields.AllFields:          {},
+	fields.Authors:            {"au"},
+	fields.Author:             {"au"},
+	fields.AuthorFull:         {"au"},
+	fields.AuthorLast:         {"au"},
+	fields.Affiliation:        {"ff"},
+	fields.Journal:            {"jt"},
+	fields.Language:           {"la"},
+	fields.PublicationType:    {"it"},
+	fields.PublicationDate:    {"py"},
+	fields.DrugTradeName:      {"tn"},
+	fields.DrugManufacturer:   {"mn"},
+	fields.DeviceTradeName:    {"dn"},
+	fields.DeviceManufacturer: {"df"},
+}
+
+// embaseComCodeOrder is the order field codes are written in, e.g. `:ti,ab,kw`.
+var embaseComCodeOrder = []string{"ti", "ab", "kw", "au", "ff", "jt", "la", "it", "py", "tn", "mn", "dn", "df"}
+
+func (q EmbaseComQuery) Representation() (interface{}, error) {
+	return q.repr, nil
+}
+
+func (q EmbaseComQuery) String() (string, error) {
+	return q.repr, nil
+}
+
+func (q EmbaseComQuery) StringPretty() (string, error) {
+	return q.repr, nil
+}
+
+// embaseComCodes maps the fields of a keyword onto an Embase.com field suffix, e.g. `:ti,ab`. Keywords searched in
+// all fields do not have a suffix.
+func embaseComCodes(keywordFields []string) string {
+	seen := make(map[string]bool)
+	for _, field := range keywordFields {
+		c, ok := embaseComFieldCodes[field]
+		if !ok {
+			log.Printf("WARNING: could not map the field %v, searching all fields instead\n", field)
+			return ""
+		}
+		if len(c) == 0 {
+			return ""
+		}
+		for _, code := range c {
+			seen[code] = true
+		}
+	}
+	var codes []string
+	for _, code := range embaseComCodeOrder {
+		if seen[code] {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return ""
+	}
+	return ":" + strings.Join(codes, ",")
+}
+
+// embaseComHeading compiles a subject heading keyword, or reports that the keyword is not a subject heading. Emtree
+// headings are written as `'dementia'/exp`, or `'dementia'/de` when the heading is not exploded.
+func embaseComHeading(keyword ir.Keyword) (string, bool) {
+	if len(keyword.Fields) != 1 || !subjectHeadingFields[keyword.Fields[0]] {
+		return "", false
+	}
+	if len(keywordSubheadings(keyword)) > 0 {
+		log.Printf("WARNING: ignoring the subheadings of %v\n", keyword.QueryString)
+	}
+	heading := fmt.Sprintf("'%s'", strings.ToLower(strings.Trim(keyword.QueryString, `"`)))
+	switch {
+	case keyword.Exploded && majorFocusFields[keyword.Fields[0]]:
+		return heading + "/exp/mj", true
+	case keyword.Exploded:
+		return heading + "/exp", true
+	case majorFocusFields[keyword.Fields[0]]:
+		return heading + "/mj", true
+	}
+	return heading + "/de", true
+}
+
+// embaseComLimit compiles a keyword which limits the languages or publication years of a query, e.g. `[english]/lim`
+// or `[2000-2010]/py`, or reports that the keyword is not a limit.
+func embaseComLimit(keyword ir.Keyword) (string, bool) {
+	if len(keyword.Fields) != 1 {
+		return "", false
+	}
+	switch {
+	case keyword.Fields[0] == fields.PublicationDate && keyword.Range != nil:
+		return compileEmbaseComDateRange(*keyword.Range) + "/py", true
+	case keyword.Fields[0] == fields.Language && !strings.ContainsAny(keyword.QueryString, " \t*"):
+		return fmt.Sprintf("[%s]/lim", strings.ToLower(keyword.QueryString)), true
+	}
+	return "", false
+}
+
+// embaseComTerm formats the query string of a keyword. Phrases are written in single quotes, unless they must match
+// exactly, in which case they are written in double quotes.
+func embaseComTerm(keyword ir.Keyword) string {
+	if keyword.Range != nil {
+		return compileEmbaseComDateRange(*keyword.Range)
+	}
+	qs := keyword.QueryString
+	if !strings.ContainsAny(qs, " \t") {
+		return qs
+	}
+	if phrase, ok := keyword.Options[ir.PhraseOption].(string); ok && phrase == ir.ExactPhrase && strings.HasPrefix(qs, `"`) {
+		return qs
+	}
+	return fmt.Sprintf("'%s'", strings.Trim(qs, `"`))
+}
+
+// compileEmbaseComDateRange formats a date range as a range of publication years, e.g. `[2000-2010]`. Embase.com
+// only searches publication years, so ranges which are open are closed with the years 1000 and 3000.
+func compileEmbaseComDateRange(r ir.DateRange) string {
+	start, end := "1000", "3000"
+	if !r.Start.IsZero() {
+		start = r.Start.Format("2006")
+	}
+	if !r.End.IsZero() {
+		end = r.End.Format("2006")
+	}
+	return fmt.Sprintf("[%s-%s]", start, end)
+}
+
+// embaseComOperator formats the operator of a query. Adjacency is written as NEAR/n, or NEXT/n when the keywords must
+// appear in order.
+func embaseComOperator(q ir.BooleanQuery) string {
+	operator := strings.ToLower(q.Operator)
+	if strings.HasPrefix(operator, "adj") {
+		distance := operator[3:]
+		if len(distance) == 0 {
+			return "NEXT/1"
+		}
+		if ordered, ok := q.Options[ir.OrderedOption].(bool); ok && ordered {
+			return "NEXT/" + distance
+		}
+		return "NEAR/" + distance
+	}
+	switch operator {
+	case cqr.AND, cqr.OR, cqr.NOT:
+		return strings.ToUpper(operator)
+	}
+	log.Printf("WARNING: unsupported operator %v, using AND instead\n", q.Operator)
+	return "AND"
+}
+
+// compileEmbaseCom compiles a query. When fielded is set, the keywords are written without their fields, since the
+// fields are applied to the entire query.
+func compileEmbaseCom(q ir.BooleanQuery, fielded bool) string {
+	if q.Keywords == nil && len(q.Operator) == 0 {
+		var repr string
+		for _, child := range q.Children {
+			repr += compileEmbaseCom(child, fielded)
+		}
+		return repr
+	}
+
+	// Queries searching the same fields are grouped under a single suffix, e.g. `(memory NEAR/3 loss):ti,ab`.
+	if !fielded {
+		if f, ok := sharedFields(q); ok {
+			if codes := embaseComCodes(f); len(codes) > 0 && len(q.Keywords)+len(q.Children) > 1 {
+				return fmt.Sprintf("(%s)%s", compileEmbaseCom(q, true), codes)
+			}
+		}
+	}
+
+	var terms []string
+	for _, keyword := range q.Keywords {
+		if heading, ok := embaseComHeading(keyword); ok {
+			terms = append(terms, heading)
+		} else if limit, ok := embaseComLimit(keyword); ok && !fielded {
+			terms = append(terms, limit)
+		} else if fielded {
+			terms = append(terms, embaseComTerm(keyword))
+		} else {
+			terms = append(terms, embaseComTerm(keyword)+embaseComCodes(keyword.Fields))
+		}
+	}
+	for _, child := range q.Children {
+		// A child which is a single keyword, or which is grouped under its own suffix, does not need to be grouped.
+		if len(child.Keywords) == 1 && len(child.Children) == 0 {
+			terms = append(terms, compileEmbaseCom(child, fielded))
+			continue
+		}
+		if f, ok := sharedFields(child); ok && !fielded && len(embaseComCodes(f)) > 0 {
+			terms = append(terms, compileEmbaseCom(child, false))
+			continue
+		}
+		terms = append(terms, fmt.Sprintf("(%s)", compileEmbaseCom(child, fielded)))
+	}
+
+	return strings.Join(terms, fmt.Sprintf(" %s ", embaseComOperator(q)))
+}
+
+// Compile transforms an immediate representation of a query into an Embase.com query.
+func (b EmbaseComBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
+	return EmbaseComQuery{repr: compileEmbaseCom(applyLimits(q), false)}, nil
+}
+
+// NewEmbaseComBackend returns a new backend for compiling Embase.com queries.
+func NewEmbaseComBackend() EmbaseComBackend {
+	return EmbaseComBackend{}
+}
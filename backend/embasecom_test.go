@@ -0,0 +1,102 @@
+package backend
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"github.com/hscells/transmute/lexer"
+	"github.com/hscells/transmute/parser"
+	"testing"
+	"time"
+)
+
+func TestEmbaseComBackend_Compile(t *testing.T) {
+	keyword := func(term string, f ...string) ir.Keyword {
+		return ir.Keyword{QueryString: term, Fields: f}
+	}
+	tests := []struct {
+		name     string
+		query    ir.BooleanQuery
+		expected string
+	}{
+		{
+			name:     "title and abstract",
+			query:    ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{keyword("dementia", fields.TitleAbstract), keyword("amnesia", fields.TitleAbstract)}},
+			expected: "(dementia OR amnesia):ti,ab",
+		},
+		{
+			name: "mixed fields",
+			query: ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{
+				keyword("dementia", fields.TitleAbstract, fields.Keywords),
+				keyword("memory loss", fields.Title),
+			}},
+			expected: "dementia:ti,ab,kw OR 'memory loss':ti",
+		},
+		{
+			name: "headings",
+			query: ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{
+				{QueryString: "Dementia", Fields: []string{fields.EmtreeHeadings}, Exploded: true},
+				keyword("Alzheimer Disease", fields.EmtreeHeadings),
+				keyword("Delirium", fields.MajorFocusEmtreeHeading),
+			}},
+			expected: "'dementia'/exp OR 'alzheimer disease'/de OR 'delirium'/mj",
+		},
+		{
+			name:     "near",
+			query:    ir.BooleanQuery{Operator: "adj3", Keywords: []ir.Keyword{keyword("memory", fields.TitleAbstract), keyword("loss", fields.TitleAbstract)}},
+			expected: "(memory NEAR/3 loss):ti,ab",
+		},
+		{
+			name: "next",
+			query: ir.BooleanQuery{
+				Operator: "adj2",
+				Keywords: []ir.Keyword{keyword("mild", fields.Title), {QueryString: "impair*", Fields: []string{fields.Title}, Truncated: true}},
+				Options:  map[string]interface{}{ir.OrderedOption: true},
+			},
+			expected: "(mild NEXT/2 impair*):ti",
+		},
+		{
+			name: "limit",
+			query: ir.BooleanQuery{
+				Operator: cqr.OR,
+				Keywords: []ir.Keyword{keyword("dementia", fields.TitleAbstract)},
+				Limits: []ir.Limit{
+					{Field: fields.Language, Values: []string{"english"}},
+					{Field: fields.PublicationDate, Range: &ir.DateRange{
+						Start: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+						End:   time.Date(2010, 12, 31, 0, 0, 0, 0, time.UTC),
+					}},
+				},
+			},
+			expected: "dementia:ti,ab AND [english]/lim AND [2000-2010]/py",
+		},
+	}
+	for _, test := range tests {
+		q, err := NewEmbaseComBackend().Compile(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := q.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestEmbaseComBackend_RoundTrip(t *testing.T) {
+	query := `('dementia'/exp OR 'delirium'/de OR (memory NEAR/3 loss):ti,ab) AND [english]/lim AND [2000-2010]/py`
+	q, err := NewEmbaseComBackend().Compile(parser.NewEmbaseComParser().Parse(lexer.Node{Value: query, Reference: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := q.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != query {
+		t.Errorf("expected %v, got %v", query, got)
+	}
+}
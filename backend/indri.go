@@ -0,0 +1,264 @@
+package backend
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/meshexp"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// IndriBackend compiles queries into Indri (and Galago) structured queries, e.g.
+// `#band(#bor(#syn(dementia.mesh_headings #1(alzheimer disease).mesh_headings) #uw3(memory loss).title) #not(review.publication_type))`.
+type IndriBackend struct {
+	// Fields maps fields onto the fields of the index. Fields which are mapped onto no fields are searched in the
+	// entire document, and fields which are not mapped are searched in a field with the same name.
+	Fields map[string][]string
+	tree   *meshexp.MeSHTree
+}
+
+// IndriQuery is the transmute representation of an Indri query.
+type IndriQuery struct {
+	repr string
+}
+
+func (q IndriQuery) Representation() (interface{}, error) {
+	return q.repr, nil
+}
+
+func (q IndriQuery) String() (string, error) {
+	return q.repr, nil
+}
+
+func (q IndriQuery) StringPretty() (string, error) {
+	return q.repr, nil
+}
+
+// indexFields maps fields onto the fields of the index.
+func (b IndriBackend) indexFields(keywordFields []string) []string {
+	var indexFields []string
+	seen := make(map[string]bool)
+	for _, field := range keywordFields {
+		f, ok := b.Fields[field]
+		if !ok {
+			f = []string{field}
+		}
+		if len(f) == 0 {
+			// The entire document is searched when there is no field restriction.
+			return nil
+		}
+		for _, indexField := range f {
+			if !seen[indexField] {
+				seen[indexField] = true
+				indexFields = append(indexFields, indexField)
+			}
+		}
+	}
+	return indexFields
+}
+
+// restrict restricts an expression to each of the fields, e.g. `#bor(dementia.title dementia.abstract)`.
+func (b IndriBackend) restrict(keywordFields []string, expression string) string {
+	indexFields := b.indexFields(keywordFields)
+	if len(indexFields) == 0 {
+		return expression
+	}
+	expressions := make([]string, len(indexFields))
+	for i, field := range indexFields {
+		expressions[i] = fmt.Sprintf("%s.%s", expression, field)
+	}
+	if len(expressions) == 1 {
+		return expressions[0]
+	}
+	return fmt.Sprintf("#bor(%s)", strings.Join(expressions, " "))
+}
+
+// indriTerm formats a query string as a term, or as an ordered window of its words, e.g. `#1(alzheimer disease)`.
+// Indri does not index punctuation, so the words are separated at punctuation. Truncated terms are written with a
+// trailing wildcard.
+func indriTerm(queryString string, truncated bool) string {
+	qs := queryString
+	if truncated {
+		if i := strings.IndexAny(qs, "*$#?"); i >= 0 {
+			if i < len(qs)-1 {
+				log.Printf("WARNING: Indri only supports trailing wildcards, searching for %v as the prefix %v\n", qs, qs[:i])
+			}
+			qs = qs[:i]
+		}
+	}
+	words := strings.FieldsFunc(strings.ToLower(qs), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if truncated && len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+	if len(words) == 1 {
+		return words[0]
+	}
+	return fmt.Sprintf("#1(%s)", strings.Join(words, " "))
+}
+
+// compileIndriDateRange writes a date range with the date operators of Indri, e.g. `#datebetween(01/01/2000 12/31/2010)`.
+func compileIndriDateRange(r ir.DateRange) string {
+	const format = "01/02/2006"
+	switch {
+	case r.Start.IsZero() && r.End.IsZero():
+		return ""
+	case r.Start.IsZero():
+		return fmt.Sprintf("#datebefore(%s)", r.End.AddDate(0, 0, 1).Format(format))
+	case r.End.IsZero():
+		return fmt.Sprintf("#dateafter(%s)", r.Start.AddDate(0, 0, -1).Format(format))
+	}
+	return fmt.Sprintf("#datebetween(%s %s)", r.Start.Format(format), r.End.Format(format))
+}
+
+// keyword compiles a keyword into a field restricted term. An exploded subject heading is searched as a synonym of the
+// headings beneath it, e.g. `#syn(dementia.mesh_headings #1(alzheimer disease).mesh_headings)`.
+func (b IndriBackend) keyword(keyword ir.Keyword) string {
+	if keyword.Range != nil {
+		return compileIndriDateRange(*keyword.Range)
+	}
+
+	term := indriTerm(strings.Trim(keyword.QueryString, `"`), keyword.Truncated)
+	if !isExplodedHeading(keyword) {
+		return b.restrict(keyword.Fields, term)
+	}
+
+	terms := []string{term}
+	seen := map[string]bool{term: true}
+	for _, heading := range explodedHeadings(b.tree, keyword.QueryString)[1:] {
+		if t := indriTerm(heading, false); !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	if len(terms) == 1 {
+		return b.restrict(keyword.Fields, term)
+	}
+	indexFields := b.indexFields(keyword.Fields)
+	if len(indexFields) == 0 {
+		return fmt.Sprintf("#syn(%s)", strings.Join(terms, " "))
+	}
+	var synonyms []string
+	for _, t := range terms {
+		for _, field := range indexFields {
+			synonyms = append(synonyms, fmt.Sprintf("%s.%s", t, field))
+		}
+	}
+	return fmt.Sprintf("#syn(%s)", strings.Join(synonyms, " "))
+}
+
+// window compiles an adjacency query into an ordered (#odN) or unordered (#uwN) window, or reports that the query
+// cannot be written as one. The keywords of a window must be searched in the same fields, and a disjunction of
+// keywords inside a window is searched as a synonym, e.g. `#uw3(#syn(memory recall) loss).title`.
+func (b IndriBackend) window(q ir.BooleanQuery) (string, bool) {
+	f, ok := sharedFields(q)
+	if !ok {
+		return "", false
+	}
+	var terms []string
+	for _, keyword := range q.Keywords {
+		if keyword.Range != nil {
+			return "", false
+		}
+		terms = append(terms, indriTerm(strings.Trim(keyword.QueryString, `"`), keyword.Truncated))
+	}
+	for _, child := range q.Children {
+		if strings.ToLower(child.Operator) != cqr.OR || len(child.Children) > 0 {
+			return "", false
+		}
+		var synonyms []string
+		for _, keyword := range child.Keywords {
+			if keyword.Range != nil {
+				return "", false
+			}
+			synonyms = append(synonyms, indriTerm(strings.Trim(keyword.QueryString, `"`), keyword.Truncated))
+		}
+		terms = append(terms, fmt.Sprintf("#syn(%s)", strings.Join(synonyms, " ")))
+	}
+
+	distance := strings.TrimPrefix(strings.ToLower(q.Operator), "adj")
+	operator := "#uw"
+	if len(distance) == 0 {
+		distance = "1"
+		operator = "#od"
+	} else if ordered, ok := q.Options[ir.OrderedOption].(bool); ok && ordered {
+		operator = "#od"
+	}
+	return b.restrict(f, fmt.Sprintf("%s%s(%s)", operator, distance, strings.Join(terms, " "))), true
+}
+
+// compile compiles a query into an Indri query. The queries excluded by a not query are each negated with #not.
+func (b IndriBackend) compile(q ir.BooleanQuery) string {
+	if q.Keywords == nil && len(q.Operator) == 0 {
+		var terms []string
+		for _, child := range q.Children {
+			terms = append(terms, b.compile(child))
+		}
+		if len(terms) == 1 {
+			return terms[0]
+		}
+		return fmt.Sprintf("#band(%s)", strings.Join(terms, " "))
+	}
+
+	operator := strings.ToLower(q.Operator)
+	if strings.HasPrefix(operator, "adj") {
+		if window, ok := b.window(q); ok {
+			return window
+		}
+		log.Printf("WARNING: could not write the %v query as a window, searching for all of its terms instead\n", q.Operator)
+		operator = cqr.AND
+	}
+
+	var terms []string
+	for _, keyword := range q.Keywords {
+		if term := b.keyword(keyword); len(term) > 0 {
+			terms = append(terms, term)
+		}
+	}
+	for _, child := range q.Children {
+		terms = append(terms, b.compile(child))
+	}
+
+	switch operator {
+	case cqr.AND:
+		if len(terms) == 1 {
+			return terms[0]
+		}
+		return fmt.Sprintf("#band(%s)", strings.Join(terms, " "))
+	case cqr.OR:
+		if len(terms) == 1 {
+			return terms[0]
+		}
+		return fmt.Sprintf("#bor(%s)", strings.Join(terms, " "))
+	case cqr.NOT:
+		for i := 1; i < len(terms); i++ {
+			terms[i] = fmt.Sprintf("#not(%s)", terms[i])
+		}
+		return fmt.Sprintf("#band(%s)", strings.Join(terms, " "))
+	}
+	log.Printf("WARNING: unsupported operator %v, using AND instead\n", q.Operator)
+	return fmt.Sprintf("#band(%s)", strings.Join(terms, " "))
+}
+
+// Compile transforms an immediate representation of a query into an Indri query.
+func (b IndriBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
+	return IndriQuery{repr: b.compile(applyLimits(q))}, nil
+}
+
+// NewIndriBackend returns a new backend for compiling Indri queries. Fields are searched in index fields with the
+// same name, except that all fields are searched in the entire document. Exploded subject headings are expanded with
+// the default MeSH tree.
+func NewIndriBackend() IndriBackend {
+	tree, err := meshexp.Default()
+	if err != nil {
+		panic(err)
+	}
+	return IndriBackend{
+		Fields: map[string][]string{fields.AllFields: {}},
+		tree:   tree,
+	}
+}
@@ -0,0 +1,94 @@
+package backend
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"github.com/hscells/transmute/lexer"
+	"github.com/hscells/transmute/parser"
+	"testing"
+	"time"
+)
+
+func TestIndriBackend_Compile(t *testing.T) {
+	keyword := func(term string) ir.Keyword {
+		return ir.Keyword{QueryString: term, Fields: []string{fields.Title}}
+	}
+	tests := []struct {
+		name     string
+		query    ir.BooleanQuery
+		expected string
+	}{
+		{
+			name: "not",
+			query: ir.BooleanQuery{
+				Operator: cqr.NOT,
+				Keywords: []ir.Keyword{keyword("dementia")},
+				Children: []ir.BooleanQuery{{Operator: cqr.OR, Keywords: []ir.Keyword{keyword("mice"), keyword("rats")}}},
+			},
+			expected: "#band(dementia.title #not(#bor(mice.title rats.title)))",
+		},
+		{
+			name:     "adj3",
+			query:    ir.BooleanQuery{Operator: "adj3", Keywords: []ir.Keyword{keyword("memory"), keyword("loss")}},
+			expected: "#uw3(memory loss).title",
+		},
+		{
+			name: "ordered adj3",
+			query: ir.BooleanQuery{
+				Operator: "adj3",
+				Keywords: []ir.Keyword{keyword("memory"), keyword("loss")},
+				Options:  map[string]interface{}{ir.OrderedOption: true},
+			},
+			expected: "#od3(memory loss).title",
+		},
+		{
+			name: "date range",
+			query: ir.BooleanQuery{
+				Operator: cqr.AND,
+				Keywords: []ir.Keyword{
+					keyword("dementia"),
+					{Fields: []string{fields.PublicationDate}, Range: &ir.DateRange{
+						Start: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+						End:   time.Date(2010, 12, 31, 0, 0, 0, 0, time.UTC),
+					}},
+				},
+			},
+			expected: "#band(dementia.title #datebetween(01/01/2000 12/31/2010))",
+		},
+	}
+	b := IndriBackend{Fields: map[string][]string{fields.AllFields: {}}}
+	for _, test := range tests {
+		q, err := b.Compile(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := q.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestIndriBackend_PubMed(t *testing.T) {
+	ast, err := lexer.Lex("(Dementia[tiab] OR Amnesia[Mesh])", lexer.LexOptions{FormatParenthesis: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewIndriBackend().Compile(parser.NewPubMedParser().Parse(ast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := q.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the subject heading is exploded, even though the parser marks both keywords as exploded.
+	expected := "#bor(dementia.title_abstract #syn(amnesia.mesh_headings #1(alcoholic korsakoff syndrome).mesh_headings #1(amnesia anterograde).mesh_headings #1(amnesia retrograde).mesh_headings #1(amnesia transient global).mesh_headings))"
+	if got != expected {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
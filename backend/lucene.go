@@ -0,0 +1,265 @@
+package backend
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/meshexp"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// luceneSpecialCharacters must be escaped in terms of the classic Lucene query syntax.
+const luceneSpecialCharacters = `+-&|!(){}[]^"~*?:\/`
+
+// luceneDateFormat is the format of dates in Solr range queries.
+const luceneDateFormat = "2006-01-02T15:04:05Z"
+
+// LuceneBackend compiles queries into the classic Lucene query syntax, which is accepted as the `q` parameter of Solr
+// and by the QueryParser of Lucene (and Anserini), e.g. `+(title:dementia title:"memory loss"~3) -publication_type:review`.
+type LuceneBackend struct {
+	// Fields maps fields onto the fields of the index. Fields which are mapped onto no fields are searched in the
+	// default field, and fields which are not mapped are searched in a field with the same name.
+	Fields map[string][]string
+	tree   *meshexp.MeSHTree
+}
+
+// LuceneQuery is the transmute representation of a Lucene query.
+type LuceneQuery struct {
+	repr string
+}
+
+func (q LuceneQuery) Representation() (interface{}, error) {
+	return q.repr, nil
+}
+
+func (q LuceneQuery) String() (string, error) {
+	return q.repr, nil
+}
+
+func (q LuceneQuery) StringPretty() (string, error) {
+	return q.repr, nil
+}
+
+// escapeLucene escapes the special characters of a term. Wildcards are kept when the term is truncated, and the
+// truncation characters of other databases are written as Lucene wildcards.
+func escapeLucene(term string, truncated bool) string {
+	var b strings.Builder
+	for _, char := range term {
+		if truncated {
+			switch char {
+			case '*', '$':
+				b.WriteRune('*')
+				continue
+			case '?', '#':
+				b.WriteRune('?')
+				continue
+			}
+		}
+		if strings.ContainsRune(luceneSpecialCharacters, char) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(char)
+	}
+	escaped := b.String()
+	// Operators are only terms when they are quoted.
+	switch escaped {
+	case "AND", "OR", "NOT":
+		return fmt.Sprintf(`"%s"`, escaped)
+	}
+	return escaped
+}
+
+// escapeLucenePhrase escapes the characters of a phrase which are special inside quotes.
+func escapeLucenePhrase(phrase string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(phrase)
+}
+
+// luceneBoost formats the boost of a keyword or query, e.g. `^2`.
+func luceneBoost(options map[string]interface{}) string {
+	switch boost := options[ir.BoostOption].(type) {
+	case float64:
+		return "^" + strconv.FormatFloat(boost, 'f', -1, 64)
+	case int:
+		return "^" + strconv.Itoa(boost)
+	}
+	return ""
+}
+
+// indexFields maps fields onto the fields of the index.
+func (b LuceneBackend) indexFields(keywordFields []string) []string {
+	var indexFields []string
+	seen := make(map[string]bool)
+	for _, field := range keywordFields {
+		f, ok := b.Fields[field]
+		if !ok {
+			f = []string{field}
+		}
+		if len(f) == 0 {
+			// The default field is searched when there is no field prefix.
+			return nil
+		}
+		for _, indexField := range f {
+			if !seen[indexField] {
+				seen[indexField] = true
+				indexFields = append(indexFields, indexField)
+			}
+		}
+	}
+	return indexFields
+}
+
+// fielded writes a clause in each of the fields, e.g. `(title:dementia OR abstract:dementia)`.
+func (b LuceneBackend) fielded(keywordFields []string, clause string) string {
+	indexFields := b.indexFields(keywordFields)
+	if len(indexFields) == 0 {
+		return clause
+	}
+	clauses := make([]string, len(indexFields))
+	for i, field := range indexFields {
+		clauses[i] = fmt.Sprintf("%s:%s", field, clause)
+	}
+	if len(clauses) == 1 {
+		return clauses[0]
+	}
+	return fmt.Sprintf("(%s)", strings.Join(clauses, " OR "))
+}
+
+// term formats the query string of a keyword as a term, a phrase or a range of dates.
+func (b LuceneBackend) term(keyword ir.Keyword) string {
+	if keyword.Range != nil {
+		start, end := "*", "*"
+		if !keyword.Range.Start.IsZero() {
+			start = keyword.Range.Start.Format(luceneDateFormat)
+		}
+		if !keyword.Range.End.IsZero() {
+			end = keyword.Range.End.Add(24*time.Hour - time.Second).Format(luceneDateFormat)
+		}
+		return fmt.Sprintf("[%s TO %s]", start, end)
+	}
+
+	qs := strings.TrimSpace(keyword.QueryString)
+	if strings.HasPrefix(qs, `"`) || strings.ContainsAny(qs, " \t") {
+		if keyword.Truncated {
+			log.Printf("WARNING: phrases cannot be truncated, searching for %v without truncation\n", qs)
+		}
+		return fmt.Sprintf(`"%s"`, escapeLucenePhrase(strings.Trim(qs, `"`)))
+	}
+	return escapeLucene(qs, keyword.Truncated)
+}
+
+// keyword compiles a keyword into a clause. An exploded subject heading is searched along with the headings beneath
+// it, e.g. `(mesh_headings:Dementia OR mesh_headings:"Alzheimer Disease")`.
+func (b LuceneBackend) keyword(keyword ir.Keyword) string {
+	if !isExplodedHeading(keyword) {
+		return b.fielded(keyword.Fields, b.term(keyword)) + luceneBoost(keyword.Options)
+	}
+	headings := explodedHeadings(b.tree, keyword.QueryString)
+	clauses := []string{b.fielded(keyword.Fields, b.term(keyword))}
+	for _, heading := range headings[1:] {
+		clauses = append(clauses, b.fielded(keyword.Fields, b.term(ir.Keyword{QueryString: heading})))
+	}
+	if len(clauses) == 1 {
+		return clauses[0] + luceneBoost(keyword.Options)
+	}
+	return fmt.Sprintf("(%s)%s", strings.Join(clauses, " OR "), luceneBoost(keyword.Options))
+}
+
+// phrase compiles an adjacency query into a phrase with a slop, e.g. `"memory loss"~3`, or reports that the query
+// cannot be written as a phrase. Only queries of terms which are searched in the same fields can be.
+func (b LuceneBackend) phrase(q ir.BooleanQuery) (string, bool) {
+	if len(q.Children) > 0 || len(q.Keywords) == 0 {
+		return "", false
+	}
+	var terms []string
+	for _, keyword := range q.Keywords {
+		if keyword.Truncated || keyword.Range != nil || strings.Join(keyword.Fields, ",") != strings.Join(q.Keywords[0].Fields, ",") {
+			return "", false
+		}
+		for _, term := range strings.Fields(strings.Trim(keyword.QueryString, `"`)) {
+			terms = append(terms, escapeLucenePhrase(term))
+		}
+	}
+	phrase := fmt.Sprintf(`"%s"`, strings.Join(terms, " "))
+	if distance := strings.TrimPrefix(strings.ToLower(q.Operator), "adj"); len(distance) > 0 && distance != "0" {
+		phrase += "~" + distance
+	}
+	if ordered, ok := q.Options[ir.OrderedOption].(bool); ok && ordered {
+		log.Printf("WARNING: phrases with a slop match terms in any order, searching for %v in any order\n", phrase)
+	}
+	return b.fielded(q.Keywords[0].Fields, phrase), true
+}
+
+// compile compiles a query into a clause. Conjunctions are written with required (+) clauses, and the queries excluded
+// by a not query with prohibited (-) clauses.
+func (b LuceneBackend) compile(q ir.BooleanQuery) string {
+	if q.Keywords == nil && len(q.Operator) == 0 {
+		var clauses []string
+		for _, child := range q.Children {
+			clauses = append(clauses, b.compile(child))
+		}
+		return strings.Join(clauses, " ")
+	}
+
+	operator := strings.ToLower(q.Operator)
+	if strings.HasPrefix(operator, "adj") {
+		if phrase, ok := b.phrase(q); ok {
+			return phrase + luceneBoost(q.Options)
+		}
+		log.Printf("WARNING: could not write the %v query as a phrase, searching for all of its terms instead\n", q.Operator)
+		operator = cqr.AND
+	}
+
+	var clauses []string
+	for _, keyword := range q.Keywords {
+		clauses = append(clauses, b.keyword(keyword))
+	}
+	for _, child := range q.Children {
+		clauses = append(clauses, b.compile(child))
+	}
+	if len(clauses) == 1 && operator != cqr.NOT {
+		return clauses[0] + luceneBoost(q.Options)
+	}
+
+	switch operator {
+	case cqr.AND:
+		for i := range clauses {
+			clauses[i] = "+" + clauses[i]
+		}
+	case cqr.NOT:
+		clauses[0] = "+" + clauses[0]
+		for i := 1; i < len(clauses); i++ {
+			clauses[i] = "-" + clauses[i]
+		}
+	case cqr.OR:
+		return fmt.Sprintf("(%s)%s", strings.Join(clauses, " OR "), luceneBoost(q.Options))
+	default:
+		log.Printf("WARNING: unsupported operator %v, using AND instead\n", q.Operator)
+		for i := range clauses {
+			clauses[i] = "+" + clauses[i]
+		}
+	}
+	return fmt.Sprintf("(%s)%s", strings.Join(clauses, " "), luceneBoost(q.Options))
+}
+
+// Compile transforms an immediate representation of a query into a Lucene query.
+func (b LuceneBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
+	return LuceneQuery{repr: b.compile(applyLimits(q))}, nil
+}
+
+// NewLuceneBackend returns a new backend for compiling Lucene queries. Fields are searched in index fields with the
+// same name, except that all fields are searched in the default field. Exploded subject headings are expanded with the
+// default MeSH tree.
+func NewLuceneBackend() LuceneBackend {
+	tree, err := meshexp.Default()
+	if err != nil {
+		panic(err)
+	}
+	return LuceneBackend{
+		Fields: map[string][]string{fields.AllFields: {}},
+		tree:   tree,
+	}
+}
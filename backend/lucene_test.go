@@ -0,0 +1,105 @@
+package backend
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"testing"
+	"time"
+)
+
+func TestLuceneBackend_Compile(t *testing.T) {
+	title := func(term string) ir.Keyword {
+		return ir.Keyword{QueryString: term, Fields: []string{fields.Title}}
+	}
+	tests := []struct {
+		name     string
+		query    ir.BooleanQuery
+		expected string
+	}{
+		{
+			name:     "phrase",
+			query:    ir.BooleanQuery{Operator: "adj", Keywords: []ir.Keyword{title("memory"), title("loss")}},
+			expected: `title:"memory loss"`,
+		},
+		{
+			name:     "slop",
+			query:    ir.BooleanQuery{Operator: "adj3", Keywords: []ir.Keyword{title("memory"), title("loss")}},
+			expected: `title:"memory loss"~3`,
+		},
+		{
+			name: "not",
+			query: ir.BooleanQuery{
+				Operator: cqr.NOT,
+				Keywords: []ir.Keyword{title("dementia")},
+				Children: []ir.BooleanQuery{{Operator: cqr.OR, Keywords: []ir.Keyword{title("mice"), title("rats")}}},
+			},
+			expected: "(+title:dementia -(title:mice OR title:rats))",
+		},
+		{
+			name: "and",
+			query: ir.BooleanQuery{
+				Operator: cqr.AND,
+				Keywords: []ir.Keyword{title("dementia"), {QueryString: "memory loss", Fields: []string{fields.TitleAbstract}}},
+			},
+			expected: `(+title:dementia +title_abstract:"memory loss")`,
+		},
+		{
+			name: "date range",
+			query: ir.BooleanQuery{
+				Operator: cqr.AND,
+				Keywords: []ir.Keyword{title("dementia")},
+				Limits: []ir.Limit{{Field: fields.PublicationDate, Range: &ir.DateRange{
+					Start: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+					End:   time.Date(2010, 12, 31, 0, 0, 0, 0, time.UTC),
+				}}},
+			},
+			expected: "(+title:dementia +publication_date:[2000-01-01T00:00:00Z TO 2010-12-31T23:59:59Z])",
+		},
+		{
+			name: "boost",
+			query: ir.BooleanQuery{
+				Operator: cqr.OR,
+				Keywords: []ir.Keyword{
+					{QueryString: "dementia", Fields: []string{fields.Title}, Options: map[string]interface{}{ir.BoostOption: 2}},
+					title("memory"),
+				},
+				Options: map[string]interface{}{ir.BoostOption: 0.5},
+			},
+			expected: "(title:dementia^2 OR title:memory)^0.5",
+		},
+		{
+			name: "escaping",
+			query: ir.BooleanQuery{
+				Operator: cqr.OR,
+				Keywords: []ir.Keyword{title("covid-19"), title("AND"), {QueryString: "alzheimer$", Fields: []string{fields.Title}, Truncated: true}},
+			},
+			expected: `(title:covid\-19 OR title:"AND" OR title:alzheimer*)`,
+		},
+		{
+			// The PubMed parser marks free text keywords as exploded, which are not subject headings.
+			name:     "exploded free text",
+			query:    ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{{QueryString: "Dementia", Fields: []string{fields.TitleAbstract}, Exploded: true}}},
+			expected: "title_abstract:Dementia",
+		},
+		{
+			name:     "exploded heading",
+			query:    ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{{QueryString: "Amnesia", Fields: []string{fields.MeshHeadings}, Exploded: true}}},
+			expected: `(mesh_headings:Amnesia OR mesh_headings:"Alcoholic Korsakoff Syndrome" OR mesh_headings:"Amnesia, Anterograde" OR mesh_headings:"Amnesia, Retrograde" OR mesh_headings:"Amnesia, Transient Global")`,
+		},
+	}
+	b := NewLuceneBackend()
+	for _, test := range tests {
+		q, err := b.Compile(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := q.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
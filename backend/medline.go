@@ -2,15 +2,24 @@ package backend
 
 import (
 	"fmt"
+	"github.com/hscells/cqr"
 	"github.com/hscells/transmute/fields"
 	"github.com/hscells/transmute/ir"
-	"github.com/xtgo/set"
-	"log"
-	"sort"
 	"strconv"
 	"strings"
 )
 
+// medlineEarliestYear is the start of a range of years which has no start, since Ovid requires both bounds of a range.
+const medlineEarliestYear = "1000"
+
+// medlineLimitSpecies maps the subject headings of species onto how they are written in a limit line.
+var medlineLimitSpecies = map[string]string{
+	"humans":  "humans",
+	"human":   "humans",
+	"animals": "animals",
+	"animal":  "animals",
+}
+
 type MedlineBackend struct {
 }
 
@@ -30,117 +39,88 @@ func (m MedlineQuery) StringPretty() (string, error) {
 	return m.repr, nil
 }
 
-func compileMedline(q ir.BooleanQuery, level int) (l int, query MedlineQuery) {
-	repr := ""
-	var op []int
-	if q.Keywords == nil && len(q.Operator) == 0 {
-		for _, child := range q.Children {
-			var comp MedlineQuery
-			level, comp = compileMedline(child, level)
-			repr += comp.repr
-		}
-		return level, MedlineQuery{repr: repr}
-	}
-	for _, child := range q.Children {
-		l, comp := compileMedline(child, level)
-		repr += comp.repr
-		level = l
-		op = append(op, l-1)
+// hoistMedlineDateRanges moves the publication date ranges of an and query into the limits of the query, since
+// Ovid searches dates by limiting a line.
+func hoistMedlineDateRanges(q ir.BooleanQuery) ir.BooleanQuery {
+	if q.Operator != cqr.AND {
+		return q
 	}
+	var keywords []ir.Keyword
+	var limits []ir.Limit
 	for _, keyword := range q.Keywords {
-		var mf string
-		qs := keyword.QueryString
-		if len(keyword.Fields) == 1 && keyword.Fields[0] == fields.MeshHeadings {
-			if keyword.Exploded {
-				qs = "exp " + qs
-			}
-			qs += "/"
-		} else {
-			m := map[string][]string{
-				"ti,ab,sh": {fields.AllFields},
-				"ti,ab":    {fields.TitleAbstract},
-				"ab":       {fields.Abstract},
-				"ai":       {fields.AuthorFull},
-				"as":       {fields.PublicationDate},
-				"au":       {fields.Authors},
-				"ax":       {fields.AuthorLast},
-				"ba":       {fields.Authors},
-				"bd":       {fields.PublicationDate},
-				"be":       {fields.Editor},
-				"bf":       {fields.Authors},
-				"em":       {fields.PublicationDate},
-				"ed":       {fields.PublicationDate},
-				"fa":       {fields.AuthorFull},
-				"fe":       {fields.Editor},
-				"fs":       {fields.FloatingMeshHeadings},
-				"fx":       {fields.FloatingMeshHeadings},
-				"ot":       {fields.Title},
-				"mh":       {fields.MeshHeadings},
-				"px":       {fields.MeshHeadings},
-				"pt":       {fields.PublicationType},
-				"rs":       {fields.AllFields},
-				"rn":       {fields.AllFields},
-				"sb":       {fields.PublicationType},
-				"sh":       {fields.MeSHSubheading},
-				"tw":       {fields.TextWord},
-				"ti":       {fields.Title},
-				"ja":       {fields.Journal},
-				"jn":       {fields.Journal},
-				"jw":       {fields.Journal},
-			}
-			sort.Strings(keyword.Fields)
-			keyword.Fields = set.Strings(keyword.Fields)
-			for f, mappingFields := range m {
-				if len(mappingFields) != len(keyword.Fields) {
-					continue
-				}
-				for _, field := range keyword.Fields {
-					for _, f2 := range mappingFields {
-						if field == f2 || field == f {
-							mf = f
-							break
-						}
-					}
-				}
-			}
-			if len(mf) == 0 {
-				log.Println("WARNING: could not map fields: ", keyword)
-			}
-			qs = fmt.Sprintf("%v.%v.", qs, mf)
+		if keyword.Range != nil && len(keyword.Fields) == 1 && keyword.Fields[0] == fields.PublicationDate {
+			limits = append(limits, ir.Limit{Field: keyword.Fields[0], Range: keyword.Range})
+			continue
 		}
-		repr += fmt.Sprintf("%v. %v\n", level, qs)
-		op = append(op, level)
-		level += 1
+		keywords = append(keywords, keyword)
 	}
-	if len(op) > 0 {
-		// This block of code determines if we can use the short hand version of grouping for medline e.g. or/1-9
-		o := op[0]
-		asc := true
-		for i := 1; i < len(op); i++ {
-			if op[i]-1 != o {
-				asc = false
-				break
-			}
-			o = op[i]
+	// There must be something left to limit.
+	if len(limits) == 0 || len(keywords)+len(q.Children) == 0 {
+		return q
+	}
+	q.Keywords = keywords
+	q.Limits = append(limits, q.Limits...)
+	return q
+}
+
+// compileMedlineLimit writes the restriction of a limit line, e.g. `yr="2000-2010"` or `english language`. Species
+// are written as they are in the limits of the database.
+func compileMedlineLimit(limit ir.Limit, limitSpecies map[string]string) (string, bool) {
+	if limit.Range != nil {
+		if limit.Field != fields.PublicationDate {
+			return "", false
 		}
-		if asc && len(op) > 2 {
-			repr += fmt.Sprintf("%d. %s/%d-%d\n", level, q.Operator, op[0], op[len(op)-1])
-		} else {
-			// Otherwise we need to use the long form version.
-			ops := make([]string, len(op))
-			for i, o := range op {
-				ops[i] = strconv.Itoa(o)
+		return fmt.Sprintf(`yr="%v"`, compileMedlineYears(*limit.Range)), true
+	}
+
+	values := make([]string, len(limit.Values))
+	for i, value := range limit.Values {
+		switch limit.Field {
+		case fields.Language:
+			values[i] = strings.ToLower(value) + " language"
+		case fields.PublicationType:
+			values[i] = strings.ToLower(value)
+		case fields.MeshHeadings, fields.EmtreeHeadings, fields.SubjectHeadings:
+			// Only species can be limited using subject headings.
+			species, ok := limitSpecies[strings.ToLower(value)]
+			if !ok {
+				return "", false
 			}
-			repr += fmt.Sprintf("%v. %v\n", level, strings.Join(ops, fmt.Sprintf(" %v ", q.Operator)))
+			values[i] = species
+		default:
+			return "", false
 		}
 	}
-	level += 1
-	return level, MedlineQuery{repr: repr}
+
+	switch len(values) {
+	case 0:
+		return "", false
+	case 1:
+		return values[0], true
+	}
+	return fmt.Sprintf("(%v)", strings.Join(values, " or ")), true
+}
+
+// compileMedlineYears writes a date range as a range of years, e.g. `2000-2010` or `2000-Current`. Ovid limits
+// publication dates by year, so the months and days of the range are lost.
+func compileMedlineYears(r ir.DateRange) string {
+	start, end := medlineEarliestYear, "Current"
+	if !r.Start.IsZero() {
+		start = strconv.Itoa(r.Start.Year())
+	}
+	if !r.End.IsZero() {
+		end = strconv.Itoa(r.End.Year())
+	}
+	if start == end {
+		return start
+	}
+	return fmt.Sprintf("%v-%v", start, end)
 }
 
+// Compile transforms an immediate representation of a query into Ovid MEDLINE search lines. It is the same as an
+// OvidBackend with the OvidMedline profile.
 func (b MedlineBackend) Compile(ir ir.BooleanQuery) (BooleanQuery, error) {
-	_, q := compileMedline(ir, 1)
-	return q, nil
+	return NewOvidBackend(OvidMedline).Compile(ir)
 }
 
 func NewMedlineBackend() MedlineBackend {
@@ -0,0 +1,92 @@
+package backend
+
+import (
+	"fmt"
+	"github.com/hscells/meshexp"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"sort"
+	"strings"
+)
+
+// subjectHeadingFields are the fields which contain controlled vocabulary subject headings, such as MeSH or Emtree.
+var subjectHeadingFields = map[string]bool{
+	fields.MeshHeadings:            true,
+	fields.MajorFocusMeshHeading:   true,
+	fields.EmtreeHeadings:          true,
+	fields.MajorFocusEmtreeHeading: true,
+	fields.CINAHLHeadings:          true,
+	fields.MajorFocusCINAHLHeading: true,
+	fields.SubjectHeadings:         true,
+}
+
+// majorFocusFields are the subject heading fields which contain only the headings that are the major focus of an
+// article (i.e. the starred headings of Ovid).
+var majorFocusFields = map[string]bool{
+	fields.MajorFocusMeshHeading:   true,
+	fields.MajorFocusEmtreeHeading: true,
+	fields.MajorFocusCINAHLHeading: true,
+}
+
+// keywordSubheadings returns the subheadings a subject heading keyword is restricted to. The option may have been
+// decoded from JSON, in which case it is a slice of interfaces.
+func keywordSubheadings(keyword ir.Keyword) []string {
+	switch subheadings := keyword.Options[ir.SubheadingsOption].(type) {
+	case []string:
+		return subheadings
+	case []interface{}:
+		s := make([]string, len(subheadings))
+		for i, subheading := range subheadings {
+			s[i] = fmt.Sprintf("%v", subheading)
+		}
+		return s
+	}
+	return nil
+}
+
+// subheadingName returns the name of a subheading from its abbreviation, or the subheading itself when it is not an
+// abbreviation.
+func subheadingName(subheading string) string {
+	if name, ok := ir.MeSHSubheadings[subheading]; ok {
+		return name
+	}
+	return subheading
+}
+
+// isExplodedHeading reports whether a keyword is an exploded subject heading, which is only the case when each of its
+// fields is a subject heading field. Some parsers (e.g. PubMed) mark free text keywords as exploded, and these must not
+// be expanded into subject headings.
+func isExplodedHeading(keyword ir.Keyword) bool {
+	if !keyword.Exploded || keyword.Range != nil || len(keyword.Fields) == 0 {
+		return false
+	}
+	for _, field := range keyword.Fields {
+		if !subjectHeadingFields[field] {
+			return false
+		}
+	}
+	return true
+}
+
+// explodedHeadings returns an exploded subject heading followed by the headings beneath it in the MeSH tree, in
+// alphabetical order. Headings appear beneath the exploded heading once for each of their tree numbers, so each is only
+// returned once. Without a tree, only the heading itself is returned.
+func explodedHeadings(tree *meshexp.MeSHTree, heading string) []string {
+	heading = strings.Trim(heading, `"`)
+	if tree == nil {
+		log.Printf("WARNING: subject headings cannot be exploded without a MeSH tree, searching for %v only\n", heading)
+		return []string{heading}
+	}
+	headings := []string{heading}
+	seen := map[string]bool{heading: true}
+	for _, h := range tree.Explode(heading) {
+		if !seen[h] {
+			seen[h] = true
+			headings = append(headings, h)
+		}
+	}
+	// The tree does not return the headings in a consistent order.
+	sort.Strings(headings[1:])
+	return headings
+}
@@ -0,0 +1,383 @@
+package backend
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"github.com/xtgo/set"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OvidFieldCode is a field code of a database on the Ovid platform, and the fields it searches.
+type OvidFieldCode struct {
+	Code   string
+	Fields []string
+}
+
+// OvidProfile describes how queries are written for a database on the Ovid platform. Each database has its own field
+// codes and its own thesaurus of subject headings.
+type OvidProfile struct {
+	// Name of the database, e.g. `MEDLINE`.
+	Name string
+	// FieldCodes are the field codes of the database. When several codes search the same fields, the first is used.
+	FieldCodes []OvidFieldCode
+	// DefaultCode is used for keywords whose fields cannot be mapped onto any field code.
+	DefaultCode string
+	// Vocabulary is the subject heading field of the thesaurus of the database, and MajorVocabulary is the field of
+	// the headings which are the major focus of an article. Databases without major headings leave it empty.
+	Vocabulary      string
+	MajorVocabulary string
+	// LowerCaseHeadings is set when the headings of the thesaurus are written in lower case (e.g. `exp dementia/`).
+	LowerCaseHeadings bool
+	// Subheadings is set when headings may be restricted to subheadings (e.g. `Dementia/dt`).
+	Subheadings bool
+	// Species maps the subject headings of species onto how they are written in a limit line.
+	Species map[string]string
+}
+
+var (
+	// OvidMedline is the profile of Ovid MEDLINE, which is indexed with MeSH.
+	OvidMedline = OvidProfile{
+		Name: "MEDLINE",
+		FieldCodes: []OvidFieldCode{
+			{"ti,ab,sh", []string{fields.AllFields}},
+			{"ti,ab", []string{fields.TitleAbstract}},
+			{"ti", []string{fields.Title}},
+			{"ab", []string{fields.Abstract}},
+			{"tw", []string{fields.TextWord}},
+			{"kw", []string{fields.Keywords}},
+			{"mp", fields.MedlineMultiPurpose},
+			{"mh", []string{fields.MeshHeadings}},
+			{"sh", []string{fields.MeSHSubheading}},
+			{"fs", []string{fields.FloatingMeshHeadings}},
+			{"au", []string{fields.Authors}},
+			{"fa", []string{fields.AuthorFull}},
+			{"ax", []string{fields.AuthorLast}},
+			{"fe", []string{fields.Editor}},
+			{"jn", []string{fields.Journal}},
+			{"la", []string{fields.Language}},
+			{"pt", []string{fields.PublicationType}},
+			{"yr", []string{fields.PublicationDate}},
+			{"ui", []string{fields.PMID}},
+			// Codes which search the same fields as the codes above. They are only used for keywords whose fields are
+			// the codes themselves, e.g. `ai`.
+			{"ai", []string{fields.AuthorFull}},
+			{"as", []string{fields.PublicationDate}},
+			{"ba", []string{fields.Authors}},
+			{"bd", []string{fields.PublicationDate}},
+			{"be", []string{fields.Editor}},
+			{"bf", []string{fields.Authors}},
+			{"ed", []string{fields.PublicationDate}},
+			{"em", []string{fields.PublicationDate}},
+			{"fx", []string{fields.FloatingMeshHeadings}},
+			{"ja", []string{fields.Journal}},
+			{"jw", []string{fields.Journal}},
+			{"ot", []string{fields.Title}},
+			{"px", []string{fields.MeshHeadings}},
+			{"rn", []string{fields.AllFields}},
+			{"rs", []string{fields.AllFields}},
+			{"sb", []string{fields.PublicationType}},
+		},
+		DefaultCode:     "mp",
+		Vocabulary:      fields.MeshHeadings,
+		MajorVocabulary: fields.MajorFocusMeshHeading,
+		Subheadings:     true,
+		Species:         medlineLimitSpecies,
+	}
+
+	// OvidEmbase is the profile of Ovid Embase, which is indexed with Emtree. Text words (`.tw.`) are searched in the
+	// title and abstract, and candidate terms (`.dq.`) are the terms proposed for Emtree.
+	OvidEmbase = OvidProfile{
+		Name: "Embase",
+		FieldCodes: []OvidFieldCode{
+			{"af", []string{fields.AllFields}},
+			{"tw", []string{fields.TitleAbstract}},
+			{"tw", []string{fields.TextWord}},
+			{"tw,kw", []string{fields.TitleAbstract, fields.Keywords}},
+			{"ti", []string{fields.Title}},
+			{"ab", []string{fields.Abstract}},
+			{"kw", []string{fields.Keywords}},
+			{"dq", []string{fields.CandidateTerm}},
+			{"mp", fields.EmbaseMultiPurpose},
+			{"sh", []string{fields.EmtreeHeadings}},
+			{"mj", []string{fields.MajorFocusEmtreeHeading}},
+			{"tn", []string{fields.DrugTradeName}},
+			{"mn", []string{fields.DrugManufacturer}},
+			{"dv", []string{fields.DeviceTradeName}},
+			{"dm", []string{fields.DeviceManufacturer}},
+			{"ot", []string{fields.TransliteratedTitle}},
+			{"au", []string{fields.Authors}},
+			{"ca", []string{fields.AuthorCorporate}},
+			{"in", []string{fields.Affiliation}},
+			{"jn", []string{fields.Journal}},
+			{"la", []string{fields.Language}},
+			{"pt", []string{fields.PublicationType}},
+			{"yr", []string{fields.PublicationDate}},
+			{"ui", []string{fields.PMID}},
+		},
+		DefaultCode:       "mp",
+		Vocabulary:        fields.EmtreeHeadings,
+		MajorVocabulary:   fields.MajorFocusEmtreeHeading,
+		LowerCaseHeadings: true,
+		Subheadings:       true,
+		Species: map[string]string{
+			"humans":  "human",
+			"human":   "human",
+			"animals": "animal",
+			"animal":  "animal",
+		},
+	}
+
+	// OvidPsycINFO is the profile of Ovid PsycINFO, which is indexed with the APA Thesaurus of Psychological Index
+	// Terms. The thesaurus has no subheadings.
+	OvidPsycINFO = OvidProfile{
+		Name: "PsycINFO",
+		FieldCodes: []OvidFieldCode{
+			{"af", []string{fields.AllFields}},
+			{"ti,ab", []string{fields.TitleAbstract}},
+			{"ti", []string{fields.Title}},
+			{"ab", []string{fields.Abstract}},
+			{"tw", []string{fields.TextWord}},
+			{"id", []string{fields.Keywords}},
+			{"sh", []string{fields.SubjectHeadings}},
+			{"au", []string{fields.Authors}},
+			{"in", []string{fields.Affiliation}},
+			{"jn", []string{fields.Journal}},
+			{"la", []string{fields.Language}},
+			{"pt", []string{fields.PublicationType}},
+			{"yr", []string{fields.PublicationDate}},
+		},
+		DefaultCode: "mp",
+		Vocabulary:  fields.SubjectHeadings,
+		Species: map[string]string{
+			"humans":  "human",
+			"human":   "human",
+			"animals": "animal",
+			"animal":  "animal",
+		},
+	}
+)
+
+// OvidBackend compiles queries into the numbered search lines of a database on the Ovid platform.
+type OvidBackend struct {
+	Profile OvidProfile
+}
+
+// fieldCode maps the fields of a keyword onto a field code. Fields which are not searched by a single code are
+// searched by a combination of codes (e.g. `.ti,ab,kw.`). Fields which are field codes of the database (e.g. `ai`)
+// are searched by those codes.
+func (p OvidProfile) fieldCode(keywordFields []string) (string, bool) {
+	key := func(f []string) string {
+		// The fields are copied before sorting, as they may be shared with a field mapping.
+		sorted := make([]string, len(f))
+		copy(sorted, f)
+		sort.Strings(sorted)
+		return strings.Join(sorted[:set.Uniq(sort.StringSlice(sorted))], ",")
+	}
+
+	k := key(keywordFields)
+	for _, fc := range p.FieldCodes {
+		if key(fc.Fields) == k {
+			return fc.Code, true
+		}
+	}
+
+	var codes []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(k, ",") {
+		code, ok := "", false
+		for _, fc := range p.FieldCodes {
+			// A field may also be a field code of the database, e.g. `ai`.
+			if (len(fc.Fields) == 1 && fc.Fields[0] == field) || fc.Code == field {
+				code, ok = fc.Code, true
+				break
+			}
+		}
+		if !ok {
+			return "", false
+		}
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	return strings.Join(codes, ","), len(codes) > 0
+}
+
+// heading writes a subject heading keyword in the notation of the thesaurus of the database, e.g.
+// `exp *Dementia/dt, th`. Headings of other thesauri are written as headings of this one, as Ovid maps many of them
+// onto the same heading, but they should be checked.
+func (p OvidProfile) heading(keyword ir.Keyword) string {
+	field := keyword.Fields[0]
+	qs := strings.Trim(keyword.QueryString, `"`)
+	if field != p.Vocabulary && field != p.MajorVocabulary && field != fields.SubjectHeadings {
+		log.Printf("WARNING: writing the %v heading %v as a heading of %v\n", field, qs, p.Name)
+	}
+	if p.LowerCaseHeadings {
+		qs = strings.ToLower(qs)
+	}
+	if majorFocusFields[field] {
+		if len(p.MajorVocabulary) > 0 {
+			qs = "*" + qs
+		} else {
+			log.Printf("WARNING: %v does not have major headings, searching all of %v instead\n", p.Name, qs)
+		}
+	}
+	if keyword.Exploded {
+		qs = "exp " + qs
+	}
+	subheadings := keywordSubheadings(keyword)
+	if len(subheadings) > 0 && !p.Subheadings {
+		log.Printf("WARNING: %v does not have subheadings, ignoring the subheadings of %v\n", p.Name, qs)
+		subheadings = nil
+	}
+	return qs + "/" + strings.Join(subheadings, ", ")
+}
+
+// ovidProximityLine writes a proximity query on a single line, e.g. `(memory adj3 loss).ti,ab.`, or reports that the
+// query cannot be written on one line. Only queries of keywords searched in the same fields can be.
+func ovidProximityLine(q ir.BooleanQuery, profile OvidProfile) (string, bool) {
+	if !strings.HasPrefix(strings.ToLower(q.Operator), "adj") {
+		return "", false
+	}
+	f, ok := sharedFields(q)
+	if !ok || hasDateRange(q) {
+		log.Printf("WARNING: could not write the %v query on a single line, combining its lines with %v instead\n", q.Operator, q.Operator)
+		return "", false
+	}
+	code, ok := profile.fieldCode(f)
+	if !ok {
+		log.Printf("WARNING: could not map the fields %v, searching %v instead\n", f, profile.DefaultCode)
+		code = profile.DefaultCode
+	}
+	return fmt.Sprintf("(%s).%s.", ovidExpression(q), code), true
+}
+
+// ovidExpression writes a query as an expression of its keywords, without their fields.
+func ovidExpression(q ir.BooleanQuery) string {
+	var terms []string
+	for _, keyword := range q.Keywords {
+		terms = append(terms, keyword.QueryString)
+	}
+	for _, child := range q.Children {
+		terms = append(terms, fmt.Sprintf("(%s)", ovidExpression(child)))
+	}
+	return strings.Join(terms, fmt.Sprintf(" %s ", strings.ToLower(q.Operator)))
+}
+
+// compileOvid compiles a query into numbered search lines, starting at the line level. The next line number is
+// returned along with the lines.
+func compileOvid(q ir.BooleanQuery, level int, profile OvidProfile) (l int, query MedlineQuery) {
+	repr := ""
+	var op []int
+	if q.Keywords == nil && len(q.Operator) == 0 {
+		for _, child := range q.Children {
+			var comp MedlineQuery
+			level, comp = compileOvid(child, level, profile)
+			repr += comp.repr
+		}
+		return level, MedlineQuery{repr: repr}
+	}
+	q = hoistMedlineDateRanges(q)
+	if line, ok := ovidProximityLine(q, profile); ok {
+		// Ovid cannot combine search lines with proximity operators, so the query is searched on a line of its own.
+		repr += fmt.Sprintf("%v. %v\n", level, line)
+		op = append(op, level)
+		level += 1
+	} else {
+		for _, keyword := range q.Keywords {
+			qs := keyword.QueryString
+			if keyword.Range != nil {
+				// Date ranges which could not be written as a limit are searched in the year field instead.
+				log.Printf("WARNING: searching for the date range %v in the year field\n", keyword.Range)
+				qs = fmt.Sprintf(`"%v".yr.`, compileMedlineYears(*keyword.Range))
+			} else if len(keyword.Fields) == 1 && subjectHeadingFields[keyword.Fields[0]] {
+				qs = profile.heading(keyword)
+			} else {
+				code, ok := profile.fieldCode(keyword.Fields)
+				if !ok {
+					log.Printf("WARNING: could not map the fields %v, searching %v instead\n", keyword.Fields, profile.DefaultCode)
+					code = profile.DefaultCode
+				}
+				qs = fmt.Sprintf("%v.%v.", qs, code)
+				if frequency := keywordFrequency(keyword); frequency > 0 {
+					qs += fmt.Sprintf("/freq=%d", frequency)
+				}
+			}
+			repr += fmt.Sprintf("%v. %v\n", level, qs)
+			op = append(op, level)
+			level += 1
+		}
+		// The keywords are the first operands of the query, so they are written first (see ir.BooleanQuery).
+		for _, child := range q.Children {
+			l, comp := compileOvid(child, level, profile)
+			repr += comp.repr
+			level = l
+			op = append(op, l-1)
+		}
+	}
+	target := level
+	if len(op) == 1 {
+		// A single line does not need to be grouped.
+		target = op[0]
+	} else {
+		if len(op) > 0 {
+			// This block of code determines if we can use the short hand version of grouping for medline e.g. or/1-9,
+			// which Ovid only has for the and and or operators.
+			operator := strings.ToLower(q.Operator)
+			o := op[0]
+			asc := true
+			for i := 1; i < len(op); i++ {
+				if op[i]-1 != o {
+					asc = false
+					break
+				}
+				o = op[i]
+			}
+			if asc && len(op) > 2 && (operator == cqr.AND || operator == cqr.OR) {
+				repr += fmt.Sprintf("%d. %s/%d-%d\n", level, operator, op[0], op[len(op)-1])
+			} else {
+				// Otherwise we need to use the long form version.
+				ops := make([]string, len(op))
+				for i, o := range op {
+					ops[i] = strconv.Itoa(o)
+				}
+				repr += fmt.Sprintf("%v. %v\n", level, strings.Join(ops, fmt.Sprintf(" %v ", q.Operator)))
+			}
+		}
+		level += 1
+	}
+	var restrictions []string
+	for _, limit := range q.Limits {
+		restriction, ok := compileMedlineLimit(limit, profile.Species)
+		if !ok {
+			log.Println("WARNING: could not write limit: ", limit)
+			continue
+		}
+		restrictions = append(restrictions, restriction)
+	}
+	if len(restrictions) == 1 {
+		repr += fmt.Sprintf("%v. limit %v to %v\n", level, target, restrictions[0])
+		level += 1
+	} else if len(restrictions) > 1 {
+		repr += fmt.Sprintf("%v. limit %v to (%v)\n", level, target, strings.Join(restrictions, " and "))
+		level += 1
+	}
+	return level, MedlineQuery{repr: repr}
+}
+
+// Compile transforms an immediate representation of a query into the search lines of the database of the profile.
+func (b OvidBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
+	_, query := compileOvid(q, 1, b.Profile)
+	return query, nil
+}
+
+// NewOvidBackend returns a new backend for compiling queries for the database of an Ovid profile, e.g.
+// `NewOvidBackend(OvidEmbase)`.
+func NewOvidBackend(profile OvidProfile) OvidBackend {
+	return OvidBackend{Profile: profile}
+}
@@ -0,0 +1,100 @@
+package backend
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"strings"
+	"testing"
+)
+
+// ovidNotQuery is `exp Dementia/ not ((memory adj3 loss).ti,ab. or Animals/)`, where the excluded operands are the
+// children of the query.
+var ovidNotQuery = ir.BooleanQuery{
+	Operator: cqr.NOT,
+	Keywords: []ir.Keyword{{QueryString: "Dementia", Fields: []string{fields.MeshHeadings}, Exploded: true}},
+	Children: []ir.BooleanQuery{
+		{
+			Operator: "adj3",
+			Keywords: []ir.Keyword{
+				{QueryString: "memory", Fields: []string{fields.TitleAbstract}},
+				{QueryString: "loss", Fields: []string{fields.TitleAbstract}},
+			},
+		},
+		{
+			Operator: cqr.OR,
+			Keywords: []ir.Keyword{{QueryString: "Animals", Fields: []string{fields.MeshHeadings}}},
+		},
+	},
+}
+
+func TestOvidBackend_Profiles(t *testing.T) {
+	tests := []struct {
+		profile  OvidProfile
+		expected string
+	}{
+		{OvidMedline, "1. exp Dementia/\n2. (memory adj3 loss).ti,ab.\n3. Animals/\n4. 1 not 2 not 3\n"},
+		{OvidEmbase, "1. exp dementia/\n2. (memory adj3 loss).tw.\n3. animals/\n4. 1 not 2 not 3\n"},
+		{OvidPsycINFO, "1. exp Dementia/\n2. (memory adj3 loss).ti,ab.\n3. Animals/\n4. 1 not 2 not 3\n"},
+	}
+	for _, test := range tests {
+		q, err := NewOvidBackend(test.profile).Compile(ovidNotQuery)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := q.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expected {
+			t.Errorf("%v: expected\n%v\ngot\n%v", test.profile.Name, test.expected, got)
+		}
+	}
+}
+
+func TestOvidBackend_Shorthand(t *testing.T) {
+	keywords := []ir.Keyword{
+		{QueryString: "dementia", Fields: []string{fields.Title}},
+		{QueryString: "alzheimer*", Fields: []string{fields.Title}},
+		{QueryString: "memory", Fields: []string{fields.Title}},
+	}
+	tests := []struct {
+		operator string
+		expected string
+	}{
+		{cqr.OR, "1. dementia.ti.\n2. alzheimer*.ti.\n3. memory.ti.\n4. or/1-3\n"},
+		{cqr.AND, "1. dementia.ti.\n2. alzheimer*.ti.\n3. memory.ti.\n4. and/1-3\n"},
+		{cqr.NOT, "1. dementia.ti.\n2. alzheimer*.ti.\n3. memory.ti.\n4. 1 not 2 not 3\n"},
+	}
+	for _, test := range tests {
+		q, err := NewOvidBackend(OvidMedline).Compile(ir.BooleanQuery{Operator: test.operator, Keywords: keywords})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := q.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expected {
+			t.Errorf("%v: expected\n%v\ngot\n%v", test.operator, test.expected, got)
+		}
+	}
+}
+
+func TestOvidBackend_FieldCodes(t *testing.T) {
+	q := ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{
+		{QueryString: "smith", Fields: []string{fields.AuthorFull}},
+		{QueryString: "jones", Fields: []string{"ai"}},
+		{QueryString: "2005", Fields: []string{"bd"}},
+		{QueryString: "dementia", Fields: []string{"unknown"}},
+	}}
+
+	got, logged := compileWithLog(t, NewOvidBackend(OvidMedline), q)
+	expected := "1. smith.fa.\n2. jones.ai.\n3. 2005.bd.\n4. dementia.mp.\n5. or/1-4\n"
+	if got != expected {
+		t.Errorf("expected\n%v\ngot\n%v", expected, got)
+	}
+	if !strings.Contains(logged, "could not map the fields [unknown], searching mp instead") {
+		t.Errorf("expected a warning about the unmapped fields, got %v", logged)
+	}
+}
@@ -0,0 +1,234 @@
+package backend
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/meshexp"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// PostgresWeights maps fields onto the weights of the lexemes of a tsvector of PubMed records, e.g. one built with
+// `setweight(to_tsvector(title), 'A') || setweight(to_tsvector(abstract), 'B')`. Fields which are mapped onto no
+// weights are searched in lexemes of any weight.
+var PostgresWeights = map[string]string{
+	fields.Title:                 "A",
+	fields.Abstract:              "B",
+	fields.TitleAbstract:         "AB",
+	fields.TextWord:              "AB",
+	fields.Keywords:              "C",
+	fields.MeshHeadings:          "C",
+	fields.MajorFocusMeshHeading: "C",
+	fields.SubjectHeadings:       "C",
+	fields.AllFields:             "",
+}
+
+// PostgresBackend compiles queries into PostgreSQL tsquery strings, which are the argument of `to_tsquery`, e.g.
+// `'memory':AB <3> 'loss':AB & !'review'`.
+type PostgresBackend struct {
+	// Weights maps fields onto the weights of the lexemes which they are searched in.
+	Weights map[string]string
+	tree    *meshexp.MeSHTree
+}
+
+// PostgresQuery is the transmute representation of a PostgreSQL tsquery.
+type PostgresQuery struct {
+	repr string
+}
+
+func (q PostgresQuery) Representation() (interface{}, error) {
+	return q.repr, nil
+}
+
+func (q PostgresQuery) String() (string, error) {
+	return q.repr, nil
+}
+
+func (q PostgresQuery) StringPretty() (string, error) {
+	return q.repr, nil
+}
+
+// weights maps fields onto the weights of lexemes. Fields which cannot be mapped are searched in lexemes of any weight.
+func (b PostgresBackend) weights(keywordFields []string) string {
+	var weights string
+	for _, field := range keywordFields {
+		w, ok := b.Weights[field]
+		if !ok {
+			log.Printf("WARNING: could not map the field %v, searching lexemes of any weight instead\n", field)
+			return ""
+		}
+		if len(w) == 0 {
+			return ""
+		}
+		for _, weight := range strings.ToUpper(w) {
+			if !strings.ContainsRune(weights, weight) {
+				weights += string(weight)
+			}
+		}
+	}
+	return weights
+}
+
+// keyword compiles a keyword into lexemes, which are quoted so that punctuation is searched as text. The words of a
+// phrase are followed by each other (<->), and truncated keywords are written as prefixes, e.g. `'alzheimer':*AB`.
+func (b PostgresBackend) keyword(keyword ir.Keyword) string {
+	qs := strings.Trim(strings.TrimSpace(keyword.QueryString), `"`)
+	prefix := ""
+	if keyword.Truncated {
+		if i := strings.IndexAny(qs, "*$#?"); i >= 0 {
+			if i < len(qs)-1 {
+				log.Printf("WARNING: tsquery only supports prefix matching, searching for %v as the prefix %v\n", qs, qs[:i])
+			}
+			qs = qs[:i]
+		}
+		prefix = "*"
+	}
+	weights := b.weights(keyword.Fields)
+
+	words := strings.Fields(qs)
+	lexemes := make([]string, len(words))
+	for i, word := range words {
+		label := weights
+		if i == len(words)-1 {
+			label = prefix + weights
+		}
+		lexemes[i] = fmt.Sprintf("'%s'", strings.Replace(word, `'`, `''`, -1))
+		if len(label) > 0 {
+			lexemes[i] += ":" + label
+		}
+	}
+	if len(lexemes) > 1 {
+		return fmt.Sprintf("(%s)", strings.Join(lexemes, " <-> "))
+	}
+	return strings.Join(lexemes, "")
+}
+
+// exploded compiles an exploded subject heading into the lexemes of the heading and of the headings beneath it, e.g.
+// `('dementia':C | ('alzheimer' <-> 'disease'):C)`.
+func (b PostgresBackend) exploded(keyword ir.Keyword) string {
+	headings := explodedHeadings(b.tree, keyword.QueryString)
+	terms := []string{b.keyword(keyword)}
+	for _, heading := range headings[1:] {
+		terms = append(terms, b.keyword(ir.Keyword{QueryString: heading, Fields: keyword.Fields}))
+	}
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return fmt.Sprintf("(%s)", strings.Join(terms, " | "))
+}
+
+// postgresOperator formats the operator of a query. Adjacency is written as a distance operator (<N>), which
+// matches the keywords in order, exactly N positions apart.
+func postgresOperator(q ir.BooleanQuery) string {
+	operator := strings.ToLower(q.Operator)
+	if strings.HasPrefix(operator, "adj") {
+		distance := operator[3:]
+		if len(distance) == 0 || distance == "0" || distance == "1" {
+			return "<->"
+		}
+		return fmt.Sprintf("<%s>", distance)
+	}
+	switch operator {
+	case cqr.AND, cqr.NOT:
+		return "&"
+	case cqr.OR:
+		return "|"
+	}
+	log.Printf("WARNING: unsupported operator %v, using AND instead\n", q.Operator)
+	return "&"
+}
+
+// postgresNear compiles the terms of an adjacency query (adjN) into each of the distances they may be apart, e.g.
+// `'memory' <1> 'loss' | 'memory' <2> 'loss' | 'loss' <1> 'memory' | 'loss' <2> 'memory'` for adj2. The terms are
+// only searched in the reverse order when the query is not ordered. Adjacent terms (adj) are instead followed by each
+// other, and queries of more than two terms cannot be expanded, so both are written with a single distance operator.
+func postgresNear(q ir.BooleanQuery, terms []string) (string, bool) {
+	distance := strings.TrimPrefix(strings.ToLower(q.Operator), "adj")
+	if !strings.HasPrefix(strings.ToLower(q.Operator), "adj") || len(distance) == 0 {
+		return "", false
+	}
+	n, err := strconv.Atoi(distance)
+	if err != nil || n < 1 {
+		return "", false
+	}
+	if len(terms) != 2 {
+		log.Printf("WARNING: could not expand the %v query of %d terms, searching for them in order exactly %d positions apart instead\n", q.Operator, len(terms), n)
+		return "", false
+	}
+	ordered, _ := q.Options[ir.OrderedOption].(bool)
+	var near []string
+	for i := 1; i <= n; i++ {
+		near = append(near, fmt.Sprintf("%s <%d> %s", terms[0], i, terms[1]))
+	}
+	if !ordered {
+		for i := 1; i <= n; i++ {
+			near = append(near, fmt.Sprintf("%s <%d> %s", terms[1], i, terms[0]))
+		}
+	}
+	return strings.Join(near, " | "), true
+}
+
+// compile compiles a query into a tsquery. The queries excluded by a not query are negated (!). A tsquery cannot
+// search date ranges, so they are omitted, and a query which is left empty is compiled into the empty string.
+func (b PostgresBackend) compile(q ir.BooleanQuery) string {
+	if q.Keywords == nil && len(q.Operator) == 0 {
+		var terms []string
+		for _, child := range q.Children {
+			if term := b.compile(child); len(term) > 0 {
+				terms = append(terms, term)
+			}
+		}
+		return strings.Join(terms, " & ")
+	}
+
+	var terms []string
+	for _, keyword := range q.Keywords {
+		if keyword.Range != nil {
+			log.Printf("WARNING: tsquery cannot search date ranges, omitting the range %v\n", keyword.QueryString)
+			continue
+		}
+		if isExplodedHeading(keyword) {
+			terms = append(terms, b.exploded(keyword))
+			continue
+		}
+		terms = append(terms, b.keyword(keyword))
+	}
+	for _, child := range q.Children {
+		term := b.compile(child)
+		if len(term) == 0 {
+			continue
+		}
+		if len(child.Keywords) != 1 || len(child.Children) > 0 {
+			term = fmt.Sprintf("(%s)", term)
+		}
+		terms = append(terms, term)
+	}
+
+	if near, ok := postgresNear(q, terms); ok {
+		return near
+	}
+	if strings.ToLower(q.Operator) == cqr.NOT {
+		for i := 1; i < len(terms); i++ {
+			terms[i] = "!" + terms[i]
+		}
+	}
+	return strings.Join(terms, fmt.Sprintf(" %s ", postgresOperator(q)))
+}
+
+// Compile transforms an immediate representation of a query into a PostgreSQL tsquery.
+func (b PostgresBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
+	return PostgresQuery{repr: b.compile(applyLimits(q))}, nil
+}
+
+// NewPostgresBackend returns a new backend for compiling PostgreSQL tsqueries with the default weights. Exploded
+// subject headings are expanded with the default MeSH tree.
+func NewPostgresBackend() PostgresBackend {
+	tree, err := meshexp.Default()
+	if err != nil {
+		panic(err)
+	}
+	return PostgresBackend{Weights: PostgresWeights, tree: tree}
+}
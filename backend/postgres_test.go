@@ -0,0 +1,83 @@
+package backend
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"strings"
+	"testing"
+)
+
+func TestPostgresBackend_Compile(t *testing.T) {
+	title := func(term string) ir.Keyword {
+		return ir.Keyword{QueryString: term, Fields: []string{fields.Title}}
+	}
+	tests := []struct {
+		name     string
+		query    ir.BooleanQuery
+		expected string
+	}{
+		{
+			name:     "adj",
+			query:    ir.BooleanQuery{Operator: "adj", Keywords: []ir.Keyword{title("memory"), title("loss")}},
+			expected: "'memory':A <-> 'loss':A",
+		},
+		{
+			name:     "adj2",
+			query:    ir.BooleanQuery{Operator: "adj2", Keywords: []ir.Keyword{title("memory"), title("loss")}},
+			expected: "'memory':A <1> 'loss':A | 'memory':A <2> 'loss':A | 'loss':A <1> 'memory':A | 'loss':A <2> 'memory':A",
+		},
+		{
+			name: "ordered adj2",
+			query: ir.BooleanQuery{
+				Operator: "adj2",
+				Keywords: []ir.Keyword{title("memory"), title("loss")},
+				Options:  map[string]interface{}{ir.OrderedOption: true},
+			},
+			expected: "'memory':A <1> 'loss':A | 'memory':A <2> 'loss':A",
+		},
+		{
+			name: "not",
+			query: ir.BooleanQuery{
+				Operator: cqr.NOT,
+				Keywords: []ir.Keyword{title("dementia")},
+				Children: []ir.BooleanQuery{
+					{Operator: cqr.OR, Keywords: []ir.Keyword{title("mice"), title("rats")}},
+					{Operator: "adj1", Keywords: []ir.Keyword{title("animal"), title("model")}},
+				},
+			},
+			expected: "'dementia':A & !('mice':A | 'rats':A) & !('animal':A <1> 'model':A | 'model':A <1> 'animal':A)",
+		},
+		{
+			name: "prefix",
+			query: ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{
+				{QueryString: "alzheimer*", Fields: []string{fields.TitleAbstract}, Truncated: true},
+				{QueryString: "memory loss*", Fields: []string{fields.AllFields}, Truncated: true},
+			}},
+			expected: "'alzheimer':*AB | ('memory' <-> 'loss':*)",
+		},
+		{
+			// The PubMed parser marks free text keywords as exploded, which are not subject headings.
+			name:     "exploded free text",
+			query:    ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{{QueryString: "Dementia", Fields: []string{fields.TitleAbstract}, Exploded: true}}},
+			expected: "'Dementia':AB",
+		},
+	}
+	b := NewPostgresBackend()
+	for _, test := range tests {
+		got, _ := compileWithLog(t, b, test.query)
+		if got != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestPostgresBackend_DateRange(t *testing.T) {
+	got, logged := compileWithLog(t, NewPostgresBackend(), rangeQuery)
+	if expected := "'dementia':A"; got != expected {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	if !strings.Contains(logged, "WARNING: tsquery cannot search date ranges") {
+		t.Errorf("expected a warning for the omitted date range, got %v", logged)
+	}
+}
@@ -0,0 +1,201 @@
+package backend
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"sort"
+	"strings"
+)
+
+// ProQuestBackend compiles queries into the ProQuest (and Dialog) command line syntax, e.g.
+// `MESH.EXACT.EXPLODE("Dementia") OR TI(memory NEAR/3 loss)`.
+type ProQuestBackend struct{}
+
+// ProQuestQuery is the transmute representation of a ProQuest query.
+type ProQuestQuery struct {
+	repr string
+}
+
+// proquestFieldCodes maps fields onto the ProQuest field codes which search them.
+var proquestFieldCodes = map[string][]string{
+	fields.Title:           {"TI"},
+	fields.Abstract:        {"AB"},
+	fields.TitleAbstract:   {"TI", "AB"},
+	fields.Keywords:        {"IF"},
+	fields.AllFields:       {"NOFT"},
+	fields.TextWord:        {"NOFT"},
+	fields.Authors:         {"AU"},
+	fields.Author:          {"AU"},
+	fields.AuthorFull:      {"AU"},
+	fields.AuthorLast:      {"AU"},
+	fields.Affiliation:     {"AF"},
+	fields.Journal:         {"PUB"},
+	fields.Language:        {"LA"},
+	fields.PublicationType: {"DTYPE"},
+	fields.PublicationDate: {"PD"},
+}
+
+// proquestHeadingCodes maps subject heading fields onto the ProQuest field codes of their vocabularies.
+var proquestHeadingCodes = map[string]string{
+	fields.MeshHeadings:            "MESH",
+	fields.MajorFocusMeshHeading:   "MJMESH",
+	fields.EmtreeHeadings:          "EMB",
+	fields.MajorFocusEmtreeHeading: "MJEMB",
+	fields.SubjectHeadings:         "MAINSUBJECT",
+}
+
+func (q ProQuestQuery) Representation() (interface{}, error) {
+	return q.repr, nil
+}
+
+func (q ProQuestQuery) String() (string, error) {
+	return q.repr, nil
+}
+
+func (q ProQuestQuery) StringPretty() (string, error) {
+	return q.repr, nil
+}
+
+// proquestCodes maps the fields of a keyword onto ProQuest field codes, e.g. `TI,AB`.
+func proquestCodes(keywordFields []string) string {
+	var codes []string
+	seen := make(map[string]bool)
+	for _, field := range keywordFields {
+		c, ok := proquestFieldCodes[field]
+		if !ok {
+			log.Printf("WARNING: could not map the field %v, searching anywhere except the full text instead\n", field)
+			c = []string{"NOFT"}
+		}
+		for _, code := range c {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+	if len(codes) == 0 {
+		return "NOFT"
+	}
+	sort.Strings(codes)
+	return strings.Join(codes, ",")
+}
+
+// proquestHeading compiles a subject heading keyword, or reports that the keyword is not a subject heading.
+func proquestHeading(keyword ir.Keyword) (string, bool) {
+	if len(keyword.Fields) != 1 {
+		return "", false
+	}
+	code, ok := proquestHeadingCodes[keyword.Fields[0]]
+	if !ok {
+		return "", false
+	}
+	if len(keywordSubheadings(keyword)) > 0 {
+		log.Printf("WARNING: ignoring the subheadings of %v\n", keyword.QueryString)
+	}
+	function := code + ".EXACT"
+	if keyword.Exploded {
+		function += ".EXPLODE"
+	}
+	return fmt.Sprintf(`%s("%s")`, function, strings.Trim(keyword.QueryString, `"`)), true
+}
+
+// proquestTerm formats the query string of a keyword. Words which are not separated by an operator are searched as a
+// phrase by ProQuest, but they are quoted to make this clear.
+func proquestTerm(keyword ir.Keyword) string {
+	if keyword.Range != nil {
+		return compileProQuestDateRange(*keyword.Range)
+	}
+	qs := keyword.QueryString
+	if strings.ContainsAny(qs, " \t") && !strings.HasPrefix(qs, `"`) {
+		qs = fmt.Sprintf(`"%s"`, qs)
+	}
+	return qs
+}
+
+// compileProQuestDateRange formats a date range as a ProQuest date, e.g. `20000101-20101231`, `>20041231`.
+func compileProQuestDateRange(r ir.DateRange) string {
+	switch {
+	case r.Start.IsZero() && r.End.IsZero():
+		return ">00010101"
+	case r.Start.IsZero():
+		return "<" + r.End.AddDate(0, 0, 1).Format("20060102")
+	case r.End.IsZero():
+		return ">" + r.Start.AddDate(0, 0, -1).Format("20060102")
+	}
+	return r.Start.Format("20060102") + "-" + r.End.Format("20060102")
+}
+
+// proquestOperator formats the operator of a query. Adjacency is written as NEAR/n, or PRE/n when the keywords must
+// appear in order.
+func proquestOperator(q ir.BooleanQuery) string {
+	operator := strings.ToLower(q.Operator)
+	if strings.HasPrefix(operator, "adj") {
+		distance := operator[3:]
+		if len(distance) == 0 {
+			return "PRE/0"
+		}
+		if ordered, ok := q.Options[ir.OrderedOption].(bool); ok && ordered {
+			return "PRE/" + distance
+		}
+		return "NEAR/" + distance
+	}
+	switch operator {
+	case cqr.AND, cqr.OR, cqr.NOT:
+		return strings.ToUpper(operator)
+	}
+	log.Printf("WARNING: unsupported operator %v, using AND instead\n", q.Operator)
+	return "AND"
+}
+
+// compileProQuest compiles a query. When fielded is set, the keywords are written without their fields, since the
+// entire query is already inside a field code.
+func compileProQuest(q ir.BooleanQuery, fielded bool) string {
+	if q.Keywords == nil && len(q.Operator) == 0 {
+		var repr string
+		for _, child := range q.Children {
+			repr += compileProQuest(child, fielded)
+		}
+		return repr
+	}
+
+	// Queries searching the same fields are written inside a single field code, e.g. `TI(memory NEAR/3 loss)`.
+	if !fielded {
+		if f, ok := sharedFields(q); ok {
+			return fmt.Sprintf("%s(%s)", proquestCodes(f), compileProQuest(q, true))
+		}
+	}
+
+	var terms []string
+	for _, keyword := range q.Keywords {
+		if heading, ok := proquestHeading(keyword); ok {
+			terms = append(terms, heading)
+		} else if fielded {
+			terms = append(terms, proquestTerm(keyword))
+		} else {
+			terms = append(terms, fmt.Sprintf("%s(%s)", proquestCodes(keyword.Fields), proquestTerm(keyword)))
+		}
+	}
+	for _, child := range q.Children {
+		// A child written inside its own field code does not need to be grouped.
+		if _, ok := sharedFields(child); ok && !fielded {
+			terms = append(terms, compileProQuest(child, false))
+			continue
+		}
+		terms = append(terms, fmt.Sprintf("(%s)", compileProQuest(child, fielded)))
+	}
+
+	return strings.Join(terms, fmt.Sprintf(" %s ", proquestOperator(q)))
+}
+
+// Compile transforms an immediate representation of a query into a ProQuest query.
+func (b ProQuestBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
+	return ProQuestQuery{repr: compileProQuest(applyLimits(q), false)}, nil
+}
+
+// NewProQuestBackend returns a new backend for compiling ProQuest queries.
+func NewProQuestBackend() ProQuestBackend {
+	return ProQuestBackend{}
+}
@@ -0,0 +1,90 @@
+package backend
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"github.com/hscells/transmute/lexer"
+	"github.com/hscells/transmute/parser"
+	"testing"
+	"time"
+)
+
+func TestProQuestBackend_Compile(t *testing.T) {
+	keyword := func(term string, f ...string) ir.Keyword {
+		return ir.Keyword{QueryString: term, Fields: f}
+	}
+	tests := []struct {
+		name     string
+		query    ir.BooleanQuery
+		expected string
+	}{
+		{
+			name:     "near",
+			query:    ir.BooleanQuery{Operator: "adj3", Keywords: []ir.Keyword{keyword("memory", fields.Title), keyword("loss", fields.Title)}},
+			expected: "TI(memory NEAR/3 loss)",
+		},
+		{
+			name: "pre",
+			query: ir.BooleanQuery{
+				Operator: "adj2",
+				Keywords: []ir.Keyword{keyword("mild", fields.TitleAbstract), {QueryString: "impair*", Fields: []string{fields.TitleAbstract}, Truncated: true}},
+				Options:  map[string]interface{}{ir.OrderedOption: true},
+			},
+			expected: "AB,TI(mild PRE/2 impair*)",
+		},
+		{
+			name: "fields",
+			query: ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{
+				keyword("dementia", fields.Title),
+				keyword("memory loss", fields.Abstract),
+				{QueryString: "Dementia", Fields: []string{fields.MeshHeadings}, Exploded: true},
+				keyword("Delirium", fields.MeshHeadings),
+			}},
+			expected: `TI(dementia) OR AB("memory loss") OR MESH.EXACT.EXPLODE("Dementia") OR MESH.EXACT("Delirium")`,
+		},
+		{
+			name: "limit",
+			query: ir.BooleanQuery{
+				Operator: cqr.OR,
+				Keywords: []ir.Keyword{keyword("dementia", fields.Title)},
+				Limits: []ir.Limit{
+					{Field: fields.PublicationDate, Range: &ir.DateRange{
+						Start: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+						End:   time.Date(2010, 12, 31, 0, 0, 0, 0, time.UTC),
+					}},
+					{Field: fields.Language, Values: []string{"english", "french"}},
+				},
+			},
+			expected: "TI(dementia) AND PD(20000101-20101231) AND LA(english OR french)",
+		},
+	}
+	for _, test := range tests {
+		q, err := NewProQuestBackend().Compile(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := q.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestProQuestBackend_RoundTrip(t *testing.T) {
+	query := `((MESH.EXACT.EXPLODE("Dementia") OR MAINSUBJECT.EXACT("Delirium") OR TI(memory NEAR/3 loss)) AND AB(mild PRE/2 impair*)) NOT NOFT(review)`
+	q, err := NewProQuestBackend().Compile(parser.NewProQuestParser().Parse(lexer.Node{Value: query, Reference: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := q.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != query {
+		t.Errorf("expected %v, got %v", query, got)
+	}
+}
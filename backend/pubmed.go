@@ -7,13 +7,20 @@ import (
 	"github.com/hscells/transmute/fields"
 	"github.com/hscells/transmute/ir"
 	"sort"
+	"strconv"
 	"strings"
 )
 
+// PubmedBackend compiles queries into the PubMed query syntax. Adjacency queries over single words in the title or
+// title and abstract are compiled into PubMed proximity searches (e.g. `"hip pain"[Title/Abstract:~3]`). All other
+// adjacency queries, and every adjacency query when ReplaceAdj is set, are replaced with AND.
 type PubmedBackend struct {
 	ReplaceAdj bool
 }
 
+// pubmedEarliestDate is the start of a date range which has no start, since PubMed requires both bounds of a range.
+const pubmedEarliestDate = "1000"
+
 type PubmedQuery struct {
 	repr string
 }
@@ -41,6 +48,12 @@ func compilePubmed(q ir.BooleanQuery, level int, replaceAdj bool) (l int, query
 		return level, PubmedQuery{repr: repr}
 	}
 
+	if !replaceAdj {
+		if proximity, ok := compilePubmedProximity(q); ok {
+			return level + 1, PubmedQuery{repr: proximity}
+		}
+	}
+
 	children := make([]string, len(q.Children))
 	for i, child := range q.Children {
 		l, comp := compilePubmed(child, level, replaceAdj)
@@ -51,6 +64,9 @@ func compilePubmed(q ir.BooleanQuery, level int, replaceAdj bool) (l int, query
 	for i, keyword := range q.Keywords {
 		var mf string
 		qs := keyword.QueryString
+		if keyword.Range != nil {
+			qs = compilePubmedDateRange(*keyword.Range)
+		}
 		buff := new(bytes.Buffer)
 
 		// PubMed supports only end-truncation. There is no single character symbol.
@@ -136,7 +152,10 @@ func compilePubmed(q ir.BooleanQuery, level int, replaceAdj bool) (l int, query
 				"pmid":                            {fields.PMID},
 			}
 
-			sort.Strings(keyword.Fields)
+			keywordFields := make([]string, len(keyword.Fields))
+			copy(keywordFields, keyword.Fields)
+			sort.Strings(keywordFields)
+			keyword.Fields = keywordFields
 			for f, mappingFields := range mapping1 {
 				if len(mappingFields) != len(keyword.Fields) {
 					continue
@@ -155,7 +174,19 @@ func compilePubmed(q ir.BooleanQuery, level int, replaceAdj bool) (l int, query
 				mf = "All Fields"
 			}
 		}
-		qs = fmt.Sprintf("%v[%v]", qs, mf)
+		// A heading restricted to subheadings is searched once per subheading (e.g. `Dementia/dt[Mesh Terms:noexp]`).
+		if subheadings := keywordSubheadings(keyword); len(subheadings) > 0 && len(keyword.Fields) == 1 && subjectHeadingFields[keyword.Fields[0]] {
+			headings := make([]string, len(subheadings))
+			for j, subheading := range subheadings {
+				headings[j] = fmt.Sprintf("%v/%v[%v]", qs, subheading, mf)
+			}
+			qs = headings[0]
+			if len(headings) > 1 {
+				qs = fmt.Sprintf("(%v)", strings.Join(headings, " OR "))
+			}
+		} else {
+			qs = fmt.Sprintf("%v[%v]", qs, mf)
+		}
 		keywords[i] = qs
 		level += 1
 	}
@@ -171,8 +202,66 @@ func compilePubmed(q ir.BooleanQuery, level int, replaceAdj bool) (l int, query
 	return level, PubmedQuery{repr: repr}
 }
 
+// compilePubmedProximity compiles an adjacency query into a PubMed proximity search. PubMed only supports proximity
+// searches of words in the title, or in the title and abstract, so other adjacency queries cannot be compiled.
+func compilePubmedProximity(q ir.BooleanQuery) (string, bool) {
+	if !strings.HasPrefix(strings.ToLower(q.Operator), "adj") || len(q.Children) > 0 || len(q.Keywords) < 2 {
+		return "", false
+	}
+
+	distance := 0
+	if len(q.Operator) > 3 {
+		var err error
+		distance, err = strconv.Atoi(q.Operator[3:])
+		if err != nil {
+			return "", false
+		}
+	}
+
+	var field string
+	terms := make([]string, len(q.Keywords))
+	for i, keyword := range q.Keywords {
+		if keyword.Truncated || strings.ContainsAny(keyword.QueryString, "\"*?$ \t") {
+			return "", false
+		}
+
+		f := make([]string, len(keyword.Fields))
+		copy(f, keyword.Fields)
+		sort.Strings(f)
+		var mf string
+		switch strings.Join(f, ",") {
+		case fields.Title:
+			mf = "Title"
+		case fields.TitleAbstract, fields.Abstract + "," + fields.Title:
+			mf = "Title/Abstract"
+		default:
+			return "", false
+		}
+		if i > 0 && mf != field {
+			return "", false
+		}
+		field = mf
+		terms[i] = keyword.QueryString
+	}
+
+	return fmt.Sprintf(`"%v"[%v:~%d]`, strings.Join(terms, " "), field, distance), true
+}
+
+// compilePubmedDateRange formats a date range as a PubMed date range, e.g. `2000/01/01:2010/12/31`. PubMed uses
+// `3000` as the end of a range which is open ended.
+func compilePubmedDateRange(r ir.DateRange) string {
+	start, end := pubmedEarliestDate, "3000"
+	if !r.Start.IsZero() {
+		start = r.Start.Format("2006/01/02")
+	}
+	if !r.End.IsZero() {
+		end = r.End.Format("2006/01/02")
+	}
+	return fmt.Sprintf("%v:%v", start, end)
+}
+
 func (b PubmedBackend) Compile(ir ir.BooleanQuery) (BooleanQuery, error) {
-	_, q := compilePubmed(ir, 1, b.ReplaceAdj)
+	_, q := compilePubmed(applyLimits(ir), 1, b.ReplaceAdj)
 	return q, nil
 }
 
@@ -0,0 +1,112 @@
+package backend
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"testing"
+)
+
+func TestCompilePubmedProximity(t *testing.T) {
+	keyword := func(term string, f ...string) ir.Keyword {
+		return ir.Keyword{QueryString: term, Fields: f}
+	}
+	tests := []struct {
+		name     string
+		query    ir.BooleanQuery
+		expected string
+		ok       bool
+	}{
+		{
+			name:     "title and abstract",
+			query:    ir.BooleanQuery{Operator: "adj3", Keywords: []ir.Keyword{keyword("hip", fields.TitleAbstract), keyword("pain", fields.TitleAbstract)}},
+			expected: `"hip pain"[Title/Abstract:~3]`,
+			ok:       true,
+		},
+		{
+			name:     "title and text",
+			query:    ir.BooleanQuery{Operator: "adj2", Keywords: []ir.Keyword{keyword("hip", fields.Title, fields.Abstract), keyword("pain", fields.Abstract, fields.Title)}},
+			expected: `"hip pain"[Title/Abstract:~2]`,
+			ok:       true,
+		},
+		{
+			name:     "title",
+			query:    ir.BooleanQuery{Operator: "adj", Keywords: []ir.Keyword{keyword("hip", fields.Title), keyword("pain", fields.Title)}},
+			expected: `"hip pain"[Title:~0]`,
+			ok:       true,
+		},
+		{
+			name:  "phrase",
+			query: ir.BooleanQuery{Operator: "adj3", Keywords: []ir.Keyword{keyword("hip joint", fields.TitleAbstract), keyword("pain", fields.TitleAbstract)}},
+		},
+		{
+			name:  "truncated",
+			query: ir.BooleanQuery{Operator: "adj3", Keywords: []ir.Keyword{keyword("hip", fields.TitleAbstract), {QueryString: "pain*", Fields: []string{fields.TitleAbstract}, Truncated: true}}},
+		},
+		{
+			name:  "abstract",
+			query: ir.BooleanQuery{Operator: "adj3", Keywords: []ir.Keyword{keyword("hip", fields.Abstract), keyword("pain", fields.Abstract)}},
+		},
+		{
+			name:  "mixed fields",
+			query: ir.BooleanQuery{Operator: "adj3", Keywords: []ir.Keyword{keyword("hip", fields.Title), keyword("pain", fields.TitleAbstract)}},
+		},
+		{
+			name:  "not adjacency",
+			query: ir.BooleanQuery{Operator: cqr.AND, Keywords: []ir.Keyword{keyword("hip", fields.Title), keyword("pain", fields.Title)}},
+		},
+	}
+	for _, test := range tests {
+		got, ok := compilePubmedProximity(test.query)
+		if ok != test.ok || got != test.expected {
+			t.Errorf("%v: expected %v (%v), got %v (%v)", test.name, test.expected, test.ok, got, ok)
+		}
+	}
+}
+
+func TestPubmedBackend_Proximity(t *testing.T) {
+	keyword := func(term string, f ...string) ir.Keyword {
+		return ir.Keyword{QueryString: term, Fields: f}
+	}
+	tests := []struct {
+		name     string
+		query    ir.BooleanQuery
+		expected string
+	}{
+		// Adjacency queries which cannot be searched by proximity are searched with and instead.
+		{
+			name: "proximity",
+			query: ir.BooleanQuery{Operator: cqr.AND, Keywords: []ir.Keyword{keyword("dementia", fields.Title)}, Children: []ir.BooleanQuery{
+				{Operator: "adj3", Keywords: []ir.Keyword{keyword("hip", fields.TitleAbstract), keyword("pain", fields.TitleAbstract)}},
+			}},
+			expected: `(dementia[Title] AND "hip pain"[Title/Abstract:~3])`,
+		},
+		{
+			name: "phrase",
+			query: ir.BooleanQuery{Operator: cqr.AND, Keywords: []ir.Keyword{keyword("dementia", fields.Title)}, Children: []ir.BooleanQuery{
+				{Operator: "adj3", Keywords: []ir.Keyword{keyword(`"hip joint"`, fields.TitleAbstract), keyword("pain", fields.TitleAbstract)}},
+			}},
+			expected: `(dementia[Title] AND ("hip joint"[Title/Abstract] AND pain[Title/Abstract]))`,
+		},
+		{
+			name: "heading",
+			query: ir.BooleanQuery{Operator: cqr.AND, Keywords: []ir.Keyword{keyword("dementia", fields.Title)}, Children: []ir.BooleanQuery{
+				{Operator: "adj3", Keywords: []ir.Keyword{keyword("hip", fields.MeshHeadings), keyword("pain", fields.MeshHeadings)}},
+			}},
+			expected: "(dementia[Title] AND (hip[Mesh Terms:noexp] AND pain[Mesh Terms:noexp]))",
+		},
+	}
+	for _, test := range tests {
+		q, err := NewPubmedBackend().Compile(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := q.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
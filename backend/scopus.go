@@ -0,0 +1,139 @@
+package backend
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"strings"
+)
+
+// ScopusBackend compiles queries into Scopus advanced search queries, e.g.
+// `TITLE-ABS-KEY(memory W/3 loss) AND PUBYEAR > 1999`. Scopus does not index a controlled vocabulary, so subject
+// headings are handled by the heading policy.
+type ScopusBackend struct {
+	HeadingPolicy HeadingPolicy
+}
+
+// ScopusQuery is the transmute representation of a Scopus query.
+type ScopusQuery struct {
+	repr string
+}
+
+func (q ScopusQuery) Representation() (interface{}, error) {
+	return q.repr, nil
+}
+
+func (q ScopusQuery) String() (string, error) {
+	return q.repr, nil
+}
+
+func (q ScopusQuery) StringPretty() (string, error) {
+	return q.repr, nil
+}
+
+// scopusDialect describes the Scopus search syntax.
+var scopusDialect = citationDialect{
+	tags: map[string][]string{
+		fields.Title:                   {"TITLE"},
+		fields.Abstract:                {"ABS"},
+		fields.TitleAbstract:           {"TITLE", "ABS"},
+		fields.TextWord:                {"TITLE", "ABS"},
+		fields.Keywords:                {"KEY"},
+		fields.MeshHeadings:            {"KEY"},
+		fields.MajorFocusMeshHeading:   {"KEY"},
+		fields.EmtreeHeadings:          {"KEY"},
+		fields.MajorFocusEmtreeHeading: {"KEY"},
+		fields.FloatingMeshHeadings:    {"KEY"},
+		fields.SubjectHeadings:         {"KEY"},
+		fields.AllFields:               {"ALL"},
+		fields.Authors:                 {"AUTH"},
+		fields.Author:                  {"AUTH"},
+		fields.AuthorFull:              {"AUTH"},
+		fields.AuthorLast:              {"AUTH"},
+		fields.AuthorFirst:             {"FIRSTAUTH"},
+		fields.Affiliation:             {"AFFIL"},
+		fields.Journal:                 {"SRCTITLE"},
+		fields.PublicationType:         {"DOCTYPE"},
+		fields.Language:                {"LANGUAGE"},
+		fields.ISBN:                    {"ISBN"},
+		fields.PMID:                    {"PMID"},
+	},
+	combined: []citationTag{
+		{"TITLE-ABS-KEY", []string{"TITLE", "ABS", "KEY"}},
+		{"TITLE-ABS", []string{"TITLE", "ABS"}},
+	},
+	text:     map[string]bool{"TITLE": true, "ABS": true, "KEY": true, "TITLE-ABS": true, "TITLE-ABS-KEY": true, "ALL": true},
+	fallback: "TITLE-ABS-KEY",
+	all:      "ALL",
+	field: func(tag, expression string) string {
+		return fmt.Sprintf("%s(%s)", tag, expression)
+	},
+	term:      scopusTerm,
+	dateRange: compileScopusDateRange,
+	operator:  scopusOperator,
+}
+
+// scopusTerm formats the query string of a keyword. Exact phrases are written in braces, and loose phrases in quotes.
+func scopusTerm(keyword ir.Keyword) string {
+	if phrase, ok := keyword.Options[ir.PhraseOption].(string); ok && phrase == ir.ExactPhrase {
+		return fmt.Sprintf("{%s}", strings.Trim(keyword.QueryString, `"`))
+	}
+	return citationTerm(keyword)
+}
+
+// compileScopusDateRange writes a date range as a comparison of publication years, e.g.
+// `(PUBYEAR > 1999 AND PUBYEAR < 2011)`. The comparisons exclude the year itself.
+func compileScopusDateRange(r ir.DateRange) string {
+	switch {
+	case r.Start.IsZero() && r.End.IsZero():
+		return "PUBYEAR > 0"
+	case r.Start.IsZero():
+		return fmt.Sprintf("PUBYEAR < %d", r.End.Year()+1)
+	case r.End.IsZero():
+		return fmt.Sprintf("PUBYEAR > %d", r.Start.Year()-1)
+	case r.Start.Year() == r.End.Year():
+		return fmt.Sprintf("PUBYEAR = %d", r.Start.Year())
+	}
+	return fmt.Sprintf("(PUBYEAR > %d AND PUBYEAR < %d)", r.Start.Year()-1, r.End.Year()+1)
+}
+
+// scopusOperator formats the operator of a query. Adjacency is written as W/n, or PRE/n when the keywords must appear
+// in order. Scopus excludes documents with AND NOT.
+func scopusOperator(q ir.BooleanQuery) string {
+	operator := strings.ToLower(q.Operator)
+	if strings.HasPrefix(operator, "adj") {
+		distance := operator[3:]
+		if len(distance) == 0 {
+			return "PRE/0"
+		}
+		if ordered, ok := q.Options[ir.OrderedOption].(bool); ok && ordered {
+			return "PRE/" + distance
+		}
+		return "W/" + distance
+	}
+	switch operator {
+	case cqr.AND, cqr.OR:
+		return strings.ToUpper(operator)
+	case cqr.NOT:
+		return "AND NOT"
+	}
+	log.Printf("WARNING: unsupported operator %v, using AND instead\n", q.Operator)
+	return "AND"
+}
+
+// Compile transforms an immediate representation of a query into a Scopus query.
+func (b ScopusBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
+	q, err := applyHeadingPolicy(applyLimits(q), b.HeadingPolicy, "Scopus")
+	if err != nil {
+		return nil, err
+	}
+	return ScopusQuery{repr: compileCitation(q, scopusDialect, false)}, nil
+}
+
+// NewScopusBackend returns a new backend for compiling Scopus queries, which handles subject headings according to
+// the heading policy, e.g. `NewScopusBackend(HeadingsAsPhrases)`.
+func NewScopusBackend(policy HeadingPolicy) ScopusBackend {
+	return ScopusBackend{HeadingPolicy: policy}
+}
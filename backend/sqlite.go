@@ -0,0 +1,214 @@
+package backend
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/meshexp"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"strings"
+)
+
+// SQLiteColumns maps fields onto the columns of an FTS5 table of PubMed records. Fields which are mapped onto no columns
+// are searched in every column.
+var SQLiteColumns = map[string][]string{
+	fields.Title:                 {"title"},
+	fields.Abstract:              {"abstract"},
+	fields.TitleAbstract:         {"title", "abstract"},
+	fields.TextWord:              {"title", "abstract"},
+	fields.AllFields:             {},
+	fields.Keywords:              {"keywords"},
+	fields.MeshHeadings:          {"mesh_headings"},
+	fields.MajorFocusMeshHeading: {"mesh_headings"},
+	fields.SubjectHeadings:       {"mesh_headings"},
+	fields.Authors:               {"authors"},
+	fields.Author:                {"authors"},
+	fields.AuthorFull:            {"authors"},
+	fields.AuthorLast:            {"authors"},
+	fields.Journal:               {"journal"},
+	fields.PublicationType:       {"publication_type"},
+	fields.Language:              {"language"},
+}
+
+// SQLiteBackend compiles queries into SQLite FTS5 full-text query expressions, which are the right hand side of a
+// `MATCH`, e.g. `{title abstract}:NEAR("memory" "loss", 3) NOT publication_type:"review"`.
+type SQLiteBackend struct {
+	// Columns maps fields onto the columns of the FTS5 table.
+	Columns map[string][]string
+	tree    *meshexp.MeSHTree
+}
+
+// SQLiteQuery is the transmute representation of an SQLite FTS5 query.
+type SQLiteQuery struct {
+	repr string
+}
+
+func (q SQLiteQuery) Representation() (interface{}, error) {
+	return q.repr, nil
+}
+
+func (q SQLiteQuery) String() (string, error) {
+	return q.repr, nil
+}
+
+func (q SQLiteQuery) StringPretty() (string, error) {
+	return q.repr, nil
+}
+
+// columnFilter writes the column filter which restricts an expression to the columns of fields, e.g.
+// `{title abstract}:`. Fields which cannot be mapped are searched in every column.
+func (b SQLiteBackend) columnFilter(keywordFields []string) string {
+	var columns []string
+	seen := make(map[string]bool)
+	for _, field := range keywordFields {
+		c, ok := b.Columns[field]
+		if !ok {
+			log.Printf("WARNING: could not map the field %v, searching all columns instead\n", field)
+			return ""
+		}
+		if len(c) == 0 {
+			return ""
+		}
+		for _, column := range c {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	switch len(columns) {
+	case 0:
+		return ""
+	case 1:
+		return columns[0] + ":"
+	}
+	return fmt.Sprintf("{%s}:", strings.Join(columns, " "))
+}
+
+// sqliteTerm formats the query string of a keyword as an FTS5 string, which is quoted so that punctuation and
+// operators are searched as text. Truncated keywords are written as prefix queries.
+func sqliteTerm(keyword ir.Keyword) string {
+	qs := strings.Trim(strings.TrimSpace(keyword.QueryString), `"`)
+	prefix := ""
+	if keyword.Truncated {
+		if i := strings.IndexAny(qs, "*$#?"); i >= 0 {
+			if i < len(qs)-1 {
+				log.Printf("WARNING: FTS5 only supports prefix queries, searching for %v as the prefix %v\n", qs, qs[:i])
+			}
+			qs = qs[:i]
+		}
+		prefix = "*"
+	}
+	return fmt.Sprintf(`"%s"%s`, strings.Replace(qs, `"`, `""`, -1), prefix)
+}
+
+// keyword compiles a keyword into a phrase restricted to the columns of its fields. An exploded subject heading is
+// searched along with the headings beneath it, e.g. `(mesh_headings:"Dementia" OR mesh_headings:"Alzheimer Disease")`.
+func (b SQLiteBackend) keyword(keyword ir.Keyword) string {
+	filter := b.columnFilter(keyword.Fields)
+	if !isExplodedHeading(keyword) {
+		return filter + sqliteTerm(keyword)
+	}
+	headings := explodedHeadings(b.tree, keyword.QueryString)
+	terms := []string{filter + sqliteTerm(keyword)}
+	for _, heading := range headings[1:] {
+		terms = append(terms, filter+sqliteTerm(ir.Keyword{QueryString: heading}))
+	}
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return fmt.Sprintf("(%s)", strings.Join(terms, " OR "))
+}
+
+// near compiles an adjacency query into a phrase or a NEAR group, e.g. `NEAR("memory" "loss", 3)`, or reports that
+// the query cannot be written as one. Only queries of keywords searched in the same fields can be.
+func (b SQLiteBackend) near(q ir.BooleanQuery) (string, bool) {
+	if len(q.Children) > 0 || len(q.Keywords) == 0 {
+		return "", false
+	}
+	var phrases []string
+	for _, keyword := range q.Keywords {
+		if keyword.Range != nil || strings.Join(keyword.Fields, ",") != strings.Join(q.Keywords[0].Fields, ",") {
+			return "", false
+		}
+		phrases = append(phrases, sqliteTerm(keyword))
+	}
+	filter := b.columnFilter(q.Keywords[0].Fields)
+
+	distance := strings.TrimPrefix(strings.ToLower(q.Operator), "adj")
+	if len(distance) == 0 {
+		// Adjacent phrases are joined into a single phrase.
+		return filter + strings.Join(phrases, " + "), true
+	}
+	if ordered, ok := q.Options[ir.OrderedOption].(bool); ok && ordered {
+		log.Printf("WARNING: FTS5 NEAR groups match phrases in any order, searching within %v tokens in any order instead\n", distance)
+	}
+	return fmt.Sprintf("%sNEAR(%s, %s)", filter, strings.Join(phrases, " "), distance), true
+}
+
+// compile compiles a query into an FTS5 expression. FTS5 cannot search date ranges, so they are omitted, and a query
+// which is left empty is compiled into the empty string.
+func (b SQLiteBackend) compile(q ir.BooleanQuery) string {
+	if q.Keywords == nil && len(q.Operator) == 0 {
+		var terms []string
+		for _, child := range q.Children {
+			if term := b.compile(child); len(term) > 0 {
+				terms = append(terms, term)
+			}
+		}
+		return strings.Join(terms, " AND ")
+	}
+
+	operator := strings.ToLower(q.Operator)
+	if strings.HasPrefix(operator, "adj") {
+		if near, ok := b.near(q); ok {
+			return near
+		}
+		log.Printf("WARNING: could not write the %v query as a NEAR group, searching for all of its terms instead\n", q.Operator)
+		operator = cqr.AND
+	}
+
+	var terms []string
+	for _, keyword := range q.Keywords {
+		if keyword.Range != nil {
+			log.Printf("WARNING: FTS5 cannot search date ranges, omitting the range %v\n", keyword.QueryString)
+			continue
+		}
+		terms = append(terms, b.keyword(keyword))
+	}
+	for _, child := range q.Children {
+		term := b.compile(child)
+		if len(term) == 0 {
+			continue
+		}
+		if len(child.Keywords) != 1 || len(child.Children) > 0 {
+			term = fmt.Sprintf("(%s)", term)
+		}
+		terms = append(terms, term)
+	}
+
+	switch operator {
+	case cqr.AND, cqr.OR, cqr.NOT:
+		operator = strings.ToUpper(operator)
+	default:
+		log.Printf("WARNING: unsupported operator %v, using AND instead\n", q.Operator)
+		operator = "AND"
+	}
+	return strings.Join(terms, fmt.Sprintf(" %s ", operator))
+}
+
+// Compile transforms an immediate representation of a query into an SQLite FTS5 query.
+func (b SQLiteBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
+	return SQLiteQuery{repr: b.compile(applyLimits(q))}, nil
+}
+
+// NewSQLiteBackend returns a new backend for compiling SQLite FTS5 queries with the default columns. Exploded subject
+// headings are expanded with the default MeSH tree.
+func NewSQLiteBackend() SQLiteBackend {
+	tree, err := meshexp.Default()
+	if err != nil {
+		panic(err)
+	}
+	return SQLiteBackend{Columns: SQLiteColumns, tree: tree}
+}
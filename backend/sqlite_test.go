@@ -0,0 +1,107 @@
+package backend
+
+import (
+	"bytes"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rangeQuery is `dementia.ti. and 2000-2010.yr.`, which has a date range that some backends cannot search.
+var rangeQuery = ir.BooleanQuery{
+	Operator: cqr.AND,
+	Keywords: []ir.Keyword{
+		{QueryString: "dementia", Fields: []string{fields.Title}},
+		{QueryString: "2000:2010", Fields: []string{fields.PublicationDate}, Range: &ir.DateRange{
+			Start: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(2010, 12, 31, 0, 0, 0, 0, time.UTC),
+		}},
+	},
+}
+
+// compileWithLog compiles a query, returning the query string along with anything that was logged.
+func compileWithLog(t *testing.T, compiler Compiler, q ir.BooleanQuery) (string, string) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	compiled, err := compiler.Compile(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := compiled.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, buf.String()
+}
+
+func TestSQLiteBackend_Compile(t *testing.T) {
+	title := func(term string) ir.Keyword {
+		return ir.Keyword{QueryString: term, Fields: []string{fields.Title}}
+	}
+	tests := []struct {
+		name     string
+		query    ir.BooleanQuery
+		expected string
+	}{
+		{
+			name:     "phrase",
+			query:    ir.BooleanQuery{Operator: "adj", Keywords: []ir.Keyword{title("memory"), title("loss")}},
+			expected: `title:"memory" + "loss"`,
+		},
+		{
+			name:     "near",
+			query:    ir.BooleanQuery{Operator: "adj3", Keywords: []ir.Keyword{title("memory"), title("loss")}},
+			expected: `title:NEAR("memory" "loss", 3)`,
+		},
+		{
+			name: "not",
+			query: ir.BooleanQuery{
+				Operator: cqr.NOT,
+				Keywords: []ir.Keyword{title("dementia")},
+				Children: []ir.BooleanQuery{{Operator: cqr.OR, Keywords: []ir.Keyword{title("mice"), title("rats")}}},
+			},
+			expected: `title:"dementia" NOT (title:"mice" OR title:"rats")`,
+		},
+		{
+			name: "prefix",
+			query: ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{
+				{QueryString: "alzheimer*", Fields: []string{fields.TitleAbstract}, Truncated: true},
+			}},
+			expected: `{title abstract}:"alzheimer"*`,
+		},
+		{
+			// The PubMed parser marks free text keywords as exploded, which are not subject headings.
+			name:     "exploded free text",
+			query:    ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{{QueryString: "Dementia", Fields: []string{fields.TitleAbstract}, Exploded: true}}},
+			expected: `{title abstract}:"Dementia"`,
+		},
+		{
+			name:     "exploded heading",
+			query:    ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{{QueryString: "Amnesia", Fields: []string{fields.MeshHeadings}, Exploded: true}}},
+			expected: `(mesh_headings:"Amnesia" OR mesh_headings:"Alcoholic Korsakoff Syndrome" OR mesh_headings:"Amnesia, Anterograde" OR mesh_headings:"Amnesia, Retrograde" OR mesh_headings:"Amnesia, Transient Global")`,
+		},
+	}
+	b := NewSQLiteBackend()
+	for _, test := range tests {
+		got, _ := compileWithLog(t, b, test.query)
+		if got != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestSQLiteBackend_DateRange(t *testing.T) {
+	got, logged := compileWithLog(t, NewSQLiteBackend(), rangeQuery)
+	if expected := `title:"dementia"`; got != expected {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	if !strings.Contains(logged, "WARNING: FTS5 cannot search date ranges") {
+		t.Errorf("expected a warning for the omitted date range, got %v", logged)
+	}
+}
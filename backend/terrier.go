@@ -3,6 +3,7 @@ package backend
 import (
 	"fmt"
 	"github.com/hscells/transmute/ir"
+	"log"
 	"strings"
 )
 
@@ -29,9 +30,29 @@ func (q TerrierQuery) Representation() (interface{}, error) {
 	return q.String()
 }
 
-// Compile a terrier query.
+// terrierKeywords returns the keywords of a query which terrier can search. Terrier cannot search date ranges, so they
+// are omitted.
+func terrierKeywords(keywords []ir.Keyword) []ir.Keyword {
+	var searchable []ir.Keyword
+	for _, keyword := range keywords {
+		if keyword.Range != nil {
+			log.Printf("WARNING: terrier cannot search date ranges, omitting the range %v\n", keyword.Range)
+			continue
+		}
+		searchable = append(searchable, keyword)
+	}
+	return searchable
+}
+
+// Compile a terrier query. Limits are searched as keywords, except for date ranges, which terrier cannot search.
 func (t TerrierBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
+	return t.compile(applyLimits(q))
+}
+
+// compile compiles a query which has had its limits applied.
+func (t TerrierBackend) compile(q ir.BooleanQuery) (BooleanQuery, error) {
 	tq := TerrierQuery{}
+	q.Keywords = terrierKeywords(q.Keywords)
 
 	// Process the keywords.
 	if q.Operator == "and" {
@@ -42,17 +63,21 @@ func (t TerrierBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
 				keywords = append(keywords, fmt.Sprintf("+%s:%s", field, keyword.QueryString))
 			}
 		}
-		tq.repr += strings.Join(keywords, " ")
 
-		// Process the children.
+		// Process the children, which are each required like the keywords (e.g. the limits of a query).
 		for _, child := range q.Children {
-			c, err := t.Compile(child)
+			c, err := t.compile(child)
 			if err != nil {
 				return nil, err
 			}
 			s, _ := c.String()
-			tq.repr += s
+			if s == "()" {
+				// The child only searched for date ranges.
+				continue
+			}
+			keywords = append(keywords, "+"+s)
 		}
+		tq.repr += strings.Join(keywords, " ")
 		tq.repr += ")"
 	} else if len(q.Operator) > 3 && q.Operator[0:3] == "adj" {
 		tq.repr += " \""
@@ -67,11 +92,15 @@ func (t TerrierBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
 
 		// Process the children.
 		for _, child := range q.Children {
-			c, err := t.Compile(child)
+			c, err := t.compile(child)
 			if err != nil {
 				return nil, err
 			}
 			s, _ := c.String()
+			if s == "()" {
+				// The child only searched for date ranges.
+				continue
+			}
 			tq.repr += s
 		}
 
@@ -90,11 +119,15 @@ func (t TerrierBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
 
 		// Process the children.
 		for _, child := range q.Children {
-			c, err := t.Compile(child)
+			c, err := t.compile(child)
 			if err != nil {
 				return nil, err
 			}
 			s, _ := c.String()
+			if s == "()" {
+				// The child only searched for date ranges.
+				continue
+			}
 			tq.repr += s
 		}
 		tq.repr += ")"
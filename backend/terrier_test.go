@@ -0,0 +1,41 @@
+package backend
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"testing"
+	"time"
+)
+
+// limitedQuery is `limit 1 to (yr="2000-2010" and humans)`, where line 1 is `exp Dementia/ or memory.ti,ab.`.
+var limitedQuery = ir.BooleanQuery{
+	Operator: cqr.OR,
+	Keywords: []ir.Keyword{
+		{QueryString: "Dementia", Fields: []string{fields.MeshHeadings}, Exploded: true},
+		{QueryString: "memory", Fields: []string{fields.TitleAbstract}},
+	},
+	Limits: []ir.Limit{
+		{Field: fields.PublicationDate, Range: &ir.DateRange{
+			Start: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(2010, 12, 31, 0, 0, 0, 0, time.UTC),
+		}},
+		{Field: fields.MeshHeadings, Values: []string{"Humans"}},
+	},
+}
+
+func TestTerrierBackend_Limits(t *testing.T) {
+	q, err := NewTerrierBackend().Compile(limitedQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := q.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Terrier cannot search the date range, so only the species limit restricts the query.
+	expected := "(+(mesh_headings:Dementia title_abstract:memory) +(mesh_headings:Humans))"
+	if got != expected {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
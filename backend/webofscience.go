@@ -0,0 +1,136 @@
+package backend
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"strings"
+)
+
+// webOfScienceEarliestYear is the start of a range of years which has no start, as Web of Science indexes articles
+// published from 1900.
+const webOfScienceEarliestYear = "1900"
+
+// WebOfScienceBackend compiles queries into Web of Science advanced search queries, e.g.
+// `TS=(memory NEAR/3 loss) AND PY=(2000-2010)`. Web of Science does not index a controlled vocabulary, so subject
+// headings are handled by the heading policy.
+type WebOfScienceBackend struct {
+	HeadingPolicy HeadingPolicy
+}
+
+// WebOfScienceQuery is the transmute representation of a Web of Science query.
+type WebOfScienceQuery struct {
+	repr string
+}
+
+func (q WebOfScienceQuery) Representation() (interface{}, error) {
+	return q.repr, nil
+}
+
+func (q WebOfScienceQuery) String() (string, error) {
+	return q.repr, nil
+}
+
+func (q WebOfScienceQuery) StringPretty() (string, error) {
+	return q.repr, nil
+}
+
+// webOfScienceDialect describes the Web of Science search syntax. The topic tag (TS) searches the title, abstract and
+// keywords of an article.
+var webOfScienceDialect = citationDialect{
+	tags: map[string][]string{
+		fields.Title:                   {"TI"},
+		fields.Abstract:                {"AB"},
+		fields.TitleAbstract:           {"TI", "AB"},
+		fields.TextWord:                {"TI", "AB"},
+		fields.Keywords:                {"AK"},
+		fields.MeshHeadings:            {"AK"},
+		fields.MajorFocusMeshHeading:   {"AK"},
+		fields.EmtreeHeadings:          {"AK"},
+		fields.MajorFocusEmtreeHeading: {"AK"},
+		fields.FloatingMeshHeadings:    {"AK"},
+		fields.SubjectHeadings:         {"AK"},
+		fields.AllFields:               {"ALL"},
+		fields.Authors:                 {"AU"},
+		fields.Author:                  {"AU"},
+		fields.AuthorFull:              {"AU"},
+		fields.AuthorLast:              {"AU"},
+		fields.AuthorIdentifier:        {"AI"},
+		fields.AuthorCorporate:         {"GP"},
+		fields.Editor:                  {"ED"},
+		fields.Affiliation:             {"OG"},
+		fields.Journal:                 {"SO"},
+		fields.PublicationType:         {"DT"},
+		fields.PublicationDate:         {"PY"},
+		fields.Language:                {"LA"},
+		fields.GrantNumber:             {"FG"},
+		fields.ISBN:                    {"IS"},
+		fields.PMID:                    {"PMID"},
+	},
+	combined: []citationTag{{"TS", []string{"TI", "AB", "AK"}}},
+	text:     map[string]bool{"TI": true, "AB": true, "AK": true, "TS": true, "ALL": true},
+	fallback: "TS",
+	all:      "ALL",
+	field: func(tag, expression string) string {
+		return fmt.Sprintf("%s=(%s)", tag, expression)
+	},
+	term: citationTerm,
+	dateRange: func(r ir.DateRange) string {
+		start, end := webOfScienceEarliestYear, "3000"
+		if !r.Start.IsZero() {
+			start = r.Start.Format("2006")
+		}
+		if !r.End.IsZero() {
+			end = r.End.Format("2006")
+		}
+		if start == end {
+			return fmt.Sprintf("PY=(%s)", start)
+		}
+		return fmt.Sprintf("PY=(%s-%s)", start, end)
+	},
+	operator: webOfScienceOperator,
+}
+
+// webOfScienceOperator formats the operator of a query. Adjacency is written as NEAR/n, which finds terms in any
+// order, and conjunctions restricted to the same address are written with SAME.
+func webOfScienceOperator(q ir.BooleanQuery) string {
+	operator := strings.ToLower(q.Operator)
+	if strings.HasPrefix(operator, "adj") {
+		distance := operator[3:]
+		if len(distance) == 0 {
+			distance = "0"
+		}
+		if ordered, ok := q.Options[ir.OrderedOption].(bool); ok && ordered {
+			log.Printf("WARNING: Web of Science cannot search for terms in order, searching within %v words in any order instead\n", distance)
+		}
+		return "NEAR/" + distance
+	}
+	switch operator {
+	case cqr.AND:
+		if same, ok := q.Options[ir.SameOption].(bool); ok && same {
+			return "SAME"
+		}
+		return "AND"
+	case cqr.OR, cqr.NOT:
+		return strings.ToUpper(operator)
+	}
+	log.Printf("WARNING: unsupported operator %v, using AND instead\n", q.Operator)
+	return "AND"
+}
+
+// Compile transforms an immediate representation of a query into a Web of Science query.
+func (b WebOfScienceBackend) Compile(q ir.BooleanQuery) (BooleanQuery, error) {
+	q, err := applyHeadingPolicy(applyLimits(q), b.HeadingPolicy, "Web of Science")
+	if err != nil {
+		return nil, err
+	}
+	return WebOfScienceQuery{repr: compileCitation(q, webOfScienceDialect, false)}, nil
+}
+
+// NewWebOfScienceBackend returns a new backend for compiling Web of Science queries, which handles subject headings
+// according to the heading policy, e.g. `NewWebOfScienceBackend(HeadingsAsPhrases)`.
+func NewWebOfScienceBackend(policy HeadingPolicy) WebOfScienceBackend {
+	return WebOfScienceBackend{HeadingPolicy: policy}
+}
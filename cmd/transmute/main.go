@@ -14,9 +14,10 @@ import (
 type args struct {
 	Input        string `arg:"help:File containing a search strategy."`
 	Output       string `arg:"help:File to output the transformed query to."`
-	Parser       string `arg:"help:Which parser to use"`
+	Parser       string `arg:"help:Which parser to use (auto detects the parser from the search strategy)"`
 	Backend      string `arg:"help:Which backend to use."`
 	FieldMapping string `arg:"help:Load a field mapping json file."`
+	Headings     string `arg:"help:How the wos and scopus backends handle subject headings (phrase; omit; or fail)."`
 }
 
 func (args) Version() string {
@@ -100,9 +101,39 @@ func main() {
 
 	// The list of available parsers.
 	parsers := map[string]parser.QueryParser{
-		"medline": parser.NewMedlineParser(),
-		"pubmed":  parser.NewPubMedParser(),
-		"cqr":     parser.NewCQRParser(),
+		"medline":       parser.NewMedlineParser(),
+		"embase":        parser.NewEmbaseParser(),
+		"pubmed":        parser.NewPubMedParser(),
+		"cqr":           parser.NewCQRParser(),
+		"cochrane":      parser.NewCochraneLibParser(),
+		"cinahl":        parser.NewCINAHLParser(),
+		"wos":           parser.NewWebOfScienceParser(),
+		"scopus":        parser.NewScopusParser(),
+		"ir":            parser.NewIrParser(),
+		"elasticsearch": parser.NewElasticsearchParser(),
+		"lucene":        parser.NewLuceneParser(),
+		"proquest":      parser.NewProQuestParser(),
+		"embasecom":     parser.NewEmbaseComParser(),
+	}
+
+	// Parsers which handle the entire query themselves, without the lexer.
+	unlexed := map[string]bool{
+		"cqr":           true,
+		"cochrane":      true,
+		"cinahl":        true,
+		"wos":           true,
+		"scopus":        true,
+		"ir":            true,
+		"elasticsearch": true,
+		"lucene":        true,
+		"proquest":      true,
+		"embasecom":     true,
+	}
+
+	// Citation databases do not have subject headings, so they are handled by a policy.
+	headingPolicy, err := backend.ParseHeadingPolicy(args.Headings)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	// The list of available back-ends.
@@ -112,13 +143,33 @@ func main() {
 		"cqr":           backend.NewCQRBackend(),
 		"terrier":       backend.NewTerrierBackend(),
 		"medline":       backend.NewMedlineBackend(),
+		"embase":        backend.NewOvidBackend(backend.OvidEmbase),
+		"psycinfo":      backend.NewOvidBackend(backend.OvidPsycINFO),
 		"pubmed":        backend.NewPubmedBackend(),
+		"proquest":      backend.NewProQuestBackend(),
+		"embasecom":     backend.NewEmbaseComBackend(),
+		"cochrane":      backend.NewCochraneLibBackend(),
+		"cinahl":        backend.NewCINAHLBackend(),
+		"wos":           backend.NewWebOfScienceBackend(headingPolicy),
+		"scopus":        backend.NewScopusBackend(headingPolicy),
+		"lucene":        backend.NewLuceneBackend(),
+		"bleve":         backend.NewBleveBackend(),
+		"sqlite":        backend.NewSQLiteBackend(),
+		"postgres":      backend.NewPostgresBackend(),
+		"indri":         backend.NewIndriBackend(),
+	}
+
+	// Detect the parser from the search strategy.
+	if args.Parser == "auto" {
+		d := parser.Detect(query)
+		log.Printf("detected a %v search strategy (confidence %.2f)\n", d.Name, d.Confidence)
+		args.Parser = d.Name
 	}
 
 	// Grab the parser.
 	if p, ok := parsers[args.Parser]; ok {
 		transmutePipeline.Parser = p
-		if args.Parser == "medline" {
+		if args.Parser == "medline" || args.Parser == "embase" {
 			transmutePipeline.Options.LexOptions.FormatParenthesis = false
 		} else {
 			transmutePipeline.Options.LexOptions.FormatParenthesis = true
@@ -134,15 +185,7 @@ func main() {
 		log.Fatalf("%v is not a valid backend", args.Backend)
 	}
 
-	if args.Parser != "cqr" {
-		transmutePipeline.Options = pipeline.TransmutePipelineOptions{
-			RequiresLexing: true,
-		}
-	} else {
-		transmutePipeline.Options = pipeline.TransmutePipelineOptions{
-			RequiresLexing: false,
-		}
-	}
+	transmutePipeline.Options.RequiresLexing = !unlexed[args.Parser]
 
 	// Execute the configured transmutePipeline on the query.
 	compiledQuery, err := transmutePipeline.Execute(query)
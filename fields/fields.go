@@ -12,6 +12,8 @@ var (
 	AuthorIdentifier             = "author_identifier"
 	AuthorLast                   = "author_last"
 	Book                         = "book"
+	CandidateTerm                = "candidate_term"
+	CINAHLHeadings               = "cinahl_headings"
 	ConflictOfInterestStatements = "conflict_of_interest_statements"
 	DateCompletion               = "date_completion"
 	DateCreate                   = "date_create"
@@ -19,8 +21,13 @@ var (
 	DateMeSH                     = "date_mesh"
 	DateModification             = "date_modification"
 	DatePublication              = "date_publication"
+	DeviceManufacturer           = "device_manufacturer"
+	DeviceTradeName              = "device_trade_name"
+	DrugManufacturer             = "drug_manufacturer"
+	DrugTradeName                = "drug_trade_name"
 	ECRNNumber                   = "ec_rn_number"
 	Editor                       = "editor"
+	EmtreeHeadings               = "emtree_headings"
 	Filter                       = "filter"
 	GrantNumber                  = "grant_number"
 	ISBN                         = "isbn"
@@ -28,6 +35,7 @@ var (
 	InvestigatorFull             = "investigator_full"
 	Issue                        = "issue"
 	Journal                      = "journal"
+	Keywords                     = "keywords"
 	Language                     = "language"
 	LocationID                   = "location_id"
 	MeSHMajorTopic               = "mesh_major_topic"
@@ -39,6 +47,7 @@ var (
 	PublicationType              = "publication_type"
 	Publisher                    = "publisher"
 	SecondarySourceID            = "secondary_source_id"
+	SubjectHeadings              = "subject_headings"
 	SubjectPersonalName          = "subject_personal_name"
 	SupplementaryConcept         = "supplementary_concept"
 	FloatingMeshHeadings         = "floating_mesh_headings"
@@ -50,7 +59,21 @@ var (
 	Abstract                     = "text"
 	MeshHeadings                 = "mesh_headings"
 	MajorFocusMeshHeading        = "major_mesh_headings"
+	MajorFocusEmtreeHeading      = "major_emtree_headings"
+	MajorFocusCINAHLHeading      = "major_cinahl_headings"
 	PublicationDate              = "publication_date"
 	PublicationStatus            = "publication_status"
 	PMID                         = "pmid"
 )
+
+// Field profiles of the multi-purpose (`.mp.`) field code of Ovid, which searches a different set of fields in each
+// database.
+var (
+	// MedlineMultiPurpose are the fields searched by `.mp.` in Ovid MEDLINE: the title, abstract, original title,
+	// name of substance, subject heading, floating subheading, keyword heading, supplementary concept and unique
+	// identifier fields.
+	MedlineMultiPurpose = []string{Title, Abstract, TransliteratedTitle, ECRNNumber, MeshHeadings, FloatingMeshHeadings, Keywords, SupplementaryConcept, PMID}
+	// EmbaseMultiPurpose are the fields searched by `.mp.` in Ovid Embase: the title, abstract, heading word, drug and
+	// device trade names and manufacturers, original title, keyword heading and candidate term fields.
+	EmbaseMultiPurpose = []string{Title, Abstract, EmtreeHeadings, DrugTradeName, TransliteratedTitle, DeviceManufacturer, DrugManufacturer, DeviceTradeName, Keywords, CandidateTerm}
+)
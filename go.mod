@@ -5,6 +5,7 @@ go 1.12
 require (
 	github.com/alexflint/go-arg v1.0.0
 	github.com/biogo/ncbi v1.0.2 // indirect
+	github.com/blevesearch/bleve v1.0.14
 	github.com/bugra/kmeans v0.0.0-20140831011822-bf06fda928a7 // indirect
 	github.com/chzyer/logex v1.1.10 // indirect
 	github.com/chzyer/readline v0.0.0-20180603132655-2972be24d48e // indirect
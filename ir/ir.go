@@ -1,6 +1,31 @@
 // Package ir contains code relating to the immediate representation query structure of a search strategy.
 package ir
 
+import "time"
+
+// Options which are set on keywords and Boolean queries. Not every search engine supports every option.
+var (
+	// OrderedOption is set on a proximity (adj) query when the keywords must appear in the order they are given.
+	OrderedOption = "ordered"
+	// SameOption is set on an and query when the keywords must appear in the same instance of a field, such as the
+	// same address (e.g. the SAME operator of Web of Science).
+	SameOption = "same"
+	// PhraseOption is set on a keyword that is a phrase, and is either ExactPhrase or LoosePhrase.
+	PhraseOption = "phrase"
+	// ExactPhrase matches the characters of a phrase exactly, including punctuation and stop words.
+	ExactPhrase = "exact"
+	// LoosePhrase matches the words of a phrase in order, ignoring punctuation and allowing for stemming.
+	LoosePhrase = "loose"
+	// SubheadingsOption is set on a subject heading keyword to the slice of subheadings (qualifiers) the heading is
+	// restricted to, using their two letter abbreviations, e.g. `dt` and `th` for `Dementia/dt, th`.
+	SubheadingsOption = "subheadings"
+	// FrequencyOption is set on a keyword to the minimum number of times (an int) the keyword must occur in its fields
+	// for a document to match, e.g. `3` for `dementia.ti,ab./freq=3` in Ovid.
+	FrequencyOption = "frequency"
+	// BoostOption is set on a keyword or query to weight its contribution to the score of a document.
+	BoostOption = "boost"
+)
+
 // Keyword represents a single string inside a search strategy. When these are reported, however, a keyword not only
 // contains the phrase to search, but the fields in the database to search, how it is truncated, and if it is a mesh
 // term, if the term has been exploded.
@@ -10,11 +35,34 @@ type Keyword struct {
 	Exploded    bool                   `json:"exploded"`
 	Truncated   bool                   `json:"truncated"`
 	Options     map[string]interface{} `json:"options"`
+	// Range is set when the keyword matches a range of dates in its fields rather than a string, e.g. publication
+	// dates in PubMed (`2000/01/01:2010/12/31[dp]`).
+	Range *DateRange `json:"range,omitempty"`
+}
+
+// DateRange is a range of dates, inclusive of both bounds. A zero Start or End leaves that side of the range open.
+type DateRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Limit restricts the documents retrieved by a query to those with a value in the field that is either inside the
+// date range, or one of the values (e.g. the `limit 1 to yr="2000-2010"` lines of Ovid MEDLINE).
+type Limit struct {
+	Field  string     `json:"field"`
+	Range  *DateRange `json:"range,omitempty"`
+	Values []string   `json:"values,omitempty"`
 }
 
 // BooleanQuery is the immediate representation of a boolean query for a search engine. This representation groups a
 // list of keywords by a single operator, much like prefix notation. To combine operators, they can be added as children
 // to a query. This means that there is no ambiguity to a query.
+//
+// The operands of a query are its Keywords followed by its Children, in that order. The order only matters for a not
+// query, where the first operand is the set of documents and the remaining operands are excluded from it, e.g.
+// Keywords=[dementia] and Children=[(mice or rats)] is `dementia not (mice or rats)`. A query which is the first
+// operand of a not query can therefore only be followed by other queries, so a keyword that follows it is wrapped in a
+// query of its own.
 type BooleanQuery struct {
 	// A boolean operator (e.g. "and", "or", "not")
 	Operator string `json:"operator"`
@@ -24,4 +72,31 @@ type BooleanQuery struct {
 	Children []BooleanQuery `json:"children"`
 	// Optional parameters of the query
 	Options map[string]interface{}
+	// Limits which restrict the documents retrieved by the query
+	Limits []Limit `json:"limits,omitempty"`
+}
+
+// Keywords converts a limit into the keywords it is satisfied by, one of which must match a document. This is useful
+// for search engines which do not have a dedicated way to limit a query.
+func (l Limit) Keywords() []Keyword {
+	if l.Range != nil {
+		return []Keyword{{QueryString: l.Range.String(), Fields: []string{l.Field}, Range: l.Range}}
+	}
+	keywords := make([]Keyword, len(l.Values))
+	for i, value := range l.Values {
+		keywords[i] = Keyword{QueryString: value, Fields: []string{l.Field}}
+	}
+	return keywords
+}
+
+// String formats a date range as `start:end`, with each bound formatted as `YYYY/MM/DD`. Open bounds are empty.
+func (r DateRange) String() string {
+	var start, end string
+	if !r.Start.IsZero() {
+		start = r.Start.Format("2006/01/02")
+	}
+	if !r.End.IsZero() {
+		end = r.End.Format("2006/01/02")
+	}
+	return start + ":" + end
 }
@@ -0,0 +1,85 @@
+package ir
+
+// MeSHSubheadings maps the two letter abbreviations of MeSH subheadings (qualifiers), which are the values of the
+// SubheadingsOption, onto their names.
+var MeSHSubheadings = map[string]string{
+	"ab": "abnormalities",
+	"ad": "administration & dosage",
+	"ae": "adverse effects",
+	"ag": "agonists",
+	"ah": "anatomy & histology",
+	"ai": "antagonists & inhibitors",
+	"an": "analysis",
+	"bi": "biosynthesis",
+	"bl": "blood",
+	"bs": "blood supply",
+	"cf": "cerebrospinal fluid",
+	"ch": "chemistry",
+	"ci": "chemically induced",
+	"cl": "classification",
+	"cn": "congenital",
+	"co": "complications",
+	"cs": "chemical synthesis",
+	"ct": "contraindications",
+	"cy": "cytology",
+	"de": "drug effects",
+	"df": "deficiency",
+	"dg": "diagnostic imaging",
+	"dh": "diet therapy",
+	"di": "diagnosis",
+	"dt": "drug therapy",
+	"du": "diagnostic use",
+	"ec": "economics",
+	"ed": "education",
+	"eh": "ethnology",
+	"em": "embryology",
+	"en": "enzymology",
+	"ep": "epidemiology",
+	"es": "ethics",
+	"et": "etiology",
+	"ge": "genetics",
+	"gd": "growth & development",
+	"hi": "history",
+	"ic": "instrumentation",
+	"im": "immunology",
+	"in": "injuries",
+	"ir": "innervation",
+	"is": "isolation & purification",
+	"lj": "legislation & jurisprudence",
+	"ma": "manpower",
+	"me": "metabolism",
+	"mi": "microbiology",
+	"mo": "mortality",
+	"mt": "methods",
+	"nu": "nursing",
+	"og": "organization & administration",
+	"pa": "pathology",
+	"pc": "prevention & control",
+	"pd": "pharmacology",
+	"ph": "physiology",
+	"pk": "pharmacokinetics",
+	"po": "poisoning",
+	"pp": "physiopathology",
+	"ps": "parasitology",
+	"px": "psychology",
+	"py": "pathogenicity",
+	"re": "radiation effects",
+	"rh": "rehabilitation",
+	"rt": "radiotherapy",
+	"sc": "secondary",
+	"sd": "supply & distribution",
+	"se": "secretion",
+	"sn": "statistics & numerical data",
+	"st": "standards",
+	"su": "surgery",
+	"th": "therapy",
+	"tm": "trends",
+	"to": "toxicity",
+	"tr": "transplantation",
+	"tu": "therapeutic use",
+	"ul": "ultrastructure",
+	"ur": "urine",
+	"ut": "utilization",
+	"ve": "veterinary",
+	"vi": "virology",
+}
@@ -5,16 +5,23 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 var (
-	numberRegex, _ = regexp.Compile("^[0-9]+$")
-	prefixRegex, _ = regexp.Compile("^(or|and|not|OR|AND|NOT|adj[0-9]+)/[0-9]+-[0-9]+$")
-	namedRegex, _  = regexp.Compile("^(or|and|not|OR|AND|NOT|adj[0-9]+)/[0-9]+,[0-9]+$")
+	numberRegex, _  = regexp.Compile("^[0-9]+$")
+	prefixRegex, _  = regexp.Compile("^(or|and|not|OR|AND|NOT|adj[0-9]+)/[0-9]+-[0-9]+$")
+	namedRegex, _   = regexp.Compile("^(or|and|not|OR|AND|NOT|adj[0-9]+)/[0-9]+,[0-9]+$")
+	limitRegex, _   = regexp.Compile(`^(?i:limit)\s+([0-9]+)\s+(?i:to)\s+(.+)$`)
+	historyRegex, _ = regexp.Compile(`^#[0-9]+\s`)
 )
 
+// LimitOperator is the operator of a node created from a limit line (e.g. `limit 5 to yr="2000-2010"`). The node has
+// a single child, the query being limited, and the value of the node is the restriction (e.g. `yr="2000-2010"`).
+const LimitOperator = "limit"
+
 // Node contains the encoding of the query as a tree.
 type Node struct {
 	Value     string
@@ -120,7 +127,14 @@ func ExpandQuery(query map[int]map[string]map[int]string) (Node, error) {
 	var recursionDepth int
 	expand = func(node Node, query map[int]map[string]map[int]string) (Node, error) {
 		recursionDepth++
-		for k, v := range query[node.Reference][node.Operator] {
+		// The lines are expanded in order so that the tree is the same each time a query is lexed.
+		var references []int
+		for k := range query[node.Reference][node.Operator] {
+			references = append(references, k)
+		}
+		sort.Ints(references)
+		for _, k := range references {
+			v := query[node.Reference][node.Operator][k]
 			// If we find a query in the top-level, process that.
 			if innerQuery, ok := query[k]; ok {
 				for operator := range innerQuery {
@@ -148,11 +162,26 @@ func ExpandQuery(query map[int]map[string]map[int]string) (Node, error) {
 // Lex creates the abstract syntax tree for the query. It will preprocess the query to try to normalise it. This
 // function only creates the tree; it does not attempt to parse the individual lines in the query.
 func Lex(query string, options LexOptions) (Node, error) {
+	// Search histories (e.g. PubMed Advanced Search) refer to previous searches as `#n` rather than by line number, so
+	// these are expanded into a single query.
+	query = strings.TrimSpace(query)
+	if strings.Contains(query, "\n") && historyRegex.MatchString(query) {
+		expanded, err := ExpandReferences(query, "#")
+		if err != nil {
+			return Node{}, err
+		}
+		query = fmt.Sprintf("(%s)", expanded)
+	}
+
 	query = PreProcess(query, options)
 
 	// reference -> operator -> reference -> query_string
 	depth1Query := map[int]map[string]map[int]string{}
 	queries := map[int]string{}
+	// reference -> restriction of a limit line
+	limits := map[int]string{}
+	// reference -> references of an infix line, in the order they are written
+	order := map[int][]int{}
 
 	var err error
 	// In the first pass, we create a depth-1 query structure.
@@ -175,6 +204,7 @@ func Lex(query string, options LexOptions) (Node, error) {
 			if err != nil {
 				return Node{}, err
 			}
+			order[reference+1] = infixReferences(line)
 		} else if prefixRegex.MatchString(line) {
 			// Assume we are looking at `OP/N-N
 			depth1Query[reference+1], err = ProcessPrefixOperators(queries, line)
@@ -187,6 +217,16 @@ func Lex(query string, options LexOptions) (Node, error) {
 			if err != nil {
 				return Node{}, err
 			}
+			parts := strings.Split(line, "/")
+			order[reference+1] = infixReferences(strings.Join(strings.Split(parts[1], ","), fmt.Sprintf(" %v ", parts[0])))
+		} else if limit := limitRegex.FindStringSubmatch(line); limit != nil {
+			// Assume we are looking at `limit N to ...`.
+			ref, err := strconv.Atoi(limit[1])
+			if err != nil {
+				return Node{}, err
+			}
+			depth1Query[reference+1] = map[string]map[int]string{LimitOperator: {ref: queries[ref-1]}}
+			limits[reference+1] = strings.TrimSpace(limit[2])
 		}
 
 		// We can be pretty sure that the string is for a query
@@ -197,6 +237,53 @@ func Lex(query string, options LexOptions) (Node, error) {
 		return Node{Value: queries[0], Reference: 1}, nil
 	} else {
 		// In the second pass, we then parse a second time recursively to expand the inner queries at depth 1.
-		return ExpandQuery(depth1Query)
+		ast, err := ExpandQuery(depth1Query)
+		if err != nil {
+			return Node{}, err
+		}
+		return setOrder(setLimits(ast, limits), order), nil
+	}
+}
+
+// infixReferences returns the references of an infix line (e.g. `2 not 1`), in the order they are written.
+func infixReferences(line string) []int {
+	var references []int
+	for i, token := range strings.Split(line, " ") {
+		if i%2 != 0 {
+			continue
+		}
+		if reference, err := strconv.Atoi(strings.TrimSpace(token)); err == nil {
+			references = append(references, reference)
+		}
+	}
+	return references
+}
+
+// setOrder orders the children of each node in the tree in the order they are referred to by the line the node was
+// created from. The order is the order of the operands of the line, which matters for not lines (e.g. `2 not 1`).
+func setOrder(node Node, order map[int][]int) Node {
+	if references, ok := order[node.Reference]; ok {
+		position := make(map[int]int)
+		for i, reference := range references {
+			position[reference] = i
+		}
+		sort.SliceStable(node.Children, func(i, j int) bool {
+			return position[node.Children[i].Reference] < position[node.Children[j].Reference]
+		})
+	}
+	for i, child := range node.Children {
+		node.Children[i] = setOrder(child, order)
+	}
+	return node
+}
+
+// setLimits sets the value of each limit node in the tree to the restriction of the limit line it was created from.
+func setLimits(node Node, limits map[int]string) Node {
+	if node.Operator == LimitOperator {
+		node.Value = limits[node.Reference]
+	}
+	for i, child := range node.Children {
+		node.Children[i] = setLimits(child, limits)
 	}
+	return node
 }
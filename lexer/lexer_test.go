@@ -16,7 +16,7 @@ var (
 )
 
 func Test_Lex_MedlineQuery(t *testing.T) {
-	ast, err := Lex(string(medlineQueryString))
+	ast, err := Lex(string(medlineQueryString), LexOptions{})
 	if err != nil {
 		panic(err)
 	}
@@ -29,7 +29,7 @@ func Test_Lex_MedlineQuery(t *testing.T) {
 }
 
 func Test_Lex_PubMedQuery(t *testing.T) {
-	ast, err := Lex(string(pubmedQueryString))
+	ast, err := Lex(string(pubmedQueryString), LexOptions{})
 	if err != nil {
 		panic(err)
 	}
@@ -40,3 +40,33 @@ func Test_Lex_PubMedQuery(t *testing.T) {
 		t.Fatalf("expected %v children, got %v", expected, got)
 	}
 }
+
+func Test_Lex_PubMedHistory(t *testing.T) {
+	ast, err := Lex(`#4 #3 AND english[la]
+#3 #1 OR #2
+#2 "Alzheimer Disease"[Mesh]
+#1 dementia[tiab]`, LexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `(((dementia[tiab]) OR ("Alzheimer Disease"[Mesh])) AND english[la])`
+	got := ast.Value
+	if expected != got || ast.Reference != 1 || len(ast.Children) != 0 {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func Test_Lex_OperandOrder(t *testing.T) {
+	ast, err := Lex(`1. dementia.ti.
+2. (mice or rats).ti.
+3. 2 not 1`, LexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The lines are the operands of line 3 in the order they are written.
+	if len(ast.Children) != 2 || ast.Children[0].Reference != 2 || ast.Children[1].Reference != 1 {
+		t.Fatalf("expected line 2 to be followed by line 1, got %v", ast.Children)
+	}
+}
@@ -0,0 +1,92 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var hitsRegex, _ = regexp.Compile(`\t[0-9]+$`)
+
+// ExpandReferences rewrites a search strategy where lines refer to other lines by number (e.g. `#3 #1 OR #2`) into
+// a single expression. Each reference is replaced by the parenthesised line it refers to, and the expansion of the
+// highest numbered line in the strategy is returned. The prefix is the string that precedes line numbers, such as `#`
+// for the Cochrane Library and PubMed, or `S` for EBSCOhost. Lines may optionally be labelled with their number;
+// unlabelled lines are numbered sequentially. Labelled lines may appear in any order, since some databases (e.g. Web
+// of Science) export the most recent line first.
+func ExpandReferences(query string, prefix string) (string, error) {
+	labelRegex, err := regexp.Compile(`^(?:` + regexp.QuoteMeta(prefix) + `)?([0-9]+)\.?\s+`)
+	if err != nil {
+		return "", err
+	}
+	boundary := ""
+	if len(prefix) > 0 && !strings.ContainsAny(prefix[:1], "#$@") {
+		boundary = `\b`
+	}
+	referenceRegex, err := regexp.Compile(boundary + regexp.QuoteMeta(prefix) + `([0-9]+)\b`)
+	if err != nil {
+		return "", err
+	}
+
+	lines := map[int]string{}
+	last, highest := 0, 0
+	for _, line := range strings.Split(query, "\n") {
+		// Some exports (e.g. the Cochrane Library) append the number of hits to each line.
+		line = strings.TrimSpace(hitsRegex.ReplaceAllString(strings.TrimRight(line, " \r"), ""))
+		if len(line) == 0 {
+			continue
+		}
+
+		reference := last + 1
+		if label := labelRegex.FindStringSubmatch(line); label != nil {
+			reference, err = strconv.Atoi(label[1])
+			if err != nil {
+				return "", err
+			}
+			line = line[len(label[0]):]
+		}
+
+		lines[reference] = strings.TrimSpace(line)
+		last = reference
+		if reference > highest {
+			highest = reference
+		}
+	}
+
+	if len(lines) == 0 {
+		return "", errors.New("no lines found in query")
+	}
+
+	var expand func(reference int, seen map[int]bool) (string, error)
+	expand = func(reference int, seen map[int]bool) (string, error) {
+		if seen[reference] {
+			return "", errors.New(fmt.Sprintf("unable to parse, found a recursive reference on line %v", reference))
+		}
+		seen[reference] = true
+		defer delete(seen, reference)
+
+		var missing error
+		line := referenceRegex.ReplaceAllStringFunc(lines[reference], func(s string) string {
+			n, err := strconv.Atoi(referenceRegex.FindStringSubmatch(s)[1])
+			if err != nil {
+				missing = err
+				return s
+			}
+			if _, ok := lines[n]; !ok {
+				missing = errors.New(fmt.Sprintf("line %v refers to line %v, which does not exist", reference, n))
+				return s
+			}
+			expanded, err := expand(n, seen)
+			if err != nil {
+				missing = err
+				return s
+			}
+			return fmt.Sprintf("(%s)", expanded)
+		})
+		return line, missing
+	}
+
+	return expand(highest, map[int]bool{})
+}
@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"github.com/hscells/transmute/lexer"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// CINAHLFieldMapping maps EBSCOhost CINAHL field codes. Subject headings in CINAHL come from the CINAHL Headings
+// thesaurus, which is why they are mapped to their own fields.
+var CINAHLFieldMapping = map[string][]string{
+	"AB":      {fields.Abstract},
+	"AF":      {fields.Affiliation},
+	"AU":      {fields.Authors},
+	"DT":      {fields.PublicationDate},
+	"IB":      {fields.ISBN},
+	"KW":      {fields.Keywords},
+	"LA":      {fields.Language},
+	"MH":      {fields.CINAHLHeadings},
+	"MM":      {fields.MajorFocusCINAHLHeading},
+	"MW":      {fields.CINAHLHeadings},
+	"PM":      {fields.PMID},
+	"PT":      {fields.PublicationType},
+	"SO":      {fields.Journal},
+	"SU":      {fields.CINAHLHeadings},
+	"TI":      {fields.Title},
+	"TX":      {fields.AllFields},
+	"default": {fields.AllFields},
+}
+
+var (
+	cinahlProximityRegexp, _   = regexp.Compile(`^([NW])([0-9]+)$`)
+	cinahlSubheadingsRegexp, _ = regexp.Compile(`^(.*?)(\+)?((?:/[A-Za-z]{2})+)$`)
+)
+
+// CINAHLTransformer is an implementation of a QueryTransformer for EBSCOhost CINAHL search strategies.
+type CINAHLTransformer struct{}
+
+// keyword transforms a term into a keyword. A trailing `+` explodes the keyword.
+func (c CINAHLTransformer) keyword(query string) ir.Keyword {
+	query = strings.TrimSpace(query)
+	exploded := false
+	if strings.HasSuffix(query, `+"`) {
+		query = strings.TrimSuffix(query, `+"`) + `"`
+		exploded = true
+	} else if strings.HasSuffix(query, "+") {
+		query = strings.TrimSuffix(query, "+")
+		exploded = true
+	}
+	return ir.Keyword{
+		QueryString: query,
+		Exploded:    exploded,
+		Truncated:   isTruncated(query),
+	}
+}
+
+// TransformSingle implements the transformation of a single term, e.g. `MH "Dementia+"`.
+func (c CINAHLTransformer) TransformSingle(query string, mapping map[string][]string) ir.Keyword {
+	q := parseExpression(query, c.dialect(mapping), mapping)
+	if len(q.Keywords) == 0 {
+		return ir.Keyword{}
+	}
+	return c.heading(q.Keywords[0])
+}
+
+// heading removes the quotes surrounding subject headings, as they are not part of the heading itself. Headings may be
+// restricted to subheadings, e.g. `(MH "Dementia+/DT/TH")`.
+func (c CINAHLTransformer) heading(keyword ir.Keyword) ir.Keyword {
+	for _, field := range keyword.Fields {
+		if field == fields.CINAHLHeadings || field == fields.MajorFocusCINAHLHeading {
+			keyword.QueryString = strings.Trim(keyword.QueryString, `"`)
+			if subheadings := cinahlSubheadingsRegexp.FindStringSubmatch(keyword.QueryString); subheadings != nil {
+				keyword.QueryString = subheadings[1]
+				keyword.Exploded = keyword.Exploded || len(subheadings[2]) > 0
+				keyword.Options = map[string]interface{}{
+					ir.SubheadingsOption: strings.Split(strings.ToLower(strings.TrimPrefix(subheadings[3], "/")), "/"),
+				}
+			}
+			break
+		}
+	}
+	return keyword
+}
+
+// TransformNested implements the transformation of a CINAHL search strategy. Lines are numbered with an `S` and may
+// refer to previous lines (e.g. `S3 S1 AND S2`). These are expanded into a single query.
+func (c CINAHLTransformer) TransformNested(query string, mapping map[string][]string) ir.BooleanQuery {
+	expanded, err := lexer.ExpandReferences(query, "S")
+	if err != nil {
+		log.Println(err)
+		return ir.BooleanQuery{}
+	}
+	return mapKeywords(parseExpression(expanded, c.dialect(mapping), mapping), func(keyword ir.Keyword) ir.Keyword {
+		// Publication dates are searched as ranges, e.g. `DT 20000101-20101231`.
+		if len(keyword.Fields) == 1 && keyword.Fields[0] == fields.PublicationDate {
+			if r, ok := proquestDateRange(keyword.QueryString); ok {
+				keyword.Range = r
+			}
+		}
+		return c.heading(keyword)
+	})
+}
+
+// dialect describes the EBSCOhost search syntax.
+func (c CINAHLTransformer) dialect(mapping map[string][]string) expressionDialect {
+	return expressionDialect{
+		operator: func(token string) (expressionOperator, bool) {
+			switch token {
+			case "OR", "or":
+				return expressionOperator{operator: cqr.OR, precedence: 1}, true
+			case "AND", "and":
+				return expressionOperator{operator: cqr.AND, precedence: 2}, true
+			case "NOT", "not":
+				return expressionOperator{operator: cqr.NOT, precedence: 2}, true
+			}
+			// Nn finds terms within n words in any order, and Wn finds them within n words in the order entered.
+			if proximity := cinahlProximityRegexp.FindStringSubmatch(token); proximity != nil {
+				op := expressionOperator{operator: fmt.Sprintf("adj%s", proximity[2]), precedence: 3}
+				if proximity[1] == "W" {
+					op.options = map[string]interface{}{ir.OrderedOption: true}
+				}
+				return op, true
+			}
+			return expressionOperator{}, false
+		},
+		prefix: func(token string) ([]string, bool) {
+			if token == "default" {
+				return nil, false
+			}
+			f, ok := mapping[token]
+			return f, ok
+		},
+		keyword: c.keyword,
+	}
+}
+
+// NewCINAHLParser creates a new parser for EBSCOhost CINAHL search strategies.
+func NewCINAHLParser() QueryParser {
+	return QueryParser{FieldMapping: CINAHLFieldMapping, Parser: CINAHLTransformer{}}
+}
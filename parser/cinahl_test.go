@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"github.com/hscells/transmute/ir"
+	"testing"
+)
+
+var (
+	cinahlQueryString = `S1 (MH "Dementia+")
+S2 (MM "Alzheimer's Disease")
+S3 TI dementia OR AB dementia
+S4 TI (memory N5 loss)
+S5 AB (mild W3 impairment)
+S6 S1 OR S2 OR S3 OR S4 OR S5`
+)
+
+func TestCINAHL_BooleanQuery_Terms(t *testing.T) {
+	queryRep := NewCINAHLParser().Parse(lexerNode(cinahlQueryString))
+
+	expected := 8
+	got := len(queryRep.Terms())
+	if expected != got {
+		t.Fatalf("Expected %v terms, got %v", expected, got)
+	}
+}
+
+func TestCINAHL_BooleanQuery_FieldCount(t *testing.T) {
+	queryRep := NewCINAHLParser().Parse(lexerNode(cinahlQueryString))
+
+	counts := queryRep.FieldCount()
+	for field, expected := range map[string]int{
+		"cinahl_headings":       1,
+		"major_cinahl_headings": 1,
+		"title":                 3,
+		"text":                  3,
+	} {
+		if got := counts[field]; got != expected {
+			t.Fatalf("Expected %v %v fields, got %v", expected, field, got)
+		}
+	}
+}
+
+func TestCINAHL_Headings(t *testing.T) {
+	k := CINAHLTransformer{}.TransformSingle(`MH "Dementia+"`, CINAHLFieldMapping)
+	if k.QueryString != "Dementia" || !k.Exploded {
+		t.Fatalf("expected an exploded Dementia heading, got %v", k)
+	}
+
+	k = CINAHLTransformer{}.TransformNested(`(MH "Dementia+/DT/TH")`, CINAHLFieldMapping).Keywords[0]
+	subheadings, ok := k.Options[ir.SubheadingsOption].([]string)
+	if k.QueryString != "Dementia" || !k.Exploded || !ok || len(subheadings) != 2 || subheadings[0] != "dt" {
+		t.Fatalf("expected an exploded Dementia heading with subheadings, got %v", k)
+	}
+}
+
+func TestCINAHL_Dates(t *testing.T) {
+	q := CINAHLTransformer{}.TransformNested(`S1 TI dementia
+S2 DT 20000101-20101231
+S3 S1 AND S2`, CINAHLFieldMapping)
+	if len(q.Keywords) != 2 || q.Keywords[1].Range == nil {
+		t.Fatalf("expected a publication date range, got %v", q)
+	}
+	if q.Keywords[1].Range.Start.Year() != 2000 || q.Keywords[1].Range.End.Year() != 2010 {
+		t.Fatalf("expected the years 2000 to 2010, got %v", q.Keywords[1].Range)
+	}
+}
+
+func TestCINAHL_Proximity(t *testing.T) {
+	queryRep := NewCINAHLParser().Parse(lexerNode(cinahlQueryString))
+
+	var unordered, ordered int
+	for _, child := range queryRep.Children {
+		if child.Operator == "adj5" && child.Options[ir.OrderedOption] == nil {
+			unordered++
+		}
+		if child.Operator == "adj3" && child.Options[ir.OrderedOption] == true {
+			ordered++
+		}
+	}
+	if unordered != 1 || ordered != 1 {
+		t.Fatalf("expected one ordered and one unordered proximity query, got %v", queryRep.Children)
+	}
+}
@@ -2,70 +2,170 @@ package parser
 
 import (
 	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
 	"github.com/hscells/transmute/ir"
-	"github.com/prataprc/goparsec"
+	"github.com/hscells/transmute/lexer"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
-type CochraneLibParser struct {
+var CochraneLibFieldMapping = map[string][]string{
+	"ti":       {fields.Title},
+	"ab":       {fields.Abstract},
+	"kw":       {fields.Keywords},
+	"tw":       {fields.TextWord},
+	"mh":       {fields.MeshHeadings},
+	"au":       {fields.Authors},
+	"so":       {fields.Journal},
+	"pt":       {fields.PublicationType},
+	"dn":       {fields.PMID},
+	"ti,ab":    {fields.TitleAbstract},
+	"ti,ab,kw": {fields.TitleAbstract, fields.Keywords},
+	"default":  {fields.AllFields},
 }
 
-func (CochraneLibParser) TransformSingle(query string, mapping map[string][]string) ir.Keyword {
-	panic("implement me")
-}
+var (
+	cochraneDescriptorRegexp, _ = regexp.Compile(`(?i)^MeSH descriptor:?\s*\[([^\]]+)\](\s+explode all trees|\s+explode selected trees|\s+this term only)?(?:\s+and\s+with\s+qualifiers?(?:\(s\))?:\s*\[([^\]]+)\])?`)
+	cochraneFieldRegexp, _      = regexp.Compile(`^:[a-zA-Z]+(,[a-zA-Z]+)*$`)
+	cochraneNearRegexp, _       = regexp.Compile(`(?i)^NEAR(/[0-9]+)?$`)
+)
 
-func (CochraneLibParser) TransformNested(query string, mapping map[string][]string) ir.BooleanQuery {
-	var array parsec.Parser
+// CochraneLibParser is an implementation of a QueryTransformer for Cochrane Library (CENTRAL) search strategies.
+type CochraneLibParser struct{}
 
-	ast := parsec.NewAST("CochraneLibrary", 100)
+// TransformFields maps a string of fields such as `:ti,ab,kw` into a slice of mapped fields.
+func (c CochraneLibParser) TransformFields(fieldsString string, mapping map[string][]string) []string {
+	return transformFieldSuffix(fieldsString, mapping)
+}
 
-	id := parsec.And(
-		func(ns []parsec.ParsecNode) parsec.ParsecNode {
-			t := ns[0].(*parsec.Terminal)
-			t.Value = `[` + t.Value + `]`
-			return t
-		},
-		parsec.Token(`("[a-zA-Z0-9*? ]+"|[a-zA-Z0-9*?]+)`, "ID"),
-	)
+// transformFieldSuffix maps a string of fields such as `:ti,ab,kw` into a slice of mapped fields. Combinations of
+// fields which do not have a mapping are mapped field by field.
+func transformFieldSuffix(fieldsString string, mapping map[string][]string) []string {
+	fieldsString = strings.ToLower(strings.TrimPrefix(fieldsString, ":"))
+	if f, ok := mapping[fieldsString]; ok {
+		return f
+	}
+	var mapped []string
+	for _, field := range strings.Split(fieldsString, ",") {
+		if f, ok := mapping[field]; ok {
+			mapped = append(mapped, f...)
+		} else {
+			log.Printf("the field `%v` does not have a mapping defined\n", field)
+		}
+	}
+	if len(mapped) == 0 {
+		return mapping["default"]
+	}
+	return mapped
+}
 
-	operator := parsec.And(
-		func(ns []parsec.ParsecNode) parsec.ParsecNode {
-			t := ns[0].(*parsec.Terminal)
-			t.Value = `$` + t.Value + `$`
-			return t
-		},
-		parsec.Token(`(AND|OR|NOT){1}`, "OPERATOR"),
-	)
+// keyword transforms a term into a keyword, leaving the fields empty when the term does not specify any.
+func (c CochraneLibParser) keyword(query string, mapping map[string][]string) ir.Keyword {
+	query = strings.TrimSpace(query)
 
-	proximity := parsec.And(
-		func(ns []parsec.ParsecNode) parsec.ParsecNode {
-			t := ns[0].(*parsec.Terminal)
-			t.Value = `$` + t.Value + `$`
-			return t
-		},
-		parsec.Token(`(NEAR/[0-9]+|NEAR|NEXT){1}`, "PROXIMITY"),
-	)
+	if descriptor := cochraneDescriptorRegexp.FindStringSubmatch(query); descriptor != nil {
+		k := ir.Keyword{
+			QueryString: strings.TrimSpace(descriptor[1]),
+			Fields:      mapping["mh"],
+			Exploded:    strings.Contains(strings.ToLower(descriptor[2]), "explode"),
+		}
+		// Qualifiers are written with their names and abbreviations, e.g. `[drug therapy - DT, therapy - TH]`.
+		if len(descriptor[3]) > 0 {
+			var subheadings []string
+			for _, qualifier := range strings.Split(descriptor[3], ",") {
+				qualifier = strings.TrimSpace(qualifier)
+				if i := strings.LastIndex(qualifier, "-"); i >= 0 {
+					qualifier = qualifier[i+1:]
+				}
+				subheadings = append(subheadings, strings.ToLower(strings.TrimSpace(qualifier)))
+			}
+			k.Options = map[string]interface{}{ir.SubheadingsOption: subheadings}
+		}
+		return k
+	}
 
-	fields := parsec.And(
-		func(ns []parsec.ParsecNode) parsec.ParsecNode {
-			t := ns[0].(*parsec.Terminal)
-			t.Value = `~` + t.Value + `~`
-			return t
-		},
-		parsec.Token(`:([a-z]+,|[a-z]+)+`, "FIELDS"),
-	)
+	var queryFields []string
+	if i := strings.LastIndex(query, ":"); i > 0 && !strings.HasSuffix(query, `"`) && cochraneFieldRegexp.MatchString(query[i:]) {
+		queryFields = c.TransformFields(query[i:], mapping)
+		query = query[:i]
+	}
 
-	opensqr := parsec.Atom(`(`, "OPENPAREN")
-	closesqr := parsec.Atom(`)`, "CLOSEPAREN")
-	space := ast.Maybe("space", nil, parsec.Atom(` `, "SPACE"))
+	return ir.Keyword{
+		QueryString: query,
+		Fields:      queryFields,
+		Truncated:   isTruncated(query),
+	}
+}
 
-	item := ast.OrdChoice("item", nil, operator, proximity, id, fields, &array)
-	itemsep := ast.And("itemsep", nil, item, space)
-	items := ast.Kleene("items", nil, itemsep, nil)
-	array = ast.And("array", nil, opensqr, items, closesqr)
+// TransformSingle implements the transformation of a single term such as `dementia:ti,ab` or a MeSH descriptor line.
+func (c CochraneLibParser) TransformSingle(query string, mapping map[string][]string) ir.Keyword {
+	k := c.keyword(query, mapping)
+	if len(k.Fields) == 0 {
+		k.Fields = mapping["default"]
+	}
+	return k
+}
+
+// TransformNested implements the transformation of a Cochrane Library search strategy. Lines may refer to previous
+// lines (e.g. `#3 #1 OR #2`), and are expanded into a single query.
+func (c CochraneLibParser) TransformNested(query string, mapping map[string][]string) ir.BooleanQuery {
+	expanded, err := lexer.ExpandReferences(query, "#")
+	if err != nil {
+		log.Println(err)
+		return ir.BooleanQuery{}
+	}
+	return parseExpression(expanded, c.dialect(mapping), mapping)
+}
 
-	s := parsec.NewScanner([]byte(query))
-	node, _ := ast.Parsewith(array, s)
-	fmt.Println(node.GetValue())
+// dialect describes the Cochrane Library search syntax.
+func (c CochraneLibParser) dialect(mapping map[string][]string) expressionDialect {
+	return expressionDialect{
+		atoms: []*regexp.Regexp{cochraneDescriptorRegexp},
+		split: func(token string) []string {
+			if i := strings.Index(token, ":"); i > 0 && cochraneFieldRegexp.MatchString(token[i:]) {
+				return []string{token[:i], token[i:]}
+			}
+			return []string{token}
+		},
+		operator: func(token string) (expressionOperator, bool) {
+			switch strings.ToUpper(token) {
+			case "OR":
+				return expressionOperator{operator: cqr.OR, precedence: 1}, true
+			case "AND":
+				return expressionOperator{operator: cqr.AND, precedence: 2}, true
+			case "NOT":
+				return expressionOperator{operator: cqr.NOT, precedence: 2}, true
+			case "NEXT":
+				return expressionOperator{operator: "adj1", precedence: 3, options: map[string]interface{}{ir.OrderedOption: true}}, true
+			}
+			if near := cochraneNearRegexp.FindStringSubmatch(token); near != nil {
+				// The Cochrane Library uses a distance of 6 when none is specified.
+				distance := 6
+				if len(near[1]) > 0 {
+					distance, _ = strconv.Atoi(near[1][1:])
+				}
+				return expressionOperator{operator: fmt.Sprintf("adj%d", distance), precedence: 3}, true
+			}
+			return expressionOperator{}, false
+		},
+		suffix: func(token string) ([]string, bool) {
+			if cochraneFieldRegexp.MatchString(token) {
+				return c.TransformFields(token, mapping), true
+			}
+			return nil, false
+		},
+		keyword: func(term string) ir.Keyword {
+			return c.keyword(term, mapping)
+		},
+		// Terms which are not separated by an operator are combined with AND.
+		implicit: &expressionOperator{operator: cqr.AND, precedence: 2},
+	}
+}
 
-	return ir.BooleanQuery{}
+// NewCochraneLibParser creates a new parser for Cochrane Library search strategies.
+func NewCochraneLibParser() QueryParser {
+	return QueryParser{FieldMapping: CochraneLibFieldMapping, Parser: CochraneLibParser{}}
 }
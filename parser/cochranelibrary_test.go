@@ -1,10 +1,107 @@
 package parser
 
-import "testing"
+import (
+	"github.com/hscells/transmute/ir"
+	"github.com/hscells/transmute/lexer"
+	"testing"
+)
+
+var (
+	cochraneQueryString = `#1 MeSH descriptor: [Dementia] explode all trees
+#2 MeSH descriptor: [Delirium] this term only
+#3 (dementia* or alzheimer*):ti,ab,kw
+#4 "cognitive impairment" NEAR/3 (mild or early)
+#5 memory NEXT loss
+#6 #1 or #2 or #3 or #4 or #5`
+)
 
 func TestCochraneLibraryParse(t *testing.T) {
 	cl := CochraneLibParser{}
 
-	cl.TransformNested(`("lung cancer":tw)`, nil)
-	cl.TransformNested(`(dialy?is:ti AND (kidney*:ti,ab NEAR/3 renal) AND "lung cancer"):tw,ab`, nil)
+	q := cl.TransformNested(`("lung cancer":tw)`, CochraneLibFieldMapping)
+	if len(q.Keywords) != 1 || q.Keywords[0].QueryString != `"lung cancer"` {
+		t.Fatalf("expected a single keyword, got %v", q)
+	}
+
+	q = cl.TransformNested(`(dialy?is:ti AND (kidney*:ti,ab NEAR/3 renal) AND "lung cancer"):tw,ab`, CochraneLibFieldMapping)
+	if q.Operator != "and" {
+		t.Fatalf("expected and, got %v", q.Operator)
+	}
+	if len(q.Keywords) != 2 || len(q.Children) != 1 {
+		t.Fatalf("expected 2 keywords and 1 child, got %v", q)
+	}
+	if q.Children[0].Operator != "adj3" {
+		t.Fatalf("expected adj3, got %v", q.Children[0].Operator)
+	}
+	if !q.Keywords[0].Truncated {
+		t.Fatalf("expected %v to be truncated", q.Keywords[0])
+	}
+}
+
+func TestCochraneLibrary_BooleanQuery_Terms(t *testing.T) {
+	q := NewCochraneLibParser().Parse(lexerNode(cochraneQueryString))
+
+	expected := 9
+	got := len(q.Terms())
+	if expected != got {
+		t.Fatalf("Expected %v terms, got %v", expected, got)
+	}
+
+	if q.Operator != "or" {
+		t.Fatalf("expected or, got %v", q.Operator)
+	}
+
+	if !q.Keywords[0].Exploded || q.Keywords[1].Exploded {
+		t.Fatalf("expected only the first descriptor to be exploded, got %v", q.Keywords)
+	}
+}
+
+func TestCochraneLibrary_BooleanQuery_FieldCount(t *testing.T) {
+	q := NewCochraneLibParser().Parse(lexerNode(cochraneQueryString))
+
+	expected := 2
+	got := q.FieldCount()["mesh_headings"]
+	if expected != got {
+		t.Fatalf("Expected %v fields, got %v", expected, got)
+	}
+
+	expected = 2
+	got = q.FieldCount()["keywords"]
+	if expected != got {
+		t.Fatalf("Expected %v fields, got %v", expected, got)
+	}
+}
+
+func TestCochraneLibrary_Next(t *testing.T) {
+	q := NewCochraneLibParser().Parse(lexerNode(`memory NEXT loss`))
+	if q.Operator != "adj1" || q.Options[ir.OrderedOption] != true {
+		t.Fatalf("expected ordered adj1, got %v", q)
+	}
+}
+
+func TestCochraneLibrary_Qualifiers(t *testing.T) {
+	q := NewCochraneLibParser().Parse(lexerNode(`#1 MeSH descriptor: [Dementia] explode all trees and with qualifier(s): [drug therapy - DT, therapy - TH]
+#2 memory:ti
+#3 #1 OR #2`))
+	if len(q.Keywords) != 2 {
+		t.Fatalf("expected two keywords, got %v", q)
+	}
+	k := q.Keywords[0]
+	if k.QueryString != "Dementia" || !k.Exploded {
+		t.Fatalf("expected an exploded descriptor, got %v", k)
+	}
+	subheadings, ok := k.Options[ir.SubheadingsOption].([]string)
+	if !ok || len(subheadings) != 2 || subheadings[0] != "dt" || subheadings[1] != "th" {
+		t.Fatalf("expected the qualifiers dt and th, got %v", k.Options)
+	}
+}
+
+// lexerNode creates the node the pipeline passes to a parser when a query does not require lexing.
+func lexerNode(query string) lexer.Node {
+	return lexer.Node{
+		Value:     query,
+		Children:  nil,
+		Operator:  "",
+		Reference: 1,
+	}
 }
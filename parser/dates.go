@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hscells/transmute/ir"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var dateRegexp, _ = regexp.Compile(`^([0-9]{4})(?:[/-]([0-9]{1,2})(?:[/-]([0-9]{1,2}))?)?$`)
+
+// parseDate parses a date written as `YYYY`, `YYYY/MM` or `YYYY/MM/DD` (or with `-` separators). Dates which are not
+// complete are the first day of the period they describe, or the last day when end is true. Databases use `3000` or
+// `current` as the end of an open range, which is parsed as the zero time.
+func parseDate(date string, end bool) (time.Time, error) {
+	date = strings.Trim(strings.TrimSpace(date), `"`)
+	if strings.ToLower(date) == "current" || date == "3000" {
+		return time.Time{}, nil
+	}
+
+	parts := dateRegexp.FindStringSubmatch(date)
+	if parts == nil {
+		return time.Time{}, errors.New(fmt.Sprintf("`%v` is not a valid date", date))
+	}
+
+	year, _ := strconv.Atoi(parts[1])
+	month, day := 1, 1
+	if len(parts[2]) > 0 {
+		month, _ = strconv.Atoi(parts[2])
+	}
+	if len(parts[3]) > 0 {
+		day, _ = strconv.Atoi(parts[3])
+	}
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, errors.New(fmt.Sprintf("`%v` is not a valid date", date))
+	}
+
+	if end {
+		switch {
+		case len(parts[2]) == 0:
+			return time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC), nil
+		case len(parts[3]) == 0:
+			// The zeroth day of the next month is the last day of this month.
+			return time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC), nil
+}
+
+// parseDateRange parses a range of dates where the start and end are separated by sep (e.g. `2000/01/01:2010/12/31`
+// or `2000-2010`). A single date is a range covering the entire period of that date.
+func parseDateRange(dates string, sep string) (*ir.DateRange, error) {
+	bounds := strings.SplitN(dates, sep, 2)
+	if len(bounds) == 1 {
+		bounds = append(bounds, bounds[0])
+	}
+
+	var r ir.DateRange
+	var err error
+	if len(strings.TrimSpace(bounds[0])) > 0 {
+		r.Start, err = parseDate(bounds[0], false)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(strings.TrimSpace(bounds[1])) > 0 {
+		r.End, err = parseDate(bounds[1], true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &r, nil
+}
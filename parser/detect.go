@@ -0,0 +1,205 @@
+package parser
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Detection is the dialect a query is most likely written in, the parser for that dialect, and how confident the
+// detection is, from 0 (no evidence) to 1.
+type Detection struct {
+	// Name of the dialect, which is the same as the name of the parser in the command line tool (e.g. `medline`).
+	Name       string
+	Parser     QueryParser
+	Confidence float64
+}
+
+// signal is a pattern which is evidence that a query is written in a dialect.
+type signal struct {
+	pattern *regexp.Regexp
+	weight  float64
+}
+
+// dialect is a query language which can be detected by the signals which appear in its queries.
+type dialect struct {
+	name    string
+	parser  func() QueryParser
+	signals []signal
+}
+
+// detectFallback is the dialect of queries which contain none of the signals of any dialect, e.g. `a AND b`.
+const detectFallback = "pubmed"
+
+// dialects are the dialects Detect scores queries against. When two dialects score the same, the first is preferred.
+var dialects = []dialect{
+	{
+		name:   "medline",
+		parser: NewMedlineParser,
+		signals: []signal{
+			{regexp.MustCompile(`(?m)^\s*[0-9]+\.?\s+\S`), 0.4},
+			{regexp.MustCompile(`\.(ti|ab|ti,ab|mp|tw|sh|pt|kf|kw|hw|fs|nm|rn|mh)\.`), 0.4},
+			{regexp.MustCompile(`(?m)^\s*(?:[0-9]+\.?\s+)?(?:exp\s+)?\*?[^/\n\[]+/\s*(?:[a-z]{2}(?:,\s*[a-z]{2})*)?\s*$`), 0.3},
+			{regexp.MustCompile(`(?im)\b(or|and)/[0-9]+(-[0-9]+|,[0-9]+)`), 0.3},
+			{regexp.MustCompile(`(?i)\badj[0-9]*\b`), 0.2},
+			{regexp.MustCompile(`(?im)^\s*(?:[0-9]+\.?\s+)?limit\s+[0-9]+\s+to\s`), 0.2},
+		},
+	},
+	{
+		name:   "embase",
+		parser: NewEmbaseParser,
+		signals: []signal{
+			{regexp.MustCompile(`(?m)^\s*[0-9]+\.?\s+\S`), 0.4},
+			{regexp.MustCompile(`\.(ti|ab|ti,ab|mp|tw|sh|pt|kw|hw|tw,kw|ti,ab,kw)\.`), 0.4},
+			{regexp.MustCompile(`(?m)^\s*(?:[0-9]+\.?\s+)?(?:exp\s+)?\*?[^/\n\[]+/\s*(?:[a-z]{2}(?:,\s*[a-z]{2})*)?\s*$`), 0.3},
+			{regexp.MustCompile(`(?im)\b(or|and)/[0-9]+(-[0-9]+|,[0-9]+)`), 0.3},
+			{regexp.MustCompile(`(?i)\badj[0-9]*\b`), 0.2},
+			// Field codes which only exist in Embase.
+			{regexp.MustCompile(`\.(dq|dv|dm|tn|mn|tw,kw|ti,ab,kw)\.`), 0.5},
+		},
+	},
+	{
+		name:   "pubmed",
+		parser: NewPubMedParser,
+		signals: []signal{
+			{regexp.MustCompile(`(?i)\[(tiab|ti|ab|mh|mesh|majr|sh|pt|la|dp|tw|all fields|title|title/abstract|mesh terms|mesh major topic|text word|publication type)(:noexp)?(:~[0-9]+)?\]`), 0.8},
+			{regexp.MustCompile(`\b(AND|OR|NOT)\b`), 0.1},
+		},
+	},
+	{
+		name:   "cochrane",
+		parser: NewCochraneLibParser,
+		signals: []signal{
+			{regexp.MustCompile(`(?i)MeSH descriptor:\s*\[`), 0.8},
+			{regexp.MustCompile(`:(ti|ab|kw|tw)(,(ti|ab|kw|tw))*\b`), 0.5},
+			{regexp.MustCompile(`(?m)^\s*#[0-9]+\s`), 0.2},
+			{regexp.MustCompile(`(?i)\bNEAR/[0-9]+`), 0.2},
+			{regexp.MustCompile(`\bNEXT\b`), 0.3},
+		},
+	},
+	{
+		name:   "cinahl",
+		parser: NewCINAHLParser,
+		signals: []signal{
+			{regexp.MustCompile(`(?m)^\s*S[0-9]+\s+\S`), 0.5},
+			{regexp.MustCompile(`\((MH|MM)\s+"`), 0.6},
+			{regexp.MustCompile(`\bS[0-9]+\s+(OR|AND|NOT)\s+S[0-9]+`), 0.4},
+			{regexp.MustCompile(`\b(TI|AB|SU|MW|TX)\s+[("\w]`), 0.2},
+			{regexp.MustCompile(`\b[NW][0-9]+\b`), 0.2},
+		},
+	},
+	{
+		name:   "wos",
+		parser: NewWebOfScienceParser,
+		signals: []signal{
+			{regexp.MustCompile(`\b(TS|TI|AB|AU|SO|PY|DO|AK|KP)\s*=\s*[("\w]`), 0.8},
+			{regexp.MustCompile(`(?m)^\s*#[0-9]+\s`), 0.2},
+			{regexp.MustCompile(`(?i)\bNEAR/[0-9]+`), 0.2},
+			{regexp.MustCompile(`\bSAME\b`), 0.3},
+		},
+	},
+	{
+		name:   "scopus",
+		parser: NewScopusParser,
+		signals: []signal{
+			{regexp.MustCompile(`\b(TITLE-ABS-KEY|TITLE-ABS|TITLE|ABS|KEY|AUTHKEY|INDEXTERMS|AUTH|SRCTITLE|DOCTYPE|LANGUAGE)\s*\(`), 0.8},
+			{regexp.MustCompile(`\b(PUBYEAR)\s*[<>=]`), 0.5},
+			{regexp.MustCompile(`(?i)\b(W|PRE)/[0-9]+`), 0.4},
+			{regexp.MustCompile(`\bAND NOT\b`), 0.1},
+		},
+	},
+	{
+		name:   "proquest",
+		parser: NewProQuestParser,
+		signals: []signal{
+			{regexp.MustCompile(`(?i)\b[a-z]+\.EXACT(\.EXPLODE)?\s*\(`), 0.8},
+			{regexp.MustCompile(`(?i)\b(ti|ab|noft|su|if)(,(ti|ab|su|if))*\s*\(`), 0.5},
+			{regexp.MustCompile(`(?i)\b(NEAR|N|PRE|P)/[0-9]+`), 0.3},
+		},
+	},
+	{
+		name:   "embasecom",
+		parser: NewEmbaseComParser,
+		signals: []signal{
+			{regexp.MustCompile(`(?i)['"][^'"]+['"](/(exp|de|mj))+`), 0.8},
+			{regexp.MustCompile(`(?i)\[[^\]]+\]/(lim|py)`), 0.5},
+			{regexp.MustCompile(`:(ti|ab|kw|de)(,(ti|ab|kw|de))*\b`), 0.3},
+			{regexp.MustCompile(`(?i)\bNEXT/[0-9]+`), 0.3},
+			{regexp.MustCompile(`(?m)^\s*#[0-9]+\s`), 0.1},
+		},
+	},
+	{
+		name:   "lucene",
+		parser: NewLuceneParser,
+		signals: []signal{
+			{regexp.MustCompile(`(^|[\s(+\-])[a-zA-Z_]+:[^\s/]`), 0.3},
+			{regexp.MustCompile(`(^|[\s(])[+\-][("\w]`), 0.3},
+			{regexp.MustCompile(`"~[0-9]+|\w\^[0-9.]+`), 0.4},
+			{regexp.MustCompile(`&&|\|\|`), 0.3},
+		},
+	},
+}
+
+// Detect scores a query against each of the known dialects, and returns the parser for the dialect it is most likely
+// written in. The confidence is the share of the evidence for the best dialect, scaled down when there is little
+// evidence at all. JSON queries are recognised by their structure. Queries without any evidence are detected as PubMed
+// queries with a confidence of zero, since plain Boolean queries (e.g. `a AND b`) are valid PubMed queries.
+func Detect(query string) Detection {
+	query = strings.TrimSpace(query)
+
+	if strings.HasPrefix(query, "{") {
+		if d, ok := detectJSON(query); ok {
+			return d
+		}
+	}
+
+	var best dialect
+	var bestScore, total float64
+	for _, d := range dialects {
+		var score float64
+		for _, s := range d.signals {
+			if s.pattern.MatchString(query) {
+				score += s.weight
+			}
+		}
+		if score > bestScore {
+			best = d
+			bestScore = score
+		}
+		total += score
+	}
+
+	if bestScore == 0 {
+		return Detection{Name: detectFallback, Parser: NewPubMedParser(), Confidence: 0}
+	}
+
+	confidence := bestScore / total
+	if bestScore < 1 {
+		confidence *= bestScore
+	}
+	return Detection{Name: best.name, Parser: best.parser(), Confidence: confidence}
+}
+
+// detectJSON detects which of the JSON dialects a query is written in from the keys of the top-level object.
+func detectJSON(query string) (Detection, bool) {
+	var v map[string]interface{}
+	if err := json.Unmarshal([]byte(query), &v); err != nil {
+		return Detection{}, false
+	}
+
+	_, hasKeywords := v["keywords"]
+	_, hasOperator := v["operator"]
+	_, hasBool := v["bool"]
+	_, isQueryObject := v["query"].(map[string]interface{})
+	_, isKeyword := v["query"].(string)
+
+	switch {
+	case isQueryObject || hasBool:
+		return Detection{Name: "elasticsearch", Parser: NewElasticsearchParser(), Confidence: 1}, true
+	case hasKeywords:
+		return Detection{Name: "ir", Parser: NewIrParser(), Confidence: 1}, true
+	case hasOperator || isKeyword:
+		return Detection{Name: "cqr", Parser: NewCQRParser(), Confidence: 1}, true
+	}
+	return Detection{}, false
+}
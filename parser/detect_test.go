@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	irString, err := json.Marshal(irQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for expected, query := range map[string]string{
+		"medline":       medlineQueryString,
+		"embase":        embaseQueryString,
+		"pubmed":        pubmedQueryString,
+		"cochrane":      cochraneQueryString,
+		"cinahl":        cinahlQueryString,
+		"wos":           webOfScienceQueryString,
+		"scopus":        scopusQueryString,
+		"lucene":        luceneQueryString,
+		"proquest":      proquestQueryString,
+		"embasecom":     embaseComQueryString,
+		"cqr":           cqrQuery,
+		"elasticsearch": elasticsearchQueryString,
+		"ir":            string(irString),
+	} {
+		d := Detect(query)
+		if d.Name != expected {
+			t.Fatalf("expected %v to be detected, got %v (%v)", expected, d.Name, d.Confidence)
+		}
+		if d.Confidence <= 0 || d.Confidence > 1 {
+			t.Fatalf("expected a confidence between 0 and 1 for %v, got %v", expected, d.Confidence)
+		}
+	}
+}
+
+func TestDetect_Fallback(t *testing.T) {
+	d := Detect("dementia AND memory")
+	if d.Name != "pubmed" {
+		t.Fatalf("expected a query without signals to be detected as pubmed, got %v", d.Name)
+	}
+}
@@ -0,0 +1,555 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// ElasticsearchTransformer is an implementation of a QueryTransformer for the Elasticsearch query DSL. It recognises
+// the queries created by the ElasticsearchCompiler, so that they can be translated into other query languages.
+//
+// Some information cannot be recovered from an Elasticsearch query. In particular, exploded MeSH headings are
+// compiled into a disjunction of the narrower headings, so these are parsed as separate keywords.
+type ElasticsearchTransformer struct{}
+
+// TransformSingle takes a JSON encoded Elasticsearch leaf query (e.g. `match`) and parses it into a keyword.
+func (e ElasticsearchTransformer) TransformSingle(query string, mapping map[string][]string) ir.Keyword {
+	var node map[string]interface{}
+	err := json.Unmarshal([]byte(query), &node)
+	if err != nil {
+		log.Println(err)
+		return ir.Keyword{}
+	}
+	operand, ok := e.transform(node)
+	if !ok || operand.keyword == nil {
+		log.Printf("unable to parse `%v` as a single keyword\n", query)
+		return ir.Keyword{}
+	}
+	return mapKeywordFields(*operand.keyword, mapping)
+}
+
+// TransformNested takes a JSON encoded Elasticsearch query and parses it into the ir. The query may be wrapped in
+// `query` and `constant_score` objects, as is done by the ElasticsearchCompiler.
+func (e ElasticsearchTransformer) TransformNested(query string, mapping map[string][]string) ir.BooleanQuery {
+	var node map[string]interface{}
+	err := json.Unmarshal([]byte(query), &node)
+	if err != nil {
+		log.Println(err)
+		return ir.BooleanQuery{}
+	}
+
+	if q, ok := node["query"].(map[string]interface{}); ok {
+		node = q
+	}
+
+	operand, ok := e.transform(node)
+	if !ok {
+		return ir.BooleanQuery{}
+	}
+	return mapKeywords(operand.booleanQuery(), func(keyword ir.Keyword) ir.Keyword {
+		return mapKeywordFields(keyword, mapping)
+	})
+}
+
+// transform converts a node of an Elasticsearch query into an operand.
+func (e ElasticsearchTransformer) transform(node interface{}) (expressionOperand, bool) {
+	n, ok := node.(map[string]interface{})
+	if !ok {
+		log.Printf("unsupported Elasticsearch query `%v`\n", node)
+		return expressionOperand{}, false
+	}
+
+	if cs, ok := n["constant_score"].(map[string]interface{}); ok {
+		return e.transform(cs["filter"])
+	}
+	if b, ok := n["bool"].(map[string]interface{}); ok {
+		return e.transformBool(b)
+	}
+	if s, ok := n["span_near"].(map[string]interface{}); ok {
+		return e.transformSpan(s, true)
+	}
+	for _, queryType := range []string{"match", "match_phrase", "term"} {
+		if field, value, ok := leafQuery(n[queryType]); ok {
+			return keywordOperand(value, field, false), true
+		}
+	}
+	for _, queryType := range []string{"wildcard", "prefix"} {
+		if field, value, ok := leafQuery(n[queryType]); ok {
+			if queryType == "prefix" {
+				value += "*"
+			}
+			return keywordOperand(value, field, true), true
+		}
+	}
+	if r, ok := n["range"].(map[string]interface{}); ok {
+		return e.transformRange(r)
+	}
+	if qs, ok := n["query_string"].(map[string]interface{}); ok {
+		if q, ok := qs["query"].(string); ok {
+			// The ElasticsearchCompiler writes query strings as `field:query`.
+			if i := strings.Index(q, ":"); i > 0 && !strings.ContainsAny(q[:i], ` "`) {
+				return keywordOperand(q[i+1:], q[:i], isTruncated(q[i+1:])), true
+			}
+			return keywordOperand(q, "", isTruncated(q)), true
+		}
+	}
+	if mm, ok := n["multi_match"].(map[string]interface{}); ok {
+		if q, ok := mm["query"].(string); ok {
+			k := ir.Keyword{QueryString: q, Truncated: isTruncated(q)}
+			if f, ok := mm["fields"].([]interface{}); ok {
+				for _, field := range f {
+					k.Fields = append(k.Fields, fmt.Sprintf("%v", field))
+				}
+			}
+			return expressionOperand{keyword: &k}, true
+		}
+	}
+
+	log.Printf("unsupported Elasticsearch query `%v`\n", node)
+	return expressionOperand{}, false
+}
+
+// transformRange converts a range query over dates into a keyword with a date range.
+func (e ElasticsearchTransformer) transformRange(r map[string]interface{}) (expressionOperand, bool) {
+	for field, v := range r {
+		bounds, ok := v.(map[string]interface{})
+		if !ok {
+			break
+		}
+		dateRange := &ir.DateRange{}
+		for bound, value := range bounds {
+			date, ok := value.(string)
+			if !ok || bound == "format" {
+				continue
+			}
+			var err error
+			switch bound {
+			case "gte":
+				dateRange.Start, err = parseDate(date, false)
+			case "gt":
+				dateRange.Start, err = parseDate(date, true)
+				dateRange.Start = dateRange.Start.AddDate(0, 0, 1)
+			case "lte":
+				dateRange.End, err = parseDate(date, true)
+			case "lt":
+				dateRange.End, err = parseDate(date, false)
+				dateRange.End = dateRange.End.AddDate(0, 0, -1)
+			}
+			if err != nil {
+				log.Println(err)
+				return expressionOperand{}, false
+			}
+		}
+		k := ir.Keyword{QueryString: dateRange.String(), Fields: []string{field}, Range: dateRange}
+		return expressionOperand{keyword: &k}, true
+	}
+	log.Printf("unsupported Elasticsearch range query `%v`\n", r)
+	return expressionOperand{}, false
+}
+
+// transformBool converts a bool query. Clauses in `filter` and `must` are conjunctions, `should` are disjunctions,
+// and `must_not` clauses are subtracted from the rest of the query. The ElasticsearchCompiler writes a `not` query
+// as a filter over the positive clauses and a bool query containing only `must_not` clauses.
+func (e ElasticsearchTransformer) transformBool(b map[string]interface{}) (expressionOperand, bool) {
+	var positive, should, negative []expressionOperand
+	add := func(operands []expressionOperand, node interface{}) []expressionOperand {
+		if operand, ok := e.transform(node); ok {
+			return append(operands, operand)
+		}
+		return operands
+	}
+
+	var subheadings []string
+	var subheadingClause interface{}
+	for _, occur := range []string{"filter", "must"} {
+		for _, clause := range boolClauses(b[occur]) {
+			if s, ok := subheadingClauses(clause); ok && subheadingClause == nil {
+				subheadings, subheadingClause = s, clause
+			} else if mustNot, ok := mustNotClauses(clause); ok {
+				for _, c := range mustNot {
+					negative = add(negative, c)
+				}
+			} else {
+				positive = add(positive, clause)
+			}
+		}
+	}
+	for _, clause := range boolClauses(b["should"]) {
+		should = add(should, clause)
+	}
+	for _, clause := range boolClauses(b["must_not"]) {
+		negative = add(negative, clause)
+	}
+
+	// A heading restricted to subheadings is a filter over the heading and its subheadings.
+	if subheadingClause != nil {
+		if len(positive) == 1 && len(should) == 0 && len(negative) == 0 && positive[0].keyword != nil {
+			k := *positive[0].keyword
+			options := map[string]interface{}{ir.SubheadingsOption: subheadings}
+			for key, v := range k.Options {
+				options[key] = v
+			}
+			k.Options = options
+			return expressionOperand{keyword: &k}, true
+		}
+		positive = add(positive, subheadingClause)
+	}
+
+	var operands []expressionOperand
+	if len(should) > 0 {
+		operands = append(operands, mergeAlternatives(combineAll(cqr.OR, should)))
+	}
+	operands = append(operands, positive...)
+	if len(operands) == 0 {
+		log.Println("a bool query must contain at least one positive clause")
+		return expressionOperand{}, false
+	}
+
+	operand := combineAll(cqr.AND, operands)
+	if len(negative) > 0 {
+		operand = combineAll(cqr.NOT, append([]expressionOperand{operand}, negative...))
+	}
+	return operand, true
+}
+
+// transformSpan converts a span_near query into an adjacency query. The ElasticsearchCompiler also uses span_near
+// queries for phrases inside an adjacency query; these are ordered, have a slop of one and contain only terms.
+func (e ElasticsearchTransformer) transformSpan(s map[string]interface{}, top bool) (expressionOperand, bool) {
+	clauses := boolClauses(s["clauses"])
+	slop := 0
+	if v, ok := s["slop"].(float64); ok {
+		slop = int(v)
+	}
+	inOrder, _ := s["in_order"].(bool)
+
+	// The ElasticsearchCompiler searches for a keyword which must occur a minimum number of times by repeating it in an
+	// ordered span_near query with an unlimited slop.
+	if inOrder && slop == math.MaxInt32 {
+		return e.transformFrequency(clauses)
+	}
+
+	if !top && inOrder && slop == 1 {
+		var terms []string
+		var field string
+		truncated := false
+		for _, clause := range clauses {
+			f, term, wildcard, ok := spanTerm(clause)
+			if !ok {
+				terms = nil
+				break
+			}
+			terms = append(terms, term)
+			field = f
+			truncated = truncated || wildcard
+		}
+		if len(terms) > 0 {
+			return keywordOperand(strings.Join(terms, " "), field, truncated), true
+		}
+	}
+
+	q := ir.BooleanQuery{Operator: "adj"}
+	if slop > 0 {
+		q.Operator = fmt.Sprintf("adj%d", slop)
+	}
+	if inOrder {
+		q.Options = map[string]interface{}{ir.OrderedOption: true}
+	}
+
+	for _, clause := range clauses {
+		if field, term, wildcard, ok := spanTerm(clause); ok {
+			q.Keywords = append(q.Keywords, *keywordOperand(term, field, wildcard).keyword)
+			continue
+		}
+		c, ok := clause.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if inner, ok := c["span_near"].(map[string]interface{}); ok {
+			operand, ok := e.transformSpan(inner, false)
+			if ok {
+				q = appendOperand(q, operand)
+			}
+			continue
+		}
+		log.Printf("unsupported span clause `%v`\n", clause)
+	}
+	return expressionOperand{query: q}, true
+}
+
+// transformFrequency converts the repeated clauses of a span_near query with an unlimited slop into a keyword with a
+// frequency, e.g. `dementia.ti./freq=3` in Ovid. Clauses which are not all the same keyword cannot be parsed.
+func (e ElasticsearchTransformer) transformFrequency(clauses []interface{}) (expressionOperand, bool) {
+	var keyword *ir.Keyword
+	for _, clause := range clauses {
+		var operand expressionOperand
+		if field, term, wildcard, ok := spanTerm(clause); ok {
+			operand = keywordOperand(term, field, wildcard)
+		} else if c, ok := clause.(map[string]interface{}); ok && c["span_near"] != nil {
+			inner, _ := c["span_near"].(map[string]interface{})
+			if operand, ok = e.transformSpan(inner, false); !ok {
+				return expressionOperand{}, false
+			}
+		}
+		if operand.keyword == nil || (keyword != nil && !reflect.DeepEqual(*keyword, *operand.keyword)) {
+			log.Printf("unsupported span_near query with an unlimited slop `%v`, expected a repeated keyword\n", clauses)
+			return expressionOperand{}, false
+		}
+		keyword = operand.keyword
+	}
+	if keyword == nil {
+		log.Println("a span_near query must contain at least one clause")
+		return expressionOperand{}, false
+	}
+	k := *keyword
+	k.Options = map[string]interface{}{ir.FrequencyOption: len(clauses)}
+	return expressionOperand{keyword: &k}, true
+}
+
+// subheadingClauses extracts the subheadings of the clause the ElasticsearchCompiler uses to restrict a heading to its
+// subheadings, which is a disjunction of match_phrase queries over the subheading field.
+func subheadingClauses(clause interface{}) ([]string, bool) {
+	c, ok := clause.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	b, ok := c["bool"].(map[string]interface{})
+	if !ok || b["should"] == nil {
+		return nil, false
+	}
+	var subheadings []string
+	for _, should := range boolClauses(b["should"]) {
+		s, ok := should.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		field, name, ok := leafQuery(s["match_phrase"])
+		if !ok || field != fields.MeSHSubheading {
+			return nil, false
+		}
+		subheading := name
+		for abbreviation, n := range ir.MeSHSubheadings {
+			if n == name {
+				subheading = abbreviation
+				break
+			}
+		}
+		subheadings = append(subheadings, subheading)
+	}
+	return subheadings, len(subheadings) > 0
+}
+
+// spanTerm extracts the term of a span_term or span_multi clause. The ElasticsearchCompiler uses span_multi prefix
+// queries for regular terms, and span_multi wildcard queries for truncated terms.
+func spanTerm(clause interface{}) (field, term string, wildcard bool, ok bool) {
+	c, ok := clause.(map[string]interface{})
+	if !ok {
+		return "", "", false, false
+	}
+	if field, term, ok := leafQuery(c["span_term"]); ok {
+		return field, term, false, true
+	}
+	if multi, ok := c["span_multi"].(map[string]interface{}); ok {
+		if match, ok := multi["match"].(map[string]interface{}); ok {
+			if field, term, ok := leafQuery(match["wildcard"]); ok {
+				return field, term, true, true
+			}
+			if field, term, ok := leafQuery(match["prefix"]); ok {
+				return field, term, false, true
+			}
+		}
+	}
+	return "", "", false, false
+}
+
+// leafQuery extracts the field and value of a query such as `{"title": "dementia"}` or
+// `{"title": {"query": "dementia"}}`.
+func leafQuery(node interface{}) (field, value string, ok bool) {
+	n, ok := node.(map[string]interface{})
+	if !ok || len(n) != 1 {
+		return "", "", false
+	}
+	for f, v := range n {
+		switch v := v.(type) {
+		case string:
+			return f, v, true
+		case map[string]interface{}:
+			for _, key := range []string{"query", "value"} {
+				if s, ok := v[key].(string); ok {
+					return f, s, true
+				}
+			}
+		}
+	}
+	return "", "", false
+}
+
+// boolClauses returns the clauses of a bool query occurrence type as a flat list.
+func boolClauses(node interface{}) []interface{} {
+	switch n := node.(type) {
+	case []interface{}:
+		var clauses []interface{}
+		for _, c := range n {
+			clauses = append(clauses, boolClauses(c)...)
+		}
+		return clauses
+	case map[string]interface{}:
+		return []interface{}{n}
+	}
+	return nil
+}
+
+// mustNotClauses returns the clauses of a bool query which contains only `must_not` clauses.
+func mustNotClauses(node interface{}) ([]interface{}, bool) {
+	n, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	b, ok := n["bool"].(map[string]interface{})
+	if !ok || b["must_not"] == nil {
+		return nil, false
+	}
+	for occur := range b {
+		if occur != "must_not" && occur != "disable_coord" {
+			return nil, false
+		}
+	}
+	return boolClauses(b["must_not"]), true
+}
+
+func keywordOperand(queryString, field string, truncated bool) expressionOperand {
+	k := ir.Keyword{QueryString: queryString, Truncated: truncated}
+	if len(field) > 0 {
+		k.Fields = []string{field}
+	}
+	return expressionOperand{keyword: &k}
+}
+
+// combineAll combines a list of operands with the same operator.
+func combineAll(operator string, operands []expressionOperand) expressionOperand {
+	operand := operands[0]
+	for _, o := range operands[1:] {
+		operand = combineOperands(expressionOperator{operator: operator}, operand, o)
+	}
+	return operand
+}
+
+// mergeAlternatives merges the keywords and queries of a disjunction which differ only by their fields. Elasticsearch
+// queries search each field separately, whereas the ir allows a keyword to search several fields.
+func mergeAlternatives(operand expressionOperand) expressionOperand {
+	if operand.keyword != nil || operand.query.Operator != cqr.OR {
+		return operand
+	}
+	q := operand.query
+
+	var keywords []ir.Keyword
+	for _, k := range q.Keywords {
+		merged := false
+		for i := range keywords {
+			if sameKeyword(keywords[i], k) {
+				keywords[i].Fields = unionFields(keywords[i].Fields, k.Fields)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			k.Fields = unionFields(nil, k.Fields)
+			keywords = append(keywords, k)
+		}
+	}
+
+	var children []ir.BooleanQuery
+	for _, c := range q.Children {
+		merged := false
+		for i := range children {
+			if sameQuery(children[i], c) {
+				children[i] = mergeQueryFields(children[i], c)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			children = append(children, c)
+		}
+	}
+
+	if len(keywords) == 1 && len(children) == 0 {
+		return expressionOperand{keyword: &keywords[0]}
+	}
+	if len(keywords) == 0 && len(children) == 1 {
+		return expressionOperand{query: children[0]}
+	}
+	q.Keywords = keywords
+	q.Children = children
+	return expressionOperand{query: q}
+}
+
+// sameKeyword tests if two keywords are equal, ignoring their fields.
+func sameKeyword(a, b ir.Keyword) bool {
+	return a.QueryString == b.QueryString &&
+		a.Exploded == b.Exploded &&
+		a.Truncated == b.Truncated &&
+		reflect.DeepEqual(a.Options, b.Options)
+}
+
+// sameQuery tests if two queries are equal, ignoring the fields of their keywords.
+func sameQuery(a, b ir.BooleanQuery) bool {
+	if a.Operator != b.Operator || !reflect.DeepEqual(a.Options, b.Options) ||
+		len(a.Keywords) != len(b.Keywords) || len(a.Children) != len(b.Children) {
+		return false
+	}
+	for i := range a.Keywords {
+		if !sameKeyword(a.Keywords[i], b.Keywords[i]) {
+			return false
+		}
+	}
+	for i := range a.Children {
+		if !sameQuery(a.Children[i], b.Children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeQueryFields merges the fields of the keywords of two queries for which sameQuery is true.
+func mergeQueryFields(a, b ir.BooleanQuery) ir.BooleanQuery {
+	q := ir.BooleanQuery{Operator: a.Operator, Options: a.Options}
+	for i := range a.Keywords {
+		k := a.Keywords[i]
+		k.Fields = unionFields(k.Fields, b.Keywords[i].Fields)
+		q.Keywords = append(q.Keywords, k)
+	}
+	for i := range a.Children {
+		q.Children = append(q.Children, mergeQueryFields(a.Children[i], b.Children[i]))
+	}
+	return q
+}
+
+func unionFields(a, b []string) []string {
+	f := make([]string, len(a))
+	copy(f, a)
+	for _, field := range b {
+		found := false
+		for _, existing := range f {
+			if existing == field {
+				found = true
+				break
+			}
+		}
+		if !found {
+			f = append(f, field)
+		}
+	}
+	return f
+}
+
+// NewElasticsearchParser creates a new parser for Elasticsearch queries.
+func NewElasticsearchParser() QueryParser {
+	return QueryParser{Parser: ElasticsearchTransformer{}, FieldMapping: map[string][]string{"default": {fields.AllFields}}}
+}
@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"github.com/hscells/transmute/ir"
+	"testing"
+)
+
+var (
+	elasticsearchQueryString = `{
+  "query": {
+    "constant_score": {
+      "filter": {
+        "bool": {
+          "disable_coord": true,
+          "filter": [
+            {
+              "bool": {
+                "disable_coord": true,
+                "filter": [
+                  {
+                    "bool": {
+                      "disable_coord": true,
+                      "should": [
+                        {"match": {"title": "dementia"}},
+                        {"bool": {"should": [{"match_phrase": {"title": "memory loss"}}, {"match_phrase": {"text": "memory loss"}}]}},
+                        {"query_string": {"query": "title:alzheimer*", "analyze_wildcard": true, "split_on_whitespace": false}},
+                        {
+                          "bool": {
+                            "should": [
+                              {"span_near": {"clauses": [{"span_multi": {"match": {"prefix": {"title": "mild"}}}}, {"span_multi": {"match": {"wildcard": {"title": "impair*"}}}}], "slop": 3, "in_order": false}},
+                              {"span_near": {"clauses": [{"span_multi": {"match": {"prefix": {"text": "mild"}}}}, {"span_multi": {"match": {"wildcard": {"text": "impair*"}}}}], "slop": 3, "in_order": false}}
+                            ]
+                          }
+                        }
+                      ]
+                    }
+                  }
+                ]
+              }
+            },
+            {
+              "bool": {
+                "disable_coord": true,
+                "must_not": [
+                  {"match": {"publication_type": "review"}}
+                ]
+              }
+            }
+          ]
+        }
+      }
+    }
+  }
+}`
+)
+
+func TestElasticsearch_BooleanQuery_Terms(t *testing.T) {
+	queryRep := NewElasticsearchParser().Parse(lexerNode(elasticsearchQueryString))
+
+	expected := 6
+	got := len(queryRep.Terms())
+	if expected != got {
+		t.Fatalf("Expected %v terms, got %v", expected, got)
+	}
+
+	if queryRep.Operator != "not" {
+		t.Fatalf("expected not, got %v", queryRep.Operator)
+	}
+}
+
+func TestElasticsearch_BooleanQuery_Fields(t *testing.T) {
+	queryRep := NewElasticsearchParser().Parse(lexerNode(elasticsearchQueryString))
+
+	counts := queryRep.FieldCount()
+	for field, expected := range map[string]int{
+		"title":            5,
+		"text":             3,
+		"publication_type": 1,
+	} {
+		if got := counts[field]; got != expected {
+			t.Fatalf("Expected %v %v fields, got %v", expected, field, got)
+		}
+	}
+}
+
+func TestElasticsearch_Adjacency(t *testing.T) {
+	queryRep := NewElasticsearchParser().Parse(lexerNode(elasticsearchQueryString))
+
+	var adj *ir.BooleanQuery
+	var visit func(q ir.BooleanQuery)
+	visit = func(q ir.BooleanQuery) {
+		if q.Operator == "adj3" {
+			adj = &q
+		}
+		for _, child := range q.Children {
+			visit(child)
+		}
+	}
+	visit(queryRep)
+
+	if adj == nil {
+		t.Fatalf("expected an adj3 query, got %v", queryRep)
+	}
+	if len(adj.Keywords) != 2 || adj.Keywords[0].QueryString != "mild" || !adj.Keywords[1].Truncated {
+		t.Fatalf("expected mild adj3 impair*, got %v", adj)
+	}
+}
+
+func TestElasticsearch_Frequency(t *testing.T) {
+	queryRep := NewElasticsearchParser().Parse(lexerNode(`{"span_near": {"clauses": [{"span_term": {"title": "dementia"}}, {"span_term": {"title": "dementia"}}, {"span_term": {"title": "dementia"}}], "in_order": true, "slop": 2147483647}}`))
+
+	if len(queryRep.Keywords) != 1 || queryRep.Keywords[0].QueryString != "dementia" || queryRep.Keywords[0].Truncated {
+		t.Fatalf("expected the keyword dementia, got %v", queryRep)
+	}
+	if queryRep.Keywords[0].Options[ir.FrequencyOption] != 3 {
+		t.Fatalf("expected a frequency of 3, got %v", queryRep.Keywords[0].Options)
+	}
+
+	// Clauses which are not the same keyword are not a frequency.
+	queryRep = NewElasticsearchParser().Parse(lexerNode(`{"span_near": {"clauses": [{"span_term": {"title": "dementia"}}, {"span_term": {"title": "memory"}}], "in_order": true, "slop": 2147483647}}`))
+	if len(queryRep.Keywords) != 0 || len(queryRep.Children) != 0 {
+		t.Fatalf("expected the query to be rejected, got %v", queryRep)
+	}
+}
+
+func TestElasticsearch_Subheadings(t *testing.T) {
+	queryRep := NewElasticsearchParser().Parse(lexerNode(`{"bool": {"filter": [{"match": {"mesh_headings": "Dementia"}}, {"bool": {"should": [{"match_phrase": {"mesh_subheading": "drug therapy"}}, {"match_phrase": {"mesh_subheading": "therapy"}}]}}]}}`))
+
+	if len(queryRep.Keywords) != 1 || queryRep.Keywords[0].QueryString != "Dementia" {
+		t.Fatalf("expected the heading Dementia, got %v", queryRep)
+	}
+	subheadings, ok := queryRep.Keywords[0].Options[ir.SubheadingsOption].([]string)
+	if !ok || len(subheadings) != 2 || subheadings[0] != "dt" || subheadings[1] != "th" {
+		t.Fatalf("expected the subheadings dt and th, got %v", queryRep.Keywords[0].Options)
+	}
+}
@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"github.com/hscells/transmute/fields"
+)
+
+// EmbaseFieldMapping maps Ovid Embase field codes. Subject headings in Embase come from the Emtree thesaurus rather
+// than MeSH, so they are mapped to the Emtree fields so that backends can distinguish the two vocabularies.
+var EmbaseFieldMapping = map[string][]string{
+	"ab":       {fields.Abstract},
+	"au":       {fields.Authors},
+	"ca":       {fields.AuthorCorporate},
+	"dm":       {fields.DeviceManufacturer},
+	"dq":       {fields.CandidateTerm},
+	"dv":       {fields.DeviceTradeName},
+	"hw":       {fields.EmtreeHeadings},
+	"in":       {fields.Affiliation},
+	"is":       {fields.ISBN},
+	"jn":       {fields.Journal},
+	"jx":       {fields.Journal},
+	"kw":       {fields.Keywords},
+	"la":       {fields.Language},
+	"mj":       {fields.MajorFocusEmtreeHeading},
+	"mn":       {fields.DrugManufacturer},
+	"mp":       fields.EmbaseMultiPurpose,
+	"ot":       {fields.TransliteratedTitle},
+	"pt":       {fields.PublicationType},
+	"sh":       {fields.EmtreeHeadings},
+	"so":       {fields.Journal},
+	"ti":       {fields.Title},
+	"tn":       {fields.DrugTradeName},
+	"tw":       {fields.TitleAbstract},
+	"ui":       {fields.PMID},
+	"vo":       {fields.Volume},
+	"yr":       {fields.PublicationDate},
+	"ti,ab":    {fields.TitleAbstract},
+	"ti,ab,kw": {fields.TitleAbstract, fields.Keywords},
+	"tw,kw":    {fields.TitleAbstract, fields.Keywords},
+	// Subject headings (e.g. `exp Dementia/`) are looked up under `mh` by the MedlineTransformer.
+	"mh":      {fields.EmtreeHeadings},
+	"default": {fields.AllFields},
+}
+
+// NewEmbaseParser creates a new parser for Ovid Embase search strategies. Ovid Embase shares its syntax with Ovid
+// MEDLINE, so it is parsed by the MedlineTransformer using the Embase field codes.
+func NewEmbaseParser() QueryParser {
+	return QueryParser{FieldMapping: EmbaseFieldMapping, Parser: MedlineTransformer{}}
+}
@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"github.com/hscells/transmute/lexer"
+	"testing"
+)
+
+var (
+	embaseQueryString = `1. exp dementia/
+2. (dementia or alzheimer*).tw.
+3. donepezil.dv.
+4. mild cognitive impairment.dq.
+5. or/1-4`
+)
+
+func TestEmbase_BooleanQuery_Terms(t *testing.T) {
+	ast, err := lexer.Lex(embaseQueryString, lexOptionsMedline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queryRep := NewEmbaseParser().Parse(ast)
+
+	expected := 5
+	got := len(queryRep.Terms())
+	if expected != got {
+		t.Fatalf("Expected %v terms, got %v", expected, got)
+	}
+}
+
+func TestEmbase_BooleanQuery_FieldCount(t *testing.T) {
+	ast, err := lexer.Lex(embaseQueryString, lexOptionsMedline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queryRep := NewEmbaseParser().Parse(ast)
+
+	counts := queryRep.FieldCount()
+	for field, expected := range map[string]int{
+		"emtree_headings":   1,
+		"mesh_headings":     0,
+		"device_trade_name": 1,
+		"candidate_term":    1,
+	} {
+		if got := counts[field]; got != expected {
+			t.Fatalf("Expected %v %v fields, got %v", expected, field, got)
+		}
+	}
+}
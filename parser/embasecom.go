@@ -0,0 +1,271 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"github.com/hscells/transmute/lexer"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EmbaseComFieldMapping maps the field codes of Embase.com (Elsevier). Subject headings are searched with `/exp`,
+// `/de` and `/mj` (e.g. `'dementia'/exp`) and so they are mapped under `de` and `mj`.
+var EmbaseComFieldMapping = map[string][]string{
+	"ti":       {fields.Title},
+	"ab":       {fields.Abstract},
+	"kw":       {fields.Keywords},
+	"de":       {fields.EmtreeHeadings},
+	"mj":       {fields.MajorFocusEmtreeHeading},
+	"au":       {fields.Authors},
+	"ff":       {fields.Affiliation},
+	"jt":       {fields.Journal},
+	"la":       {fields.Language},
+	"it":       {fields.PublicationType},
+	"py":       {fields.PublicationDate},
+	"tn":       {fields.DrugTradeName},
+	"mn":       {fields.DrugManufacturer},
+	"dn":       {fields.DeviceTradeName},
+	"df":       {fields.DeviceManufacturer},
+	"ti,ab":    {fields.TitleAbstract},
+	"ti,ab,kw": {fields.TitleAbstract, fields.Keywords},
+	"default":  {fields.AllFields},
+}
+
+var (
+	embaseComHeadingRegexp, _   = regexp.Compile(`(?i)^['"]([^'"]+)['"]((?:/(?:exp|de|mj))+)`)
+	embaseComLimitRegexp, _     = regexp.Compile(`(?i)^\[([^\]]+)\]/(lim|py)`)
+	embaseComFieldRegexp, _     = regexp.Compile(`^:[a-zA-Z]+(,[a-zA-Z]+)*$`)
+	embaseComProximityRegexp, _ = regexp.Compile(`(?i)^(NEAR|NEXT)(/([0-9]+))?$`)
+	embaseComYearsRegexp, _     = regexp.Compile(`^([0-9]{4})(?:-([0-9]{4}))?$`)
+)
+
+// embaseComLimitOption is set on the keyword of a limit (e.g. `[english]/lim`) to the limit, until the limit is moved
+// into the limits of the query it restricts.
+const embaseComLimitOption = "embasecom_limit"
+
+// EmbaseComTransformer is an implementation of a QueryTransformer for Embase.com search strategies, e.g.
+// `'dementia'/exp OR (memory NEAR/3 loss):ti,ab,kw`.
+type EmbaseComTransformer struct{}
+
+// keyword transforms a term into a keyword. Phrases in single quotes match loosely (e.g. `'heart attack'`), while
+// phrases in double quotes match exactly.
+func (e EmbaseComTransformer) keyword(query string, mapping map[string][]string) ir.Keyword {
+	query = strings.TrimSpace(query)
+
+	if heading := embaseComHeadingRegexp.FindStringSubmatch(query); heading != nil {
+		suffixes := strings.ToLower(heading[2])
+		f := mapping["de"]
+		if strings.Contains(suffixes, "/mj") {
+			f = mapping["mj"]
+		}
+		return ir.Keyword{
+			QueryString: heading[1],
+			Fields:      f,
+			Exploded:    strings.Contains(suffixes, "/exp"),
+		}
+	}
+
+	if limit := embaseComLimitRegexp.FindStringSubmatch(query); limit != nil {
+		return e.limit(limit[1], strings.ToLower(limit[2]), mapping)
+	}
+
+	var options map[string]interface{}
+	if len(query) > 1 && (query[0] == '\'' || query[0] == '"') && query[len(query)-1] == query[0] {
+		phrase := ir.LoosePhrase
+		if query[0] == '"' {
+			phrase = ir.ExactPhrase
+		}
+		query = query[1 : len(query)-1]
+		if strings.ContainsAny(query, " \t") {
+			options = map[string]interface{}{ir.PhraseOption: phrase}
+			query = fmt.Sprintf(`"%s"`, query)
+		}
+	}
+
+	return ir.Keyword{
+		QueryString: query,
+		Truncated:   isTruncated(query),
+		Options:     options,
+	}
+}
+
+// limit transforms a limit such as `[english]/lim` or `[2000-2010]/py` into a keyword, which is the limit when it
+// cannot restrict a query (see embaseComLimits).
+func (e EmbaseComTransformer) limit(value, kind string, mapping map[string][]string) ir.Keyword {
+	value = strings.TrimSpace(value)
+	var keyword ir.Keyword
+	if kind == "py" {
+		keyword = ir.Keyword{QueryString: value, Fields: mapping["py"]}
+		if !embaseComYearsRegexp.MatchString(value) {
+			return keyword
+		}
+		r, err := parseDateRange(value, "-")
+		if err != nil {
+			log.Println(err)
+			return keyword
+		}
+		keyword.Range = r
+		keyword.Options = map[string]interface{}{embaseComLimitOption: ir.Limit{Field: keyword.Fields[0], Range: r}}
+		return keyword
+	}
+
+	v := strings.ToLower(value)
+	if species, ok := medlineLimitSpecies[v]; ok {
+		// Emtree uses the singular form of species, e.g. `human`.
+		keyword = ir.Keyword{QueryString: strings.ToLower(strings.TrimSuffix(species, "s")), Fields: mapping["de"]}
+	} else if medlineLimitLanguages[v] {
+		keyword = ir.Keyword{QueryString: v, Fields: mapping["la"]}
+	} else {
+		keyword = ir.Keyword{QueryString: v, Fields: mapping["it"]}
+	}
+	keyword.Options = map[string]interface{}{embaseComLimitOption: ir.Limit{Field: keyword.Fields[0], Values: []string{keyword.QueryString}}}
+	return keyword
+}
+
+// embaseComLimits moves the limits which are operands of an and query into the limits of that query, e.g.
+// `#3 AND [english]/lim`. Limits combined with other operators (or which are the only operands of a query) do not
+// restrict a query, so they are searched as keywords.
+func embaseComLimits(q ir.BooleanQuery) ir.BooleanQuery {
+	for i, child := range q.Children {
+		q.Children[i] = embaseComLimits(child)
+	}
+
+	var keywords []ir.Keyword
+	var limits []ir.Limit
+	for _, keyword := range q.Keywords {
+		limit, ok := keyword.Options[embaseComLimitOption].(ir.Limit)
+		if !ok {
+			keywords = append(keywords, keyword)
+			continue
+		}
+		delete(keyword.Options, embaseComLimitOption)
+		if len(keyword.Options) == 0 {
+			keyword.Options = nil
+		}
+		if strings.ToLower(q.Operator) == cqr.AND {
+			limits = append(limits, limit)
+		} else {
+			keywords = append(keywords, keyword)
+		}
+	}
+	if len(limits) == 0 {
+		q.Keywords = keywords
+		return q
+	}
+	if len(keywords) == 0 && len(q.Children) == 0 {
+		// There is nothing to restrict.
+		return mapKeywords(q, func(keyword ir.Keyword) ir.Keyword {
+			delete(keyword.Options, embaseComLimitOption)
+			if len(keyword.Options) == 0 {
+				keyword.Options = nil
+			}
+			return keyword
+		})
+	}
+
+	q.Keywords = keywords
+	if len(keywords) == 0 && len(q.Children) == 1 {
+		// The query only restricts the query of its child.
+		child := q.Children[0]
+		child.Limits = append(child.Limits, limits...)
+		return child
+	}
+	q.Limits = append(q.Limits, limits...)
+	return q
+}
+
+// TransformSingle implements the transformation of a single term such as `dementia:ti,ab` or `'dementia'/exp`.
+func (e EmbaseComTransformer) TransformSingle(query string, mapping map[string][]string) ir.Keyword {
+	q := e.TransformNested(query, mapping)
+	if len(q.Keywords) == 0 {
+		return ir.Keyword{}
+	}
+	return q.Keywords[0]
+}
+
+// TransformNested implements the transformation of an Embase.com search strategy. Lines may refer to previous lines
+// (e.g. `#3 #1 OR #2`), and are expanded into a single query.
+func (e EmbaseComTransformer) TransformNested(query string, mapping map[string][]string) ir.BooleanQuery {
+	expanded, err := lexer.ExpandReferences(query, "#")
+	if err != nil {
+		log.Println(err)
+		return ir.BooleanQuery{}
+	}
+	q := embaseComLimits(parseExpression(expanded, e.dialect(mapping), mapping))
+
+	// Publication years are searched as ranges, e.g. `2005:py`.
+	return mapKeywords(q, func(keyword ir.Keyword) ir.Keyword {
+		if len(keyword.Fields) == 1 && keyword.Fields[0] == fields.PublicationDate {
+			if embaseComYearsRegexp.MatchString(keyword.QueryString) {
+				r, err := parseDateRange(keyword.QueryString, "-")
+				if err != nil {
+					log.Println(err)
+					return keyword
+				}
+				keyword.Range = r
+			}
+		}
+		return keyword
+	})
+}
+
+// dialect describes the Embase.com search syntax.
+func (e EmbaseComTransformer) dialect(mapping map[string][]string) expressionDialect {
+	return expressionDialect{
+		atoms:  []*regexp.Regexp{embaseComHeadingRegexp, embaseComLimitRegexp},
+		quotes: map[rune]rune{'\'': '\'', '"': '"'},
+		split: func(token string) []string {
+			if i := strings.Index(token, ":"); i > 0 && embaseComFieldRegexp.MatchString(token[i:]) {
+				return []string{token[:i], token[i:]}
+			}
+			return []string{token}
+		},
+		operator: func(token string) (expressionOperator, bool) {
+			switch strings.ToUpper(token) {
+			case "OR":
+				return expressionOperator{operator: cqr.OR, precedence: 1}, true
+			case "AND":
+				return expressionOperator{operator: cqr.AND, precedence: 2}, true
+			case "NOT":
+				return expressionOperator{operator: cqr.NOT, precedence: 2}, true
+			}
+			if proximity := embaseComProximityRegexp.FindStringSubmatch(token); proximity != nil {
+				// NEAR finds terms within n words in any order, and NEXT finds them in order. When no distance is
+				// given, NEAR uses a distance of 5, and NEXT a distance of 1.
+				if strings.ToUpper(proximity[1]) == "NEXT" {
+					distance := 1
+					if len(proximity[3]) > 0 {
+						distance, _ = strconv.Atoi(proximity[3])
+					}
+					return expressionOperator{operator: fmt.Sprintf("adj%d", distance), precedence: 3, options: map[string]interface{}{ir.OrderedOption: true}}, true
+				}
+				distance := 5
+				if len(proximity[3]) > 0 {
+					distance, _ = strconv.Atoi(proximity[3])
+				}
+				return expressionOperator{operator: fmt.Sprintf("adj%d", distance), precedence: 3}, true
+			}
+			return expressionOperator{}, false
+		},
+		suffix: func(token string) ([]string, bool) {
+			if embaseComFieldRegexp.MatchString(token) {
+				return transformFieldSuffix(token, mapping), true
+			}
+			return nil, false
+		},
+		keyword: func(term string) ir.Keyword {
+			return e.keyword(term, mapping)
+		},
+		// Terms which are not separated by an operator are combined with AND.
+		implicit: &expressionOperator{operator: cqr.AND, precedence: 2},
+	}
+}
+
+// NewEmbaseComParser creates a new parser for Embase.com search strategies.
+func NewEmbaseComParser() QueryParser {
+	return QueryParser{FieldMapping: EmbaseComFieldMapping, Parser: EmbaseComTransformer{}}
+}
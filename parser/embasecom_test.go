@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"testing"
+)
+
+var (
+	embaseComQueryString = `#1 'dementia'/exp OR 'alzheimer disease'/de OR 'delirium'/mj
+#2 (memory NEAR/3 loss):ti,ab,kw OR (mild NEXT/2 impair*):ti,ab
+#3 #1 OR #2
+#4 #3 AND [english]/lim AND [2000-2010]/py`
+)
+
+func TestEmbaseCom_BooleanQuery_Terms(t *testing.T) {
+	queryRep := NewEmbaseComParser().Parse(lexerNode(embaseComQueryString))
+
+	// The limits of the last line are not terms.
+	expected := 7
+	got := len(queryRep.Terms())
+	if expected != got {
+		t.Fatalf("Expected %v terms, got %v", expected, got)
+	}
+}
+
+func TestEmbaseCom_Headings(t *testing.T) {
+	queryRep := NewEmbaseComParser().Parse(lexerNode(`'dementia'/exp OR 'alzheimer disease'/de OR 'delirium'/exp/mj`))
+
+	if len(queryRep.Keywords) != 3 {
+		t.Fatalf("expected three headings, got %v", queryRep)
+	}
+	if k := queryRep.Keywords[0]; k.QueryString != "dementia" || !k.Exploded || k.Fields[0] != fields.EmtreeHeadings {
+		t.Fatalf("expected an exploded Emtree heading, got %v", k)
+	}
+	if k := queryRep.Keywords[1]; k.QueryString != "alzheimer disease" || k.Exploded || k.Fields[0] != fields.EmtreeHeadings {
+		t.Fatalf("expected an Emtree heading which is not exploded, got %v", k)
+	}
+	if k := queryRep.Keywords[2]; !k.Exploded || k.Fields[0] != fields.MajorFocusEmtreeHeading {
+		t.Fatalf("expected an exploded major Emtree heading, got %v", k)
+	}
+}
+
+func TestEmbaseCom_Proximity(t *testing.T) {
+	queryRep := NewEmbaseComParser().Parse(lexerNode(`(memory NEAR/3 loss):ti,ab,kw AND (mild NEXT/2 impair*):ti,ab`))
+
+	if len(queryRep.Children) != 2 {
+		t.Fatalf("expected two proximity queries, got %v", queryRep)
+	}
+	if queryRep.Children[0].Operator != "adj3" || queryRep.Children[0].Options[ir.OrderedOption] != nil {
+		t.Fatalf("expected an unordered proximity query, got %v", queryRep.Children[0])
+	}
+	if len(queryRep.Children[0].Keywords[0].Fields) != 2 {
+		t.Fatalf("expected the title, abstract and keyword fields, got %v", queryRep.Children[0].Keywords[0].Fields)
+	}
+	if queryRep.Children[1].Operator != "adj2" || queryRep.Children[1].Options[ir.OrderedOption] != true {
+		t.Fatalf("expected an ordered proximity query, got %v", queryRep.Children[1])
+	}
+	if k := queryRep.Children[1].Keywords[1]; !k.Truncated || k.Fields[0] != fields.TitleAbstract {
+		t.Fatalf("expected a truncated title and abstract keyword, got %v", k)
+	}
+}
+
+func TestEmbaseCom_Limits(t *testing.T) {
+	queryRep := NewEmbaseComParser().Parse(lexerNode(`dementia AND [english]/lim AND [humans]/lim AND [2000-2010]/py`))
+
+	if len(queryRep.Keywords) != 1 || len(queryRep.Limits) != 3 {
+		t.Fatalf("expected a keyword restricted by three limits, got %v", queryRep)
+	}
+	if l := queryRep.Limits[0]; l.Field != fields.Language || len(l.Values) != 1 || l.Values[0] != "english" {
+		t.Fatalf("expected a language limit, got %v", l)
+	}
+	if l := queryRep.Limits[1]; l.Field != fields.EmtreeHeadings || len(l.Values) != 1 || l.Values[0] != "human" {
+		t.Fatalf("expected a species limit, got %v", l)
+	}
+	l := queryRep.Limits[2]
+	if l.Field != fields.PublicationDate || l.Range == nil {
+		t.Fatalf("expected a publication year range, got %v", l)
+	}
+	if l.Range.Start.Year() != 2000 || l.Range.End.Year() != 2010 {
+		t.Fatalf("expected the years 2000 to 2010, got %v", l.Range)
+	}
+	for _, keyword := range queryRep.Keywords {
+		if keyword.Options != nil {
+			t.Fatalf("expected the keyword to have no options, got %v", keyword)
+		}
+	}
+}
+
+func TestEmbaseCom_LimitsNested(t *testing.T) {
+	queryRep := NewEmbaseComParser().Parse(lexerNode(`#1 dementia OR amnesia
+#2 #1 AND [english]/lim`))
+
+	// The line which is limited is restricted rather than combined with the limit.
+	if queryRep.Operator != "or" || len(queryRep.Keywords) != 2 || len(queryRep.Limits) != 1 {
+		t.Fatalf("expected a limited or query, got %v", queryRep)
+	}
+
+	// A limit which does not restrict a query is searched as a keyword.
+	queryRep = NewEmbaseComParser().Parse(lexerNode(`dementia OR [english]/lim`))
+	if len(queryRep.Limits) != 0 || len(queryRep.Keywords) != 2 {
+		t.Fatalf("expected two keywords, got %v", queryRep)
+	}
+	if k := queryRep.Keywords[1]; k.QueryString != "english" || k.Fields[0] != fields.Language || k.Options != nil {
+		t.Fatalf("expected a language keyword, got %v", k)
+	}
+}
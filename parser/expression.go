@@ -0,0 +1,403 @@
+package parser
+
+import (
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// expressionOperator is a binary operator that may appear in an infix search expression.
+type expressionOperator struct {
+	// operator is the name of the operator in the immediate representation (e.g. `and` or `adj3`).
+	operator string
+	// precedence determines how tightly the operator binds its operands; higher values bind tighter.
+	precedence int
+	// options are set on the Boolean query created by the operator (e.g. for ordered proximity).
+	options map[string]interface{}
+}
+
+// expressionDialect describes the syntax of the infix search expressions of a particular database. Most databases
+// share the same basic structure of keywords, parenthesis and infix operators, and differ only in how operators and
+// fields are written. Only the operator and keyword functions are required.
+type expressionDialect struct {
+	// atoms are tokens which must not be split further, e.g. `MeSH descriptor: [X] explode all trees`.
+	atoms []*regexp.Regexp
+	// quotes maps the opening character of a quoted phrase to the closing character. It defaults to `"`.
+	quotes map[rune]rune
+	// split may further divide a token, e.g. `dementia:ti` into `dementia` and `:ti`.
+	split func(token string) []string
+	// operator reports whether a token is an operator.
+	operator func(token string) (expressionOperator, bool)
+	// prefix reports whether a token is a field restriction applied to the operand that follows it (e.g. `TI`).
+	prefix func(token string) ([]string, bool)
+	// suffix reports whether a token is a field restriction applied to the operand that precedes it (e.g. `:ti,ab`).
+	suffix func(token string) ([]string, bool)
+	// keyword transforms a term into a keyword. Keywords without fields are assigned the fields of the closest
+	// enclosing prefix or suffix, otherwise the default fields of the mapping.
+	keyword func(term string) ir.Keyword
+	// implicit is the operator between two operands which are not separated by an operator. When it is nil,
+	// consecutive terms are instead joined into a single keyword.
+	implicit *expressionOperator
+}
+
+// expressionOperand is either a single keyword or a Boolean query produced while parsing an expression.
+type expressionOperand struct {
+	keyword *ir.Keyword
+	query   ir.BooleanQuery
+}
+
+// expressionParser is a precedence climbing parser for infix search expressions.
+type expressionParser struct {
+	dialect expressionDialect
+	tokens  []string
+	pos     int
+}
+
+// parseExpression parses an infix search expression into the immediate representation using the syntax described
+// by the dialect. Keywords without any fields are assigned the default fields of the mapping.
+func parseExpression(query string, dialect expressionDialect, mapping map[string][]string) ir.BooleanQuery {
+	p := expressionParser{dialect: dialect, tokens: tokeniseExpression(query, dialect)}
+	if len(p.tokens) == 0 {
+		return ir.BooleanQuery{}
+	}
+
+	operand := p.parseExpression(0)
+	for p.pos < len(p.tokens) {
+		// Recover from unbalanced parenthesis by treating the remainder of the query as a conjunction.
+		log.Printf("unexpected token `%v` in query, ignoring\n", p.tokens[p.pos])
+		p.pos++
+		if p.pos < len(p.tokens) {
+			operand = combineOperands(expressionOperator{operator: cqr.AND}, operand, p.parseExpression(0))
+		}
+	}
+
+	return operand.withFields(mapping["default"]).booleanQuery()
+}
+
+// tokeniseExpression splits an expression into keywords, operators, fields and parenthesis.
+func tokeniseExpression(query string, dialect expressionDialect) []string {
+	quotes := dialect.quotes
+	if quotes == nil {
+		quotes = map[rune]rune{'"': '"'}
+	}
+
+	var tokens []string
+	var current []rune
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		token := string(current)
+		current = nil
+		if dialect.split != nil {
+			for _, t := range dialect.split(token) {
+				if len(t) > 0 {
+					tokens = append(tokens, t)
+				}
+			}
+			return
+		}
+		tokens = append(tokens, token)
+	}
+
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		char := runes[i]
+
+		// Atoms can only begin at the start of a token.
+		if len(current) == 0 {
+			found := false
+			for _, atom := range dialect.atoms {
+				if loc := atom.FindStringIndex(string(runes[i:])); loc != nil && loc[0] == 0 && loc[1] > 0 {
+					tokens = append(tokens, string(runes[i:])[:loc[1]])
+					i += len([]rune(string(runes[i:])[:loc[1]])) - 1
+					found = true
+					break
+				}
+			}
+			if found {
+				continue
+			}
+		}
+
+		if closing, ok := quotes[char]; ok {
+			flush()
+			// Quoted phrases are kept intact, including the quotes.
+			current = append(current, char)
+			for i++; i < len(runes); i++ {
+				current = append(current, runes[i])
+				if runes[i] == closing {
+					break
+				}
+			}
+			tokens = append(tokens, string(current))
+			current = nil
+			continue
+		}
+
+		switch {
+		case char == '(' || char == ')':
+			flush()
+			tokens = append(tokens, string(char))
+		case unicode.IsSpace(char):
+			flush()
+		default:
+			current = append(current, char)
+		}
+	}
+	flush()
+	return tokens
+}
+
+func (p *expressionParser) peek() (string, bool) {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos], true
+	}
+	return "", false
+}
+
+// isTerm tests if a token can be part of a keyword.
+func (p *expressionParser) isTerm(token string) bool {
+	if token == "(" || token == ")" {
+		return false
+	}
+	if _, ok := p.dialect.operator(token); ok {
+		return false
+	}
+	if p.dialect.prefix != nil {
+		if _, ok := p.dialect.prefix(token); ok {
+			return false
+		}
+	}
+	if p.dialect.suffix != nil {
+		if _, ok := p.dialect.suffix(token); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// parseExpression parses a sequence of operands separated by operators which bind at least as tightly as
+// minPrecedence.
+func (p *expressionParser) parseExpression(minPrecedence int) expressionOperand {
+	lhs := p.parseOperand()
+	for {
+		token, ok := p.peek()
+		if !ok || token == ")" {
+			return lhs
+		}
+		op, ok := p.dialect.operator(token)
+		if ok {
+			if op.precedence < minPrecedence {
+				return lhs
+			}
+			p.pos++
+		} else if p.dialect.implicit != nil {
+			op = *p.dialect.implicit
+			if op.precedence < minPrecedence {
+				return lhs
+			}
+		} else {
+			return lhs
+		}
+		rhs := p.parseExpression(op.precedence + 1)
+		lhs = combineOperands(op, lhs, rhs)
+	}
+}
+
+// parseOperand parses a keyword or parenthesised expression, including any fields that restrict it.
+func (p *expressionParser) parseOperand() expressionOperand {
+	token, ok := p.peek()
+	if !ok || token == ")" {
+		return expressionOperand{query: ir.BooleanQuery{}}
+	}
+	p.pos++
+
+	var operand expressionOperand
+	if p.dialect.prefix != nil {
+		if f, ok := p.dialect.prefix(token); ok {
+			return p.parseSuffix(p.parseOperand().withFields(f))
+		}
+	}
+
+	if token == "(" {
+		operand = p.parseExpression(0)
+		if t, ok := p.peek(); ok && t == ")" {
+			p.pos++
+		} else {
+			log.Println("missing closing parenthesis in query")
+		}
+	} else {
+		term := token
+		if p.dialect.implicit == nil {
+			for {
+				t, ok := p.peek()
+				if !ok || !p.isTerm(t) {
+					break
+				}
+				term += " " + t
+				p.pos++
+			}
+		}
+		k := p.dialect.keyword(term)
+		operand = expressionOperand{keyword: &k}
+	}
+	return p.parseSuffix(operand)
+}
+
+// parseSuffix applies any fields which follow an operand.
+func (p *expressionParser) parseSuffix(operand expressionOperand) expressionOperand {
+	if p.dialect.suffix == nil {
+		return operand
+	}
+	for {
+		token, ok := p.peek()
+		if !ok {
+			return operand
+		}
+		f, ok := p.dialect.suffix(token)
+		if !ok {
+			return operand
+		}
+		p.pos++
+		operand = operand.withFields(f)
+	}
+}
+
+// booleanQuery converts the operand into a Boolean query. A single keyword is wrapped in an `or` query.
+func (o expressionOperand) booleanQuery() ir.BooleanQuery {
+	if o.keyword != nil {
+		return ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{*o.keyword}}
+	}
+	return o.query
+}
+
+// withFields assigns fields to every keyword in the operand that does not already have fields.
+func (o expressionOperand) withFields(f []string) expressionOperand {
+	if o.keyword != nil {
+		k := *o.keyword
+		if len(k.Fields) == 0 {
+			k.Fields = f
+		}
+		return expressionOperand{keyword: &k}
+	}
+	return expressionOperand{query: queryWithFields(o.query, f)}
+}
+
+// withOption sets an option on the keyword or query of the operand.
+func (o expressionOperand) withOption(option string, value interface{}) expressionOperand {
+	if o.keyword != nil {
+		k := *o.keyword
+		options := map[string]interface{}{option: value}
+		for key, v := range k.Options {
+			if _, ok := options[key]; !ok {
+				options[key] = v
+			}
+		}
+		k.Options = options
+		return expressionOperand{keyword: &k}
+	}
+	q := o.query
+	options := map[string]interface{}{option: value}
+	for key, v := range q.Options {
+		if _, ok := options[key]; !ok {
+			options[key] = v
+		}
+	}
+	q.Options = options
+	return expressionOperand{query: q}
+}
+
+func queryWithFields(q ir.BooleanQuery, f []string) ir.BooleanQuery {
+	keywords := make([]ir.Keyword, len(q.Keywords))
+	for i, k := range q.Keywords {
+		if len(k.Fields) == 0 {
+			k.Fields = f
+		}
+		keywords[i] = k
+	}
+	children := make([]ir.BooleanQuery, len(q.Children))
+	for i, child := range q.Children {
+		children[i] = queryWithFields(child, f)
+	}
+	if q.Keywords != nil {
+		q.Keywords = keywords
+	}
+	if q.Children != nil {
+		q.Children = children
+	}
+	return q
+}
+
+// sameOperator tests if a query was created by the operator, so that the two can be flattened into one query.
+func sameOperator(q ir.BooleanQuery, op expressionOperator) bool {
+	if q.Operator != op.operator || len(q.Options) != len(op.options) {
+		return false
+	}
+	for k, v := range op.options {
+		if q.Options[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// combineOperands creates a query from two operands. Queries created from the same operator are flattened. The
+// operands of the query keep the order of ir.BooleanQuery (keywords, then children), so for `kw NOT (query)` the
+// keyword is the first operand, and for `(query) NOT kw` the keyword is wrapped in a child after the query.
+func combineOperands(op expressionOperator, lhs, rhs expressionOperand) expressionOperand {
+	q := ir.BooleanQuery{Operator: op.operator}
+	if len(op.options) > 0 {
+		q.Options = make(map[string]interface{})
+		for k, v := range op.options {
+			q.Options[k] = v
+		}
+	}
+
+	if lhs.keyword == nil && sameOperator(lhs.query, op) {
+		q = lhs.query
+	} else {
+		q = appendOperand(q, lhs)
+	}
+
+	// The first operand of a `not` must remain first, so a keyword is not allowed to move in front of a query.
+	if op.operator == cqr.NOT && len(q.Children) > 0 && rhs.keyword != nil {
+		rhs = expressionOperand{query: ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{*rhs.keyword}}}
+	}
+
+	if rhs.keyword == nil && op.operator != cqr.NOT && sameOperator(rhs.query, op) {
+		q.Keywords = append(q.Keywords, rhs.query.Keywords...)
+		q.Children = append(q.Children, rhs.query.Children...)
+	} else {
+		q = appendOperand(q, rhs)
+	}
+	return expressionOperand{query: q}
+}
+
+func appendOperand(q ir.BooleanQuery, o expressionOperand) ir.BooleanQuery {
+	if o.keyword != nil {
+		q.Keywords = append(q.Keywords, *o.keyword)
+	} else if len(o.query.Operator) > 0 || len(o.query.Keywords) > 0 || len(o.query.Children) > 0 {
+		q.Children = append(q.Children, o.query)
+	}
+	return q
+}
+
+// isTruncated tests if a query string contains a truncation or wildcard character.
+func isTruncated(queryString string) bool {
+	return strings.ContainsAny(queryString, "*$?~")
+}
+
+// mapKeywords applies a function to every keyword in a query.
+func mapKeywords(q ir.BooleanQuery, f func(keyword ir.Keyword) ir.Keyword) ir.BooleanQuery {
+	for i, keyword := range q.Keywords {
+		q.Keywords[i] = f(keyword)
+	}
+	for i, child := range q.Children {
+		q.Children[i] = mapKeywords(child, f)
+	}
+	return q
+}
@@ -0,0 +1,22 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestExpression_NotOperands(t *testing.T) {
+	// The keyword is the first operand, so it stays a keyword.
+	q := NewCochraneLibParser().Parse(lexerNode(`dementia:ti NOT (mice OR rats):ti`))
+	if q.Operator != "not" || len(q.Keywords) != 1 || q.Keywords[0].QueryString != "dementia" || len(q.Children) != 1 {
+		t.Fatalf("expected dementia to be the first operand, got %v", q)
+	}
+
+	// The keyword is excluded from the query, so it must follow the query as a child.
+	q = NewCochraneLibParser().Parse(lexerNode(`(mice OR rats):ti NOT dementia:ti`))
+	if q.Operator != "not" || len(q.Keywords) != 0 || len(q.Children) != 2 {
+		t.Fatalf("expected two children, got %v", q)
+	}
+	if len(q.Children[1].Keywords) != 1 || q.Children[1].Keywords[0].QueryString != "dementia" {
+		t.Fatalf("expected dementia to be the second operand, got %v", q.Children[1])
+	}
+}
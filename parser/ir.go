@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"encoding/json"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+)
+
+// IrTransformer is an implementation of a query transformer for the JSON encoding of the immediate representation,
+// as output by the IrBackend. Unlike CQR, the immediate representation retains every field and option of a query.
+type IrTransformer struct{}
+
+// mapKeywordFields maps the fields of a keyword, keeping any fields without a mapping as they are.
+func mapKeywordFields(keyword ir.Keyword, mapping map[string][]string) ir.Keyword {
+	if len(keyword.Fields) == 0 {
+		keyword.Fields = mapping["default"]
+		return keyword
+	}
+	var queryFields []string
+	for _, f := range keyword.Fields {
+		if v, ok := mapping[f]; ok {
+			queryFields = append(queryFields, v...)
+		} else {
+			queryFields = append(queryFields, f)
+		}
+	}
+	keyword.Fields = queryFields
+	return keyword
+}
+
+// TransformSingle takes a JSON encoded keyword and parses it into the ir.
+func (i IrTransformer) TransformSingle(query string, mapping map[string][]string) ir.Keyword {
+	var keyword ir.Keyword
+	err := json.Unmarshal([]byte(query), &keyword)
+	if err != nil {
+		log.Println(err)
+		return ir.Keyword{}
+	}
+	return mapKeywordFields(keyword, mapping)
+}
+
+// TransformNested takes a JSON encoded Boolean query and parses it into the ir. A JSON encoded keyword is also
+// accepted, in which case it is wrapped in an `or` query.
+func (i IrTransformer) TransformNested(query string, mapping map[string][]string) ir.BooleanQuery {
+	var rep map[string]json.RawMessage
+	err := json.Unmarshal([]byte(query), &rep)
+	if err != nil {
+		log.Println(err)
+		return ir.BooleanQuery{}
+	}
+
+	if _, ok := rep["operator"]; !ok {
+		if _, ok := rep["query"]; ok {
+			return ir.BooleanQuery{Operator: "or", Keywords: []ir.Keyword{i.TransformSingle(query, mapping)}}
+		}
+	}
+
+	var q ir.BooleanQuery
+	err = json.Unmarshal([]byte(query), &q)
+	if err != nil {
+		log.Println(err)
+		return ir.BooleanQuery{}
+	}
+	return mapKeywords(q, func(keyword ir.Keyword) ir.Keyword {
+		return mapKeywordFields(keyword, mapping)
+	})
+}
+
+// NewIrParser creates a new parser for the JSON encoding of the immediate representation.
+func NewIrParser() QueryParser {
+	return QueryParser{Parser: IrTransformer{}, FieldMapping: map[string][]string{"default": {fields.AllFields}}}
+}
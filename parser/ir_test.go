@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"encoding/json"
+	"github.com/hscells/transmute/ir"
+	"reflect"
+	"testing"
+)
+
+var (
+	irQuery = ir.BooleanQuery{
+		Operator: "and",
+		Keywords: []ir.Keyword{
+			{QueryString: "Dementia", Fields: []string{"mesh_headings"}, Exploded: true},
+		},
+		Children: []ir.BooleanQuery{
+			{
+				Operator: "adj3",
+				Keywords: []ir.Keyword{
+					{QueryString: "memory", Fields: []string{"title"}},
+					{QueryString: "loss*", Fields: []string{"title"}, Truncated: true, Options: map[string]interface{}{"phrase": "exact"}},
+				},
+				Options: map[string]interface{}{ir.OrderedOption: true},
+			},
+		},
+	}
+)
+
+func TestIr_RoundTrip(t *testing.T) {
+	b, err := json.Marshal(irQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queryRep := NewIrParser().Parse(lexerNode(string(b)))
+	if !reflect.DeepEqual(irQuery, queryRep) {
+		t.Fatalf("expected %v, got %v", irQuery, queryRep)
+	}
+}
+
+func TestIr_Keyword(t *testing.T) {
+	queryRep := NewIrParser().Parse(lexerNode(`{"query": "dementia"}`))
+	if len(queryRep.Keywords) != 1 || queryRep.Keywords[0].Fields[0] != "all_fields" {
+		t.Fatalf("expected a single keyword with the default field, got %v", queryRep)
+	}
+}
@@ -0,0 +1,384 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Lucene clauses are either required (+), prohibited (-), or optional.
+const (
+	luceneShould = iota
+	luceneMust
+	luceneMustNot
+)
+
+// luceneClause is a single clause of a Lucene query and how it must occur.
+type luceneClause struct {
+	occur   int
+	operand expressionOperand
+}
+
+// LuceneTransformer is an implementation of a QueryTransformer for the classic Lucene (and Solr) query syntax. The
+// default operator between clauses is OR, as it is in Lucene.
+//
+// Optional clauses do not change which documents a Lucene query matches when it has required clauses, so these are
+// not kept in the ir. The terms of a phrase may be prefixed by a field, as is done by the TerrierBackend.
+type LuceneTransformer struct{}
+
+// luceneParser parses a tokenised Lucene query.
+type luceneParser struct {
+	tokens  []string
+	pos     int
+	mapping map[string][]string
+}
+
+// TransformSingle implements the transformation of a single clause, e.g. `title:dementia^2`.
+func (l LuceneTransformer) TransformSingle(query string, mapping map[string][]string) ir.Keyword {
+	q := l.TransformNested(query, mapping)
+	if len(q.Keywords) == 0 {
+		return ir.Keyword{}
+	}
+	return q.Keywords[0]
+}
+
+// TransformNested implements the transformation of a Lucene query.
+func (l LuceneTransformer) TransformNested(query string, mapping map[string][]string) ir.BooleanQuery {
+	p := luceneParser{tokens: tokeniseLucene(query), mapping: mapping}
+	operand, ok := p.parseQuery()
+	for p.pos < len(p.tokens) {
+		log.Printf("unexpected token `%v` in query, ignoring\n", p.tokens[p.pos])
+		p.pos++
+		if more, ok2 := p.parseQuery(); ok2 {
+			if ok {
+				operand = combineOperands(expressionOperator{operator: cqr.OR}, operand, more)
+			} else {
+				operand, ok = more, true
+			}
+		}
+	}
+	if !ok {
+		return ir.BooleanQuery{}
+	}
+	return operand.withFields(mapping["default"]).booleanQuery()
+}
+
+// tokeniseLucene splits a Lucene query into terms, phrases, ranges, fields (which end in `:`), modifiers, operators,
+// parenthesis, and the slop (`~n`) and boost (`^n`) suffixes.
+func tokeniseLucene(query string) []string {
+	var tokens []string
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			tokens = append(tokens, string(current))
+			current = nil
+		}
+	}
+
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		char := runes[i]
+		switch {
+		case char == '\\' && i+1 < len(runes):
+			// Escaped characters are always part of a term.
+			i++
+			current = append(current, runes[i])
+		case unicode.IsSpace(char):
+			flush()
+		case char == '(' || char == ')':
+			flush()
+			tokens = append(tokens, string(char))
+		case char == '"':
+			flush()
+			current = append(current, char)
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				} else if runes[i] == '"' {
+					break
+				}
+				current = append(current, runes[i])
+			}
+			current = append(current, '"')
+			flush()
+		case (char == '[' || char == '{') && len(current) == 0:
+			// Ranges end with either bracket, e.g. `[2000 TO 2010}`.
+			current = append(current, char)
+			for i++; i < len(runes); i++ {
+				current = append(current, runes[i])
+				if runes[i] == ']' || runes[i] == '}' {
+					break
+				}
+			}
+			flush()
+		case (char == '+' || char == '-' || char == '!') && len(current) == 0:
+			tokens = append(tokens, string(char))
+		case (char == '&' || char == '|') && i+1 < len(runes) && runes[i+1] == char && len(current) == 0:
+			tokens = append(tokens, string([]rune{char, char}))
+			i++
+		case char == ':' && len(current) > 0:
+			current = append(current, char)
+			flush()
+		case char == '^' || char == '~':
+			flush()
+			current = append(current, char)
+			for i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.') {
+				i++
+				current = append(current, runes[i])
+			}
+			flush()
+		default:
+			current = append(current, char)
+		}
+	}
+	flush()
+	return tokens
+}
+
+func (p *luceneParser) peek() (string, bool) {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos], true
+	}
+	return "", false
+}
+
+// parseQuery parses a sequence of clauses, up to a closing parenthesis.
+func (p *luceneParser) parseQuery() (expressionOperand, bool) {
+	var clauses []luceneClause
+	conjunction := ""
+	for {
+		token, ok := p.peek()
+		if !ok || token == ")" {
+			break
+		}
+		switch token {
+		case "AND", "&&":
+			conjunction = cqr.AND
+			p.pos++
+			continue
+		case "OR", "||":
+			conjunction = cqr.OR
+			p.pos++
+			continue
+		}
+
+		occur := luceneShould
+		switch token {
+		case "+":
+			occur = luceneMust
+			p.pos++
+		case "-", "!", "NOT":
+			occur = luceneMustNot
+			p.pos++
+		}
+
+		operand, ok := p.parseClause()
+		if !ok {
+			continue
+		}
+
+		// This follows the way the classic Lucene query parser adds clauses when the default operator is OR.
+		if len(clauses) > 0 && conjunction == cqr.AND && clauses[len(clauses)-1].occur != luceneMustNot {
+			clauses[len(clauses)-1].occur = luceneMust
+		}
+		if conjunction == cqr.AND && occur == luceneShould {
+			occur = luceneMust
+		}
+		clauses = append(clauses, luceneClause{occur: occur, operand: operand})
+		conjunction = ""
+	}
+
+	var must, should, mustNot []expressionOperand
+	for _, clause := range clauses {
+		switch clause.occur {
+		case luceneMust:
+			must = append(must, clause.operand)
+		case luceneMustNot:
+			mustNot = append(mustNot, clause.operand)
+		default:
+			should = append(should, clause.operand)
+		}
+	}
+
+	var operand expressionOperand
+	if len(must) > 0 {
+		if len(should) > 0 {
+			log.Printf("ignoring %v optional clauses which do not affect the documents matched\n", len(should))
+		}
+		operand = combineAll(cqr.AND, must)
+	} else if len(should) > 0 {
+		operand = combineAll(cqr.OR, should)
+	} else {
+		if len(mustNot) > 0 {
+			log.Println("a query containing only prohibited clauses matches no documents, ignoring")
+		}
+		return expressionOperand{}, false
+	}
+
+	if len(mustNot) > 0 {
+		operand = combineAll(cqr.NOT, append([]expressionOperand{operand}, mustNot...))
+	}
+	return operand, true
+}
+
+// parseClause parses a term, phrase, range or parenthesised query with its field, slop and boost.
+func (p *luceneParser) parseClause() (expressionOperand, bool) {
+	token, ok := p.peek()
+	if !ok {
+		return expressionOperand{}, false
+	}
+
+	var queryFields []string
+	if len(token) > 1 && strings.HasSuffix(token, ":") {
+		queryFields = p.field(strings.TrimSuffix(token, ":"))
+		p.pos++
+		token, ok = p.peek()
+		if !ok {
+			return expressionOperand{}, false
+		}
+	}
+	p.pos++
+
+	var operand expressionOperand
+	switch {
+	case token == "(":
+		operand, ok = p.parseQuery()
+		if t, ok := p.peek(); ok && t == ")" {
+			p.pos++
+		} else {
+			log.Println("missing closing parenthesis in query")
+		}
+		if !ok {
+			return expressionOperand{}, false
+		}
+	case strings.HasPrefix(token, `"`):
+		slop := -1
+		if t, ok := p.peek(); ok && strings.HasPrefix(t, "~") {
+			p.pos++
+			slop, _ = strconv.Atoi(t[1:])
+		}
+		operand = p.phrase(token, slop)
+	case strings.HasPrefix(token, "[") || strings.HasPrefix(token, "{"):
+		r, err := luceneDateRange(token)
+		if err != nil {
+			log.Printf("%v, ignoring\n", err)
+			return expressionOperand{}, false
+		}
+		k := ir.Keyword{QueryString: token, Range: r}
+		operand = expressionOperand{keyword: &k}
+	default:
+		if t, ok := p.peek(); ok && strings.HasPrefix(t, "~") {
+			log.Printf("fuzzy queries are not supported, searching for `%v` exactly\n", token)
+			p.pos++
+		}
+		k := ir.Keyword{QueryString: token, Truncated: isTruncated(token)}
+		operand = expressionOperand{keyword: &k}
+	}
+
+	if t, ok := p.peek(); ok && strings.HasPrefix(t, "^") {
+		p.pos++
+		boost, err := strconv.ParseFloat(t[1:], 64)
+		if err == nil {
+			operand = operand.withOption(ir.BoostOption, boost)
+		}
+	}
+
+	if queryFields != nil {
+		operand = operand.withFields(queryFields)
+	}
+	return operand, true
+}
+
+// phrase creates a keyword from a phrase, or an adjacency query when the phrase has a slop.
+func (p *luceneParser) phrase(phrase string, slop int) expressionOperand {
+	inner := strings.Trim(phrase, `"`)
+	var keywords []ir.Keyword
+	for _, term := range strings.Fields(inner) {
+		k := ir.Keyword{QueryString: term, Truncated: isTruncated(term)}
+		if i := strings.Index(term, ":"); i > 0 {
+			k.QueryString = term[i+1:]
+			k.Fields = p.field(term[:i])
+		}
+		keywords = append(keywords, k)
+	}
+
+	if slop < 0 || len(keywords) < 2 {
+		k := ir.Keyword{QueryString: phrase, Truncated: isTruncated(phrase)}
+		if len(keywords) > 0 && len(keywords[0].Fields) > 0 {
+			var terms []string
+			for _, keyword := range keywords {
+				terms = append(terms, keyword.QueryString)
+			}
+			k.QueryString = fmt.Sprintf(`"%s"`, strings.Join(terms, " "))
+			k.Fields = keywords[0].Fields
+		}
+		return expressionOperand{keyword: &k}
+	}
+
+	q := ir.BooleanQuery{Operator: "adj", Keywords: keywords}
+	if slop > 0 {
+		q.Operator = fmt.Sprintf("adj%d", slop)
+	}
+	return expressionOperand{query: q}
+}
+
+// luceneDateRange parses a range of dates, e.g. `[2000 TO 2010]` or `{2000-01-01T00:00:00Z TO *}`. Bounds in
+// brackets are inclusive and bounds in braces are exclusive, so the day after (or before) an exclusive bound is used.
+// Only the date of a bound is kept, and `*` is an open bound.
+func luceneDateRange(token string) (*ir.DateRange, error) {
+	if len(token) < 2 || (token[len(token)-1] != ']' && token[len(token)-1] != '}') {
+		return nil, fmt.Errorf("range `%v` is not closed", token)
+	}
+	bounds := strings.Fields(token[1 : len(token)-1])
+	if len(bounds) != 3 || bounds[1] != "TO" {
+		return nil, fmt.Errorf("`%v` is not a range of the form `[a TO b]`", token)
+	}
+
+	bound := func(b string, end, exclusive bool) (time.Time, error) {
+		if b == "*" {
+			return time.Time{}, nil
+		}
+		if i := strings.Index(b, "T"); i > 0 {
+			b = b[:i]
+		}
+		date, err := parseDate(b, end != exclusive)
+		if err != nil || !exclusive {
+			return date, err
+		}
+		if end {
+			return date.AddDate(0, 0, -1), nil
+		}
+		return date.AddDate(0, 0, 1), nil
+	}
+
+	var r ir.DateRange
+	var err error
+	r.Start, err = bound(bounds[0], false, token[0] == '{')
+	if err != nil {
+		return nil, err
+	}
+	r.End, err = bound(bounds[2], true, token[len(token)-1] == '}')
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// field maps a Lucene field, keeping fields without a mapping as they are.
+func (p *luceneParser) field(field string) []string {
+	if f, ok := p.mapping[field]; ok {
+		return f
+	}
+	return []string{field}
+}
+
+// NewLuceneParser creates a new parser for classic Lucene queries.
+func NewLuceneParser() QueryParser {
+	return QueryParser{Parser: LuceneTransformer{}, FieldMapping: map[string][]string{"default": {fields.AllFields}}}
+}
@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"github.com/hscells/transmute/ir"
+	"testing"
+	"time"
+)
+
+var (
+	luceneQueryString = `+(title:dementia* OR "memory loss"~3) +abstract:(alzheimer^2 delirium) -title:review`
+)
+
+func TestLucene_BooleanQuery_Terms(t *testing.T) {
+	queryRep := NewLuceneParser().Parse(lexerNode(luceneQueryString))
+
+	expected := 6
+	got := len(queryRep.Terms())
+	if expected != got {
+		t.Fatalf("Expected %v terms, got %v", expected, got)
+	}
+
+	if queryRep.Operator != "not" || len(queryRep.Children) != 2 || queryRep.Children[0].Operator != "and" {
+		t.Fatalf("expected the prohibited clause to be removed from the required clauses, got %v", queryRep)
+	}
+}
+
+func TestLucene_Clauses(t *testing.T) {
+	queryRep := NewLuceneParser().Parse(lexerNode(`dementia AND delirium OR alzheimer`))
+	if queryRep.Operator != "and" || len(queryRep.Keywords) != 2 {
+		t.Fatalf("expected the optional clause to be ignored, got %v", queryRep)
+	}
+
+	queryRep = NewLuceneParser().Parse(lexerNode(`dementia delirium NOT title:review`))
+	if queryRep.Operator != "not" || queryRep.Children[0].Operator != "or" {
+		t.Fatalf("expected optional clauses to be combined with OR, got %v", queryRep)
+	}
+	if queryRep.Children[1].Keywords[0].Fields[0] != "title" {
+		t.Fatalf("expected the field to be kept, got %v", queryRep.Children[1])
+	}
+}
+
+func TestLucene_Phrases(t *testing.T) {
+	queryRep := NewLuceneParser().Parse(lexerNode(`"memory loss"~3 OR "heart attack" OR dialy?is^1.5`))
+
+	if len(queryRep.Children) != 1 || queryRep.Children[0].Operator != "adj3" {
+		t.Fatalf("expected an adj3 query, got %v", queryRep)
+	}
+	if queryRep.Keywords[0].QueryString != `"heart attack"` {
+		t.Fatalf("expected a phrase, got %v", queryRep.Keywords[0])
+	}
+	if !queryRep.Keywords[1].Truncated || queryRep.Keywords[1].Options[ir.BoostOption] != 1.5 {
+		t.Fatalf("expected a boosted wildcard, got %v", queryRep.Keywords[1])
+	}
+
+	// Phrases produced by the Terrier backend have a field on each term.
+	queryRep = NewLuceneParser().Parse(lexerNode(`"title:memory title:loss"~2`))
+	if queryRep.Operator != "adj2" || queryRep.Keywords[0].Fields[0] != "title" || queryRep.Keywords[0].QueryString != "memory" {
+		t.Fatalf("expected an adj2 query on the title, got %v", queryRep)
+	}
+}
+
+func TestLucene_Ranges(t *testing.T) {
+	queryRep := NewLuceneParser().Parse(lexerNode(`+dementia +date:[2000 TO 2010]`))
+
+	if queryRep.Operator != "and" || len(queryRep.Keywords) != 2 {
+		t.Fatalf("expected a term and a range, got %v", queryRep)
+	}
+	k := queryRep.Keywords[1]
+	if k.Range == nil || k.Fields[0] != "date" {
+		t.Fatalf("expected a date range, got %v", k)
+	}
+	if k.Range.Start != time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC) || k.Range.End != time.Date(2010, 12, 31, 0, 0, 0, 0, time.UTC) {
+		t.Fatalf("expected the years 2000 to 2010, got %v", k.Range)
+	}
+
+	// Exclusive bounds do not include the dates themselves, and `*` is an open bound.
+	k = NewLuceneParser().Parse(lexerNode(`date:{2000-06-15 TO *]`)).Keywords[0]
+	if k.Range == nil || k.Range.Start != time.Date(2000, 6, 16, 0, 0, 0, 0, time.UTC) || !k.Range.End.IsZero() {
+		t.Fatalf("expected a range starting on 2000/06/16, got %v", k)
+	}
+	k = NewLuceneParser().Parse(lexerNode(`date:[2000-01-01T00:00:00Z TO 2010}`)).Keywords[0]
+	if k.Range == nil || k.Range.Start != time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC) || k.Range.End != time.Date(2009, 12, 31, 0, 0, 0, 0, time.UTC) {
+		t.Fatalf("expected a range from 2000/01/01 to 2009/12/31, got %v", k)
+	}
+
+	// Ranges which are not dates are ignored rather than searched as terms.
+	queryRep = NewLuceneParser().Parse(lexerNode(`dementia title:[a TO c]`))
+	if len(queryRep.Keywords) != 1 || queryRep.Keywords[0].QueryString != "dementia" {
+		t.Fatalf("expected the range to be ignored, got %v", queryRep)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"github.com/hscells/transmute/fields"
 	"github.com/hscells/transmute/ir"
+	"log"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -30,8 +32,9 @@ var MedlineFieldMapping = map[string][]string{
 	"fx":       {fields.FloatingMeshHeadings},
 	"kf":       {fields.AllFields},
 	"ot":       {fields.Title},
-	"mp":       {fields.AllFields},
+	"mp":       fields.MedlineMultiPurpose,
 	"mh":       {fields.MeshHeadings},
+	"mj":       {fields.MajorFocusMeshHeading},
 	"nm":       {fields.AllFields},
 	"px":       {fields.MeshHeadings},
 	"pt":       {fields.PublicationType},
@@ -41,6 +44,9 @@ var MedlineFieldMapping = map[string][]string{
 	"sh":       {fields.MeSHSubheading},
 	"tw":       {fields.TextWord},
 	"ti":       {fields.Title},
+	"yr":       {fields.PublicationDate},
+	"la":       {fields.Language},
+	"lg":       {fields.Language},
 	"ja":       {fields.Journal},
 	"jn":       {fields.Journal},
 	"jw":       {fields.Journal},
@@ -52,6 +58,31 @@ var MedlineFieldMapping = map[string][]string{
 
 var adjMatchRegexp, _ = regexp.Compile("^adj[0-9]*$")
 var medlineFieldRegexp, _ = regexp.Compile(".[a-z]{2}.")
+var medlineHeadingRegexp, _ = regexp.Compile(`^(exp\s+)?(\*)?([^/]+)/\s*([a-zA-Z]{2}(?:\s*,\s*[a-zA-Z]{2})*)?$`)
+var medlineFrequencyRegexp, _ = regexp.Compile(`(?i)^(.*?)\s*/freq\s*=\s*([0-9]+)$`)
+var medlineLimitYearRegexp, _ = regexp.Compile(`(?i)^yr\s*=\s*"?([^"]+)"?$`)
+
+// medlineLimitSpecies maps the species which a query may be limited to onto their subject headings.
+var medlineLimitSpecies = map[string]string{
+	"humans":  "Humans",
+	"human":   "Humans",
+	"animals": "Animals",
+	"animal":  "Animals",
+}
+
+// medlineLimitLanguages are the languages which may be used in a limit without the word `language`.
+var medlineLimitLanguages = map[string]bool{
+	"english":    true,
+	"french":     true,
+	"german":     true,
+	"spanish":    true,
+	"italian":    true,
+	"portuguese": true,
+	"dutch":      true,
+	"russian":    true,
+	"chinese":    true,
+	"japanese":   true,
+}
 
 // MedlineTransformer is an implementation of a QueryTransformer in the parser package.
 type MedlineTransformer struct{}
@@ -124,17 +155,32 @@ func (p MedlineTransformer) TransformSingle(query string, mapping map[string][]s
 	// Trim the query string to prevent whitespace such as newlines interfering with string processing.
 	query = strings.TrimSpace(query)
 
-	if len(query) > 0 && query[len(query)-1] == '/' {
-		// Check to see if we are looking at a mesh heading string.
-		expParts := strings.Split(query, " ")
-		if expParts[0] == "exp" {
-			queryString = strings.Join(expParts[1:], " ")
-			exploded = true
-		} else {
-			queryString = query
-		}
-		queryString = strings.Replace(queryString, "/", "", -1)
+	var options map[string]interface{}
+	// A minimum frequency (e.g. `dementia.ti,ab./freq=3`) follows the fields.
+	query, frequency := splitMedlineFrequency(query)
+	if frequency > 0 {
+		options = map[string]interface{}{ir.FrequencyOption: frequency}
+	}
+
+	if heading := medlineHeadingRegexp.FindStringSubmatch(query); heading != nil {
+		// Check to see if we are looking at a subject heading string, e.g. `exp *Dementia/dt, th`.
+		queryString = heading[3]
+		exploded = len(heading[1]) > 0
 		queryFields = mapping["mh"]
+		if len(heading[2]) > 0 {
+			// Starred headings are the major focus of an article.
+			queryFields = p.TransformFields("mj", mapping)
+		}
+		if len(heading[4]) > 0 {
+			var subheadings []string
+			for _, subheading := range strings.Split(heading[4], ",") {
+				subheadings = append(subheadings, strings.ToLower(strings.TrimSpace(subheading)))
+			}
+			if options == nil {
+				options = map[string]interface{}{}
+			}
+			options[ir.SubheadingsOption] = subheadings
+		}
 	} else {
 		// Otherwise try to parse a regular looking query.
 		parts := strings.Split(query, ".")
@@ -161,7 +207,23 @@ func (p MedlineTransformer) TransformSingle(query string, mapping map[string][]s
 		Fields:      queryFields,
 		Exploded:    exploded,
 		Truncated:   truncated,
+		Options:     options,
+	}
+}
+
+// splitMedlineFrequency removes the minimum frequency from the end of a query (e.g. `/freq=3`), returning the query
+// without it and the frequency, or zero if the query has no frequency.
+func splitMedlineFrequency(query string) (string, int) {
+	match := medlineFrequencyRegexp.FindStringSubmatch(query)
+	if match == nil {
+		return query, 0
 	}
+	frequency, err := strconv.Atoi(match[2])
+	if err != nil {
+		log.Println(err)
+		return query, 0
+	}
+	return match[1], frequency
 }
 
 // transformPrefixGroupToQueryGroup transforms a prefix syntax tree into a query group. The new QueryGroup is built by
@@ -423,6 +485,113 @@ func (p MedlineTransformer) IsOperator(s string) bool {
 		adjMatchRegexp.MatchString(s)
 }
 
+// TransformLimit implements the transformation of the restrictions of a limit line, e.g. `yr="2000-2010"` or
+// `(english language and humans)`. Alternatives of a restriction, e.g. `(english or french)`, must limit the same field.
+func (p MedlineTransformer) TransformLimit(limit string, mapping map[string][]string) []ir.Limit {
+	var limits []ir.Limit
+	for _, restriction := range splitMedlineLimit(limit, "and") {
+		var current *ir.Limit
+		for _, alternative := range splitMedlineLimit(restriction, "or") {
+			l, ok := p.limit(alternative, mapping)
+			if !ok {
+				log.Printf("unsupported limit `%v`, ignoring\n", alternative)
+				continue
+			}
+			if current == nil {
+				current = &l
+				continue
+			}
+			if l.Field != current.Field || l.Range != nil || current.Range != nil {
+				log.Printf("limits of different fields cannot be combined with or, ignoring `%v`\n", alternative)
+				continue
+			}
+			current.Values = append(current.Values, l.Values...)
+		}
+		if current != nil {
+			limits = append(limits, *current)
+		}
+	}
+	return limits
+}
+
+// limit transforms a single restriction of a limit line into a limit on the publication year, language, species, or
+// publication type.
+func (p MedlineTransformer) limit(restriction string, mapping map[string][]string) (ir.Limit, bool) {
+	restriction = strings.TrimSpace(restriction)
+	lower := strings.ToLower(strings.Trim(restriction, `"`))
+
+	if year := medlineLimitYearRegexp.FindStringSubmatch(restriction); year != nil {
+		r, err := parseDateRange(strings.Replace(year[1], " ", "", -1), "-")
+		if err != nil {
+			log.Println(err)
+			return ir.Limit{}, false
+		}
+		return ir.Limit{Field: p.TransformFields("yr", mapping)[0], Range: r}, true
+	}
+
+	// Species are limited using the check tags of the subject headings.
+	if species, ok := medlineLimitSpecies[lower]; ok {
+		return ir.Limit{Field: p.TransformFields("mh", mapping)[0], Values: []string{species}}, true
+	}
+
+	if strings.HasSuffix(lower, " language") || medlineLimitLanguages[lower] {
+		return ir.Limit{Field: p.TransformFields("la", mapping)[0], Values: []string{strings.TrimSuffix(lower, " language")}}, true
+	}
+
+	// Anything else is assumed to be a publication type, e.g. `randomized controlled trial`.
+	if len(lower) == 0 || strings.ContainsAny(lower, "=()") {
+		return ir.Limit{}, false
+	}
+	return ir.Limit{Field: p.TransformFields("pt", mapping)[0], Values: []string{lower}}, true
+}
+
+// splitMedlineLimit splits the restrictions of a limit line on an operator, ignoring any operators inside parenthesis
+// or quotes. The outer parenthesis of the restrictions are removed.
+func splitMedlineLimit(limit string, operator string) []string {
+	limit = strings.TrimSpace(limit)
+	for strings.HasPrefix(limit, "(") && strings.HasSuffix(limit, ")") && matchingParenthesis(limit) == len(limit)-1 {
+		limit = strings.TrimSpace(limit[1 : len(limit)-1])
+	}
+
+	var parts []string
+	depth, start := 0, 0
+	quoted := false
+	for i := 0; i < len(limit); i++ {
+		switch limit[i] {
+		case '"':
+			quoted = !quoted
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ' ':
+			end := i + len(operator) + 2
+			if depth == 0 && !quoted && end <= len(limit) && strings.EqualFold(limit[i:end], " "+operator+" ") {
+				parts = append(parts, strings.TrimSpace(limit[start:i]))
+				start = end
+				i = end - 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(limit[start:]))
+}
+
+// matchingParenthesis finds the index of the parenthesis which closes the parenthesis that starts the string.
+func matchingParenthesis(s string) int {
+	depth := 0
+	for i, char := range s {
+		if char == '(' {
+			depth++
+		} else if char == ')' {
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
 func NewMedlineParser() QueryParser {
 	return QueryParser{FieldMapping: MedlineFieldMapping, Parser: MedlineTransformer{}}
 }
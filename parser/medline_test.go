@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
 	"github.com/hscells/transmute/lexer"
+	"reflect"
 	"testing"
 )
 
@@ -43,7 +46,8 @@ func TestBooleanQuery_Fields(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	expected := 9
+	// The subject heading, and each of the eight keywords searched in the multi-purpose fields.
+	expected := 1 + 8*len(fields.MedlineMultiPurpose)
 	got := len(queryRep.Fields())
 	if expected != got {
 		t.Fatalf("Expected %v fields, got %v", expected, got)
@@ -66,3 +70,121 @@ func TestBooleanQuery_FieldCount(t *testing.T) {
 		t.Fatalf("Expected %v fields, got %v", expected, got)
 	}
 }
+
+func TestMedline_Limit(t *testing.T) {
+	ast, err := lexer.Lex(`1. exp Dementia/
+2. memory.ti,ab.
+3. 1 or 2
+4. limit 3 to yr="2000-Current"`, lexOptionsMedline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queryRep := NewMedlineParser().Parse(ast)
+
+	if queryRep.Operator != "or" || len(queryRep.Keywords) != 2 {
+		t.Fatalf("expected the limited query, got %v", queryRep)
+	}
+	if len(queryRep.Limits) != 1 || queryRep.Limits[0].Range == nil {
+		t.Fatalf("expected a date range limit, got %v", queryRep.Limits)
+	}
+
+	r := queryRep.Limits[0].Range
+	if r.Start.Year() != 2000 || !r.End.IsZero() {
+		t.Fatalf("expected an open range starting in 2000, got %v", r)
+	}
+}
+
+func TestMedline_LimitFilters(t *testing.T) {
+	ast, err := lexer.Lex(`1. exp Dementia/
+2. limit 1 to (english language and humans and (randomized controlled trial or meta analysis))`, lexOptionsMedline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queryRep := NewMedlineParser().Parse(ast)
+
+	if len(queryRep.Terms()) != 1 {
+		t.Fatalf("expected the limit line not to be parsed as a keyword, got %v", queryRep.Terms())
+	}
+
+	expected := map[string][]string{
+		fields.Language:        {"english"},
+		fields.MeshHeadings:    {"Humans"},
+		fields.PublicationType: {"randomized controlled trial", "meta analysis"},
+	}
+	if len(queryRep.Limits) != len(expected) {
+		t.Fatalf("expected %v limits, got %v", len(expected), queryRep.Limits)
+	}
+	for _, limit := range queryRep.Limits {
+		if !reflect.DeepEqual(expected[limit.Field], limit.Values) {
+			t.Fatalf("expected %v to be limited to %v, got %v", limit.Field, expected[limit.Field], limit.Values)
+		}
+	}
+}
+
+func TestMedline_Subheadings(t *testing.T) {
+	ast, err := lexer.Lex(`1. *Dementia/dt, th
+2. exp Neoplasms/pc
+3. 1 or 2`, lexOptionsMedline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queryRep := NewMedlineParser().Parse(ast)
+
+	if len(queryRep.Keywords) != 2 {
+		t.Fatalf("expected two headings, got %v", queryRep)
+	}
+
+	expected := map[string]ir.Keyword{
+		"Dementia":  {Fields: []string{fields.MajorFocusMeshHeading}, Options: map[string]interface{}{ir.SubheadingsOption: []string{"dt", "th"}}},
+		"Neoplasms": {Fields: []string{fields.MeshHeadings}, Exploded: true, Options: map[string]interface{}{ir.SubheadingsOption: []string{"pc"}}},
+	}
+	for _, keyword := range queryRep.Keywords {
+		e := expected[keyword.QueryString]
+		if !reflect.DeepEqual(e.Fields, keyword.Fields) || e.Exploded != keyword.Exploded || !reflect.DeepEqual(e.Options, keyword.Options) {
+			t.Fatalf("expected %v to be parsed as %v, got %v", keyword.QueryString, e, keyword)
+		}
+	}
+}
+
+func TestMedline_Frequency(t *testing.T) {
+	ast, err := lexer.Lex(`1. dementia.ti,ab./freq=3
+2. alzheimer*.mp.
+3. 1 or 2`, lexOptionsMedline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queryRep := NewMedlineParser().Parse(ast)
+
+	for _, keyword := range queryRep.Keywords {
+		switch keyword.QueryString {
+		case "dementia":
+			if !reflect.DeepEqual(keyword.Fields, []string{fields.TitleAbstract}) || keyword.Options[ir.FrequencyOption] != 3 {
+				t.Fatalf("expected dementia in the title and abstract at least 3 times, got %v", keyword)
+			}
+		case "alzheimer*":
+			if !reflect.DeepEqual(keyword.Fields, fields.MedlineMultiPurpose) {
+				t.Fatalf("expected alzheimer* in the multi-purpose fields, got %v", keyword.Fields)
+			}
+		default:
+			t.Fatalf("unexpected keyword %v", keyword)
+		}
+	}
+}
+
+func TestMedline_NotOperands(t *testing.T) {
+	ast, err := lexer.Lex(`1. dementia.ti.
+2. (mice or rats).ti.
+3. 2 not 1`, lexOptionsMedline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queryRep := NewMedlineParser().Parse(ast)
+
+	// Line 1 is excluded from line 2, so it must follow line 2 as a child.
+	if queryRep.Operator != "not" || len(queryRep.Keywords) != 0 || len(queryRep.Children) != 2 {
+		t.Fatalf("expected two children, got %v", queryRep)
+	}
+	if len(queryRep.Children[1].Keywords) != 1 || queryRep.Children[1].Keywords[0].QueryString != "dementia" {
+		t.Fatalf("expected dementia to be the second operand, got %v", queryRep.Children[1])
+	}
+}
@@ -3,8 +3,11 @@
 package parser
 
 import (
+	"github.com/hscells/cqr"
 	"github.com/hscells/transmute/ir"
 	"github.com/hscells/transmute/lexer"
+	"log"
+	"strings"
 )
 
 // QueryTransformer must be implemented to parse queries.
@@ -16,6 +19,13 @@ type QueryTransformer interface {
 	TransformNested(query string, mapping map[string][]string) ir.BooleanQuery
 }
 
+// LimitTransformer may be implemented by a QueryTransformer to parse the restrictions of limit lines, such as the
+// `yr="2000-2010"` of `limit 5 to yr="2000-2010"`.
+type LimitTransformer interface {
+	// TransformLimit transforms the restrictions of a limit line.
+	TransformLimit(limit string, mapping map[string][]string) []ir.Limit
+}
+
 // QueryParser represents the full implementation of a query parser.
 type QueryParser struct {
 	// FieldMapping determines how fields are mapped for a query.
@@ -33,6 +43,9 @@ func (q QueryParser) Parse(ast lexer.Node) ir.BooleanQuery {
 	}
 	var visit func(node lexer.Node, query ir.BooleanQuery) ir.BooleanQuery
 	visit = func(node lexer.Node, query ir.BooleanQuery) ir.BooleanQuery {
+		if node.Operator == lexer.LimitOperator {
+			return q.limit(node, visit)
+		}
 		query.Operator = node.Operator
 		//fmt.Println("::::", node, len(node.Children))
 		for _, child := range node.Children {
@@ -40,6 +53,13 @@ func (q QueryParser) Parse(ast lexer.Node) ir.BooleanQuery {
 				// Nested query.
 				if len(child.Value) > 0 && child.Value[0] == '(' {
 					query.Children = append(query.Children, q.Parser.TransformNested(child.Value, q.FieldMapping))
+				} else if strings.EqualFold(query.Operator, cqr.NOT) && len(query.Children) > 0 {
+					// A line excluded from a query must follow it, so it cannot be one of the keywords (see
+					// ir.BooleanQuery).
+					query.Children = append(query.Children, ir.BooleanQuery{
+						Operator: cqr.OR,
+						Keywords: []ir.Keyword{q.Parser.TransformSingle(child.Value, q.FieldMapping)},
+					})
 				} else {
 					// Regular line of a query.
 					query.Keywords = append(query.Keywords, q.Parser.TransformSingle(child.Value, q.FieldMapping))
@@ -53,3 +73,24 @@ func (q QueryParser) Parse(ast lexer.Node) ir.BooleanQuery {
 
 	return visit(ast, ir.BooleanQuery{})
 }
+
+// limit parses the query a limit node refers to, and restricts it using the limits of the node.
+func (q QueryParser) limit(node lexer.Node, visit func(node lexer.Node, query ir.BooleanQuery) ir.BooleanQuery) ir.BooleanQuery {
+	var query ir.BooleanQuery
+	for _, child := range node.Children {
+		if len(child.Operator) > 0 {
+			query = visit(child, ir.BooleanQuery{})
+		} else if len(child.Value) > 0 && child.Value[0] == '(' {
+			query = q.Parser.TransformNested(child.Value, q.FieldMapping)
+		} else {
+			query = ir.BooleanQuery{Operator: cqr.OR, Keywords: []ir.Keyword{q.Parser.TransformSingle(child.Value, q.FieldMapping)}}
+		}
+	}
+
+	if l, ok := q.Parser.(LimitTransformer); ok {
+		query.Limits = append(query.Limits, l.TransformLimit(node.Value, q.FieldMapping)...)
+	} else {
+		log.Printf("limits are not supported by this parser, ignoring `%v`\n", node.Value)
+	}
+	return query
+}
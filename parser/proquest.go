@@ -0,0 +1,243 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProQuestFieldMapping maps ProQuest (and Dialog) field codes. The subject heading codes (e.g. `MESH`) are used both
+// for word searches of the headings (`MESH(dementia)`) and for searches of exact headings (`MESH.EXACT("Dementia")`).
+var ProQuestFieldMapping = map[string][]string{
+	"TI":          {fields.Title},
+	"AB":          {fields.Abstract},
+	"NOFT":        {fields.AllFields},
+	"ALL":         {fields.AllFields},
+	"IF":          {fields.Keywords},
+	"SU":          {fields.SubjectHeadings},
+	"MAINSUBJECT": {fields.SubjectHeadings},
+	"MESH":        {fields.MeshHeadings},
+	"MJMESH":      {fields.MajorFocusMeshHeading},
+	"EMB":         {fields.EmtreeHeadings},
+	"MJEMB":       {fields.MajorFocusEmtreeHeading},
+	"AU":          {fields.Authors},
+	"AF":          {fields.Affiliation},
+	"PUB":         {fields.Journal},
+	"LA":          {fields.Language},
+	"DTYPE":       {fields.PublicationType},
+	"RTYPE":       {fields.PublicationType},
+	"PD":          {fields.PublicationDate},
+	"YR":          {fields.PublicationDate},
+	"default":     {fields.AllFields},
+}
+
+var (
+	proquestFieldRegexp, _      = regexp.Compile(`\b([a-zA-Z]+(?:,[a-zA-Z]+)*)\s*\(`)
+	proquestHeadingsRegexp, _   = regexp.Compile(`(?i)\b([a-z]+)\.EXACT(\.EXPLODE)?\s*\(([^()]*)\)`)
+	proquestHeadingRegexp, _    = regexp.Compile(`(?i)^([a-z]+)\.EXACT(\.EXPLODE)?\("([^"]*)"\)`)
+	proquestOperatorRegexp, _   = regexp.Compile(`(?i)\s+(AND|OR|NOT)\s+`)
+	proquestProximityRegexp, _  = regexp.Compile(`(?i)^(NEAR|N|PRE|P)(/([0-9]+))?$`)
+	proquestTruncationRegexp, _ = regexp.Compile(`\[\*[0-9]+\]`)
+	proquestDateRegexp, _       = regexp.Compile(`^([<>])?([0-9]{4,8})(?:-([0-9]{4,8}))?$`)
+)
+
+// ProQuestTransformer is an implementation of a QueryTransformer for ProQuest (and Dialog) command line searches,
+// e.g. `MESH.EXACT.EXPLODE("Dementia") OR ti(memory NEAR/3 loss)`.
+type ProQuestTransformer struct{}
+
+// keyword transforms a term into a keyword. Subject headings (e.g. `MESH.EXACT.EXPLODE("Dementia")`) are parsed
+// entirely here, since they carry their own field.
+func (p ProQuestTransformer) keyword(query string, mapping map[string][]string) ir.Keyword {
+	query = strings.TrimSpace(query)
+
+	if heading := proquestHeadingRegexp.FindStringSubmatch(query); heading != nil {
+		f, ok := mapping[strings.ToUpper(heading[1])]
+		if !ok {
+			log.Printf("the field `%v` does not have a mapping defined\n", heading[1])
+			f = mapping["default"]
+		}
+		return ir.Keyword{
+			QueryString: heading[3],
+			Fields:      f,
+			Exploded:    len(heading[2]) > 0,
+		}
+	}
+
+	// Truncation may be limited to a number of characters (e.g. `nurs[*2]`), which is not supported by other
+	// databases, so it is replaced with unlimited truncation.
+	query = proquestTruncationRegexp.ReplaceAllString(query, "*")
+
+	return ir.Keyword{
+		QueryString: query,
+		Truncated:   isTruncated(query),
+	}
+}
+
+// TransformSingle implements the transformation of a single term, e.g. `ti(dementia)`.
+func (p ProQuestTransformer) TransformSingle(query string, mapping map[string][]string) ir.Keyword {
+	q := p.TransformNested(query, mapping)
+	if len(q.Keywords) == 0 {
+		return ir.Keyword{}
+	}
+	return q.Keywords[0]
+}
+
+// TransformNested implements the transformation of a ProQuest query.
+func (p ProQuestTransformer) TransformNested(query string, mapping map[string][]string) ir.BooleanQuery {
+	// Subject heading searches may contain several headings (e.g. `MESH.EXACT("Dementia" OR "Delirium")`), which are
+	// expanded so that each heading can be parsed as a single term.
+	query = proquestHeadingsRegexp.ReplaceAllStringFunc(query, expandProQuestHeadings)
+
+	// Normalise field codes so they are always written as `TI,AB=(`, which distinguishes them from search terms.
+	query = proquestFieldRegexp.ReplaceAllStringFunc(query, func(s string) string {
+		codes := proquestFieldRegexp.FindStringSubmatch(s)[1]
+		for _, code := range strings.Split(codes, ",") {
+			if _, ok := mapping[strings.ToUpper(code)]; !ok {
+				return s
+			}
+		}
+		return fmt.Sprintf("%s= (", strings.ToUpper(codes))
+	})
+
+	q := parseExpression(query, p.dialect(mapping), mapping)
+
+	// Dates are searched as ranges, e.g. `PD(20000101-20101231)` or `YR(>2005)`.
+	return mapKeywords(q, func(keyword ir.Keyword) ir.Keyword {
+		if len(keyword.Fields) == 1 && keyword.Fields[0] == fields.PublicationDate {
+			if r, ok := proquestDateRange(keyword.QueryString); ok {
+				keyword.Range = r
+			}
+		}
+		return keyword
+	})
+}
+
+// expandProQuestHeadings expands a subject heading search containing several headings into a search for each
+// heading, e.g. `MESH.EXACT("Dementia" OR "Delirium")` into `(MESH.EXACT("Dementia") OR MESH.EXACT("Delirium"))`.
+func expandProQuestHeadings(s string) string {
+	match := proquestHeadingsRegexp.FindStringSubmatch(s)
+	function := fmt.Sprintf("%s.EXACT%s", strings.ToUpper(match[1]), strings.ToUpper(match[2]))
+
+	heading := func(term string) string {
+		return fmt.Sprintf(`%s("%s")`, function, strings.Trim(strings.TrimSpace(term), `"`))
+	}
+
+	var headings []string
+	last := 0
+	for _, loc := range proquestOperatorRegexp.FindAllStringSubmatchIndex(match[3], -1) {
+		headings = append(headings, heading(match[3][last:loc[0]]), strings.ToUpper(match[3][loc[2]:loc[3]]))
+		last = loc[1]
+	}
+	headings = append(headings, heading(match[3][last:]))
+
+	if len(headings) == 1 {
+		return headings[0]
+	}
+	return fmt.Sprintf("(%s)", strings.Join(headings, " "))
+}
+
+// proquestDateRange parses a ProQuest date, which is either a year or a date written as `YYYYMMDD`, a range of two
+// dates (e.g. `2000-2010`), or a date which is open on one side (e.g. `>2005`).
+func proquestDateRange(query string) (*ir.DateRange, bool) {
+	match := proquestDateRegexp.FindStringSubmatch(query)
+	if match == nil {
+		return nil, false
+	}
+
+	date := func(s string) string {
+		if len(s) == 8 {
+			return fmt.Sprintf("%s/%s/%s", s[:4], s[4:6], s[6:])
+		} else if len(s) == 6 {
+			return fmt.Sprintf("%s/%s", s[:4], s[4:])
+		}
+		return s
+	}
+
+	var r *ir.DateRange
+	var err error
+	switch match[1] {
+	case ">":
+		// Comparisons exclude the date itself.
+		var end time.Time
+		end, err = parseDate(date(match[2]), true)
+		r = &ir.DateRange{Start: end.AddDate(0, 0, 1)}
+	case "<":
+		var start time.Time
+		start, err = parseDate(date(match[2]), false)
+		r = &ir.DateRange{End: start.AddDate(0, 0, -1)}
+	default:
+		dates := date(match[2])
+		if len(match[3]) > 0 {
+			dates += ":" + date(match[3])
+		}
+		r, err = parseDateRange(dates, ":")
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, false
+	}
+	return r, true
+}
+
+// dialect describes the ProQuest search syntax. Operators are evaluated in the order PRE/n, NEAR/n, AND, OR, NOT.
+func (p ProQuestTransformer) dialect(mapping map[string][]string) expressionDialect {
+	return expressionDialect{
+		atoms: []*regexp.Regexp{proquestHeadingRegexp},
+		operator: func(token string) (expressionOperator, bool) {
+			switch strings.ToUpper(token) {
+			case "NOT":
+				return expressionOperator{operator: cqr.NOT, precedence: 1}, true
+			case "OR":
+				return expressionOperator{operator: cqr.OR, precedence: 2}, true
+			case "AND":
+				return expressionOperator{operator: cqr.AND, precedence: 3}, true
+			}
+			if proximity := proquestProximityRegexp.FindStringSubmatch(token); proximity != nil {
+				op := strings.ToUpper(proximity[1])
+				// N and P are only operators when they are followed by a distance.
+				if len(proximity[3]) == 0 && len(op) == 1 {
+					return expressionOperator{}, false
+				}
+				// ProQuest uses a distance of 4 when none is specified.
+				distance := 4
+				if len(proximity[3]) > 0 {
+					distance, _ = strconv.Atoi(proximity[3])
+				}
+				// NEAR/n finds terms within n words in any order, and PRE/n finds the first term before the second.
+				if op[0] == 'P' {
+					return expressionOperator{operator: fmt.Sprintf("adj%d", distance), precedence: 5, options: map[string]interface{}{ir.OrderedOption: true}}, true
+				}
+				return expressionOperator{operator: fmt.Sprintf("adj%d", distance), precedence: 4}, true
+			}
+			return expressionOperator{}, false
+		},
+		prefix: func(token string) ([]string, bool) {
+			if !strings.HasSuffix(token, "=") {
+				return nil, false
+			}
+			var f []string
+			for _, code := range strings.Split(strings.TrimSuffix(token, "="), ",") {
+				codeFields, ok := mapping[code]
+				if !ok {
+					return nil, false
+				}
+				f = append(f, codeFields...)
+			}
+			return f, true
+		},
+		keyword: func(term string) ir.Keyword {
+			return p.keyword(term, mapping)
+		},
+	}
+}
+
+// NewProQuestParser creates a new parser for ProQuest queries.
+func NewProQuestParser() QueryParser {
+	return QueryParser{FieldMapping: ProQuestFieldMapping, Parser: ProQuestTransformer{}}
+}
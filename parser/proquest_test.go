@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"testing"
+)
+
+var (
+	proquestQueryString = `(MESH.EXACT.EXPLODE("Dementia") OR MAINSUBJECT.EXACT("Alzheimer's Disease" OR "Delirium") OR ti(memory NEAR/3 loss)) AND ab(mild PRE/2 impair*) NOT NOFT(review)`
+)
+
+func TestProQuest_BooleanQuery_Terms(t *testing.T) {
+	queryRep := NewProQuestParser().Parse(lexerNode(proquestQueryString))
+
+	expected := 8
+	got := len(queryRep.Terms())
+	if expected != got {
+		t.Fatalf("Expected %v terms, got %v", expected, got)
+	}
+
+	if queryRep.Operator != "not" {
+		t.Fatalf("expected NOT to bind loosest, got %v", queryRep.Operator)
+	}
+}
+
+func TestProQuest_Headings(t *testing.T) {
+	queryRep := NewProQuestParser().Parse(lexerNode(`MESH.EXACT.EXPLODE("Dementia") OR MAINSUBJECT.EXACT("Alzheimer's Disease" OR "Delirium")`))
+
+	if len(queryRep.Keywords) != 3 {
+		t.Fatalf("expected three headings, got %v", queryRep)
+	}
+	if k := queryRep.Keywords[0]; k.QueryString != "Dementia" || !k.Exploded || k.Fields[0] != fields.MeshHeadings {
+		t.Fatalf("expected an exploded MeSH heading, got %v", k)
+	}
+	if k := queryRep.Keywords[2]; k.QueryString != "Delirium" || k.Exploded || k.Fields[0] != fields.SubjectHeadings {
+		t.Fatalf("expected a subject heading which is not exploded, got %v", k)
+	}
+}
+
+func TestProQuest_Proximity(t *testing.T) {
+	queryRep := NewProQuestParser().Parse(lexerNode(`ti(memory NEAR/3 loss) AND ti,ab(mild PRE/2 impair*)`))
+
+	if len(queryRep.Children) != 2 {
+		t.Fatalf("expected two proximity queries, got %v", queryRep)
+	}
+	if queryRep.Children[0].Operator != "adj3" || queryRep.Children[0].Options[ir.OrderedOption] != nil {
+		t.Fatalf("expected an unordered proximity query, got %v", queryRep.Children[0])
+	}
+	if queryRep.Children[1].Operator != "adj2" || queryRep.Children[1].Options[ir.OrderedOption] != true {
+		t.Fatalf("expected an ordered proximity query, got %v", queryRep.Children[1])
+	}
+	if len(queryRep.Children[1].Keywords[0].Fields) != 2 {
+		t.Fatalf("expected the title and abstract fields, got %v", queryRep.Children[1].Keywords[0].Fields)
+	}
+}
@@ -1,15 +1,35 @@
 package parser
 
 import (
+	"fmt"
 	"github.com/hscells/transmute/fields"
 	"github.com/hscells/transmute/ir"
 	"log"
+	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
 )
 
 type PubMedTransformer struct{}
 
+// pubmedSubheadingRegexp matches a subject heading restricted to subheadings, such as `Dementia/dt`.
+var pubmedSubheadingRegexp, _ = regexp.Compile(`^(.+)/([a-zA-Z]{2}(?:,[a-zA-Z]{2})*)$`)
+
+// pubmedProximityRegexp matches a proximity search such as `"hip pain"[tiab:~3]`.
+var pubmedProximityRegexp, _ = regexp.Compile(`^"([^"]+)"\s*\[([^\]:]+):~([0-9]+)\]$`)
+
+// pubmedDateFields are the fields which are searched using dates.
+var pubmedDateFields = map[string]bool{
+	fields.PublicationDate:  true,
+	fields.DatePublication:  true,
+	fields.DateCompletion:   true,
+	fields.DateCreate:       true,
+	fields.DateEntrez:       true,
+	fields.DateMeSH:         true,
+	fields.DateModification: true,
+}
+
 var PubMedFieldMapping = map[string][]string{
 	"Mesh":                              {fields.MeshHeadings},
 	"mesh":                              {fields.MeshHeadings},
@@ -71,12 +91,21 @@ var PubMedFieldMapping = map[string][]string{
 	"Transliterated Title":              {fields.TransliteratedTitle},
 	"Volume":                            {fields.Volume},
 	"mh":                                {fields.MeshHeadings},
+	"majr":                              {fields.MajorFocusMeshHeading},
 	"sh":                                {fields.FloatingMeshHeadings},
 	"tw":                                {fields.TextWord},
 	"ti":                                {fields.Title},
 	"pt":                                {fields.PublicationType},
 	"sb":                                {fields.PublicationStatus},
 	"tiab":                              {fields.TitleAbstract},
+	"la":                                {fields.Language},
+	"dp":                                {fields.PublicationDate},
+	"pdat":                              {fields.PublicationDate},
+	"crdt":                              {fields.DateCreate},
+	"dcom":                              {fields.DateCompletion},
+	"edat":                              {fields.DateEntrez},
+	"lr":                                {fields.DateModification},
+	"mhda":                              {fields.DateMeSH},
 	"text":                              {fields.TitleAbstract},
 	fields.Affiliation:                  {fields.Affiliation},
 	fields.AllFields:                    {fields.AllFields},
@@ -148,6 +177,12 @@ func (t PubMedTransformer) TransformSingle(query string, mapping map[string][]st
 			exploded = true
 		}
 
+		// Proximity searches are transformed by TransformProximity; a lone keyword keeps only its phrase.
+		if i := strings.Index(possibleField, ":~"); i > 0 {
+			log.Printf("searching for %v as a phrase, since proximity is only supported in nested queries\n", query)
+			possibleField = possibleField[:i]
+		}
+
 		// PubMed fields have this weird thing where they specify the mesh explosion in the field.
 		// This is handled in this step.
 		if strings.Contains(strings.ToLower(possibleField), ":noexp") {
@@ -184,15 +219,68 @@ func (t PubMedTransformer) TransformSingle(query string, mapping map[string][]st
 
 	queryString = strings.TrimSpace(queryString)
 
+	// Subject headings may be restricted to subheadings, e.g. `Dementia/dt[Mesh]`.
+	var options map[string]interface{}
+	if len(queryFields) == 1 && (queryFields[0] == fields.MeshHeadings || queryFields[0] == fields.MajorFocusMeshHeading) {
+		if heading := pubmedSubheadingRegexp.FindStringSubmatch(queryString); heading != nil {
+			queryString = heading[1]
+			options = map[string]interface{}{ir.SubheadingsOption: strings.Split(strings.ToLower(heading[2]), ",")}
+		}
+	}
+
+	// Dates are searched as ranges, e.g. `2000/01/01:2010/12/31[dp]`.
+	var dateRange *ir.DateRange
+	if len(queryFields) > 0 && pubmedDateFields[queryFields[0]] {
+		if r, err := parseDateRange(strings.Replace(queryString, `"`, "", -1), ":"); err == nil {
+			dateRange = r
+			exploded = false
+		}
+	}
+
 	return ir.Keyword{
 		QueryString: queryString,
 		Fields:      queryFields,
 		Exploded:    exploded,
 		Truncated:   truncated,
+		Range:       dateRange,
+		Options:     options,
 	}
 }
 
+// TransformProximity transforms a proximity search such as `"hip pain"[tiab:~3]` into an adjacency query over each
+// of the words in the phrase. Queries which are not proximity searches are not transformed.
+func (t PubMedTransformer) TransformProximity(query string, mapping map[string][]string) (ir.BooleanQuery, bool) {
+	proximity := pubmedProximityRegexp.FindStringSubmatch(strings.TrimSpace(query))
+	if proximity == nil {
+		return ir.BooleanQuery{}, false
+	}
+
+	queryFields, ok := mapping[proximity[2]]
+	if !ok {
+		log.Printf("the field `%v` does not have a mapping defined\n", proximity[2])
+		queryFields = mapping["default"]
+	}
+
+	distance, err := strconv.Atoi(proximity[3])
+	if err != nil {
+		return ir.BooleanQuery{}, false
+	}
+
+	q := ir.BooleanQuery{Operator: fmt.Sprintf("adj%d", distance)}
+	for _, term := range strings.Fields(proximity[1]) {
+		q.Keywords = append(q.Keywords, ir.Keyword{
+			QueryString: term,
+			Fields:      queryFields,
+			Exploded:    true,
+		})
+	}
+	return q, true
+}
+
 func (t PubMedTransformer) TransformNested(query string, mapping map[string][]string) ir.BooleanQuery {
+	if proximity, ok := t.TransformProximity(query, mapping); ok {
+		return proximity
+	}
 	query = ReversePreservingCombiningCharacters(reverse(query))
 	return t.ParseInfixKeywords(query, mapping)
 }
@@ -293,7 +381,8 @@ func (t PubMedTransformer) ParseInfixKeywords(line string, mapping map[string][]
 			continue
 		} else if char == ')' {
 			depth--
-			if len(keyword) > 0 || len(currentToken) > 0 {
+			// The previous token may be a keyword followed by a space, e.g. `( a[ti] OR b[ti] )`.
+			if len(keyword) > 0 || len(currentToken) > 0 || len(strings.TrimSpace(previousToken)) > 0 {
 				stack = append(stack, strings.TrimSpace(keyword+" "+previousToken+" "+currentToken))
 				keyword = ""
 				currentToken = ""
@@ -457,13 +546,16 @@ func (t PubMedTransformer) TransformPrefixGroupToQueryGroup(prefix []string, que
 			} else {
 				queryGroup.Keywords = subGroup.Keywords
 			}
+			queryGroup.Children = append(queryGroup.Children, subGroup.Children...)
 		} else {
 			queryGroup.Children = append(queryGroup.Children, subGroup)
 		}
 	} else if token == ")" {
 		return prefix, queryGroup
 	} else {
-		if len(token) > 0 {
+		if proximity, ok := t.TransformProximity(token, mapping); ok {
+			queryGroup.Children = append(queryGroup.Children, proximity)
+		} else if len(token) > 0 {
 			k := t.TransformSingle(token, mapping)
 			queryGroup.Keywords = append(queryGroup.Keywords, k)
 		}
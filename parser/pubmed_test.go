@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
 	"github.com/hscells/transmute/lexer"
 	"testing"
 )
@@ -37,7 +39,8 @@ func TestPubMed_BooleanQuery_Fields(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	expected := 13
+	// Each of the eleven keywords searches a single field (`tiab` searches the title_abstract field).
+	expected := 11
 	got := len(queryRep.Fields())
 	if expected != got {
 		t.Fatalf("Expected %v fields, got %v", expected, got)
@@ -60,3 +63,77 @@ func TestPubMed_BooleanQuery_FieldCount(t *testing.T) {
 		t.Fatalf("Expected %v fields, got %v", expected, got)
 	}
 }
+
+func TestPubMed_Proximity(t *testing.T) {
+	queryRep := NewPubMedParser().Parse(lexerNode(`(dementia[ti] AND "hip pain"[tiab:~3])`))
+
+	expected := 3
+	got := len(queryRep.Terms())
+	if expected != got {
+		t.Fatalf("Expected %v terms, got %v", expected, got)
+	}
+
+	var adj []ir.BooleanQuery
+	var visit func(q ir.BooleanQuery)
+	visit = func(q ir.BooleanQuery) {
+		if q.Operator == "adj3" {
+			adj = append(adj, q)
+		}
+		for _, child := range q.Children {
+			visit(child)
+		}
+	}
+	visit(queryRep)
+
+	if len(adj) != 1 || len(adj[0].Keywords) != 2 || adj[0].Keywords[0].Fields[0] != fields.TitleAbstract {
+		t.Fatalf("expected an adj3 query in the title and abstract, got %v", queryRep)
+	}
+}
+
+func TestPubMed_DateRange(t *testing.T) {
+	queryRep := NewPubMedParser().Parse(lexerNode(`(dementia[tiab] AND 2000/03:2010[dp])`))
+
+	var dates []ir.Keyword
+	var visit func(q ir.BooleanQuery)
+	visit = func(q ir.BooleanQuery) {
+		for _, keyword := range q.Keywords {
+			if keyword.Range != nil {
+				dates = append(dates, keyword)
+			}
+		}
+		for _, child := range q.Children {
+			visit(child)
+		}
+	}
+	visit(queryRep)
+
+	if len(dates) != 1 || dates[0].Fields[0] != fields.PublicationDate {
+		t.Fatalf("expected a publication date range, got %v", queryRep)
+	}
+	if dates[0].Range.String() != "2000/03/01:2010/12/31" {
+		t.Fatalf("expected the range to cover the full period of each date, got %v", dates[0].Range)
+	}
+}
+
+func TestPubMed_History(t *testing.T) {
+	ast, err := lexer.Lex(`#1 dementia[tiab]
+#2 "Alzheimer Disease"[Mesh]
+#3 #1 OR #2
+#4 (#3) AND english[la]`, lexOptionsPubMed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queryRep := NewPubMedParser().Parse(ast)
+
+	expected := 3
+	got := len(queryRep.Terms())
+	if expected != got {
+		t.Fatalf("Expected %v terms, got %v", expected, got)
+	}
+
+	expected = 1
+	got = queryRep.FieldCount()[fields.Language]
+	if expected != got {
+		t.Fatalf("Expected %v fields, got %v", expected, got)
+	}
+}
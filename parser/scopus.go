@@ -0,0 +1,142 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScopusFieldMapping maps Scopus field codes.
+var ScopusFieldMapping = map[string][]string{
+	"TITLE-ABS-KEY": {fields.TitleAbstract, fields.Keywords},
+	"TITLE-ABS":     {fields.TitleAbstract},
+	"TITLE":         {fields.Title},
+	"ABS":           {fields.Abstract},
+	"KEY":           {fields.Keywords},
+	"AUTHKEY":       {fields.Keywords},
+	"INDEXTERMS":    {fields.Keywords},
+	"AUTH":          {fields.Authors},
+	"AUTHOR-NAME":   {fields.Authors},
+	"FIRSTAUTH":     {fields.AuthorFirst},
+	"AFFIL":         {fields.Affiliation},
+	"SRCTITLE":      {fields.Journal},
+	"DOCTYPE":       {fields.PublicationType},
+	"LANGUAGE":      {fields.Language},
+	"ISBN":          {fields.ISBN},
+	"PMID":          {fields.PMID},
+	"PUBYEAR":       {fields.PublicationDate},
+	"ALL":           {fields.AllFields},
+	"default":       {fields.AllFields},
+}
+
+var (
+	scopusProximityRegexp, _ = regexp.Compile(`(?i)^(W|PRE)/([0-9]+)$`)
+	scopusAndNotRegexp, _    = regexp.Compile(`(?i)\bAND\s+NOT\b`)
+	scopusPubYearRegexp, _   = regexp.Compile(`(?i)^PUBYEAR\s*(>|<|=|AFT|BEF|IS)\s*([0-9]{4})`)
+)
+
+// ScopusTransformer is an implementation of a QueryTransformer for Scopus advanced search queries.
+type ScopusTransformer struct{}
+
+// keyword transforms a term into a keyword. Scopus distinguishes exact phrases, written in braces, from loose
+// phrases, written in quotes, which is recorded in the phrase option of the keyword.
+func (s ScopusTransformer) keyword(query string, mapping map[string][]string) ir.Keyword {
+	query = strings.TrimSpace(query)
+
+	if pubYear := scopusPubYearRegexp.FindStringSubmatch(query); pubYear != nil {
+		comparison := map[string]string{">": ">", "<": "<", "=": "", "AFT": ">", "BEF": "<", "IS": ""}[strings.ToUpper(pubYear[1])]
+		year, _ := strconv.Atoi(pubYear[2])
+		r := &ir.DateRange{}
+		switch comparison {
+		case ">":
+			r.Start = time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+		case "<":
+			r.End = time.Date(year-1, time.December, 31, 0, 0, 0, 0, time.UTC)
+		default:
+			r.Start = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+			r.End = time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+		}
+		return ir.Keyword{
+			QueryString: comparison + pubYear[2],
+			Fields:      mapping["PUBYEAR"],
+			Range:       r,
+		}
+	}
+
+	var options map[string]interface{}
+	if strings.HasPrefix(query, "{") && strings.HasSuffix(query, "}") {
+		query = fmt.Sprintf(`"%s"`, query[1:len(query)-1])
+		options = map[string]interface{}{ir.PhraseOption: ir.ExactPhrase}
+	} else if strings.HasPrefix(query, `"`) && strings.HasSuffix(query, `"`) {
+		options = map[string]interface{}{ir.PhraseOption: ir.LoosePhrase}
+	}
+
+	return ir.Keyword{
+		QueryString: query,
+		Truncated:   isTruncated(query),
+		Options:     options,
+	}
+}
+
+// TransformSingle implements the transformation of a single term, e.g. `TITLE({heart attack})`.
+func (s ScopusTransformer) TransformSingle(query string, mapping map[string][]string) ir.Keyword {
+	q := s.TransformNested(query, mapping)
+	if len(q.Keywords) == 0 {
+		return ir.Keyword{}
+	}
+	return q.Keywords[0]
+}
+
+// TransformNested implements the transformation of a Scopus query.
+func (s ScopusTransformer) TransformNested(query string, mapping map[string][]string) ir.BooleanQuery {
+	query = scopusAndNotRegexp.ReplaceAllString(query, "AND-NOT")
+	return parseExpression(query, s.dialect(mapping), mapping)
+}
+
+// dialect describes the Scopus search syntax. Scopus evaluates operators in the order OR, W/n, PRE/n, AND, AND NOT.
+func (s ScopusTransformer) dialect(mapping map[string][]string) expressionDialect {
+	return expressionDialect{
+		atoms:  []*regexp.Regexp{scopusPubYearRegexp},
+		quotes: map[rune]rune{'"': '"', '{': '}'},
+		operator: func(token string) (expressionOperator, bool) {
+			switch strings.ToUpper(token) {
+			case "AND-NOT":
+				return expressionOperator{operator: cqr.NOT, precedence: 1}, true
+			case "AND":
+				return expressionOperator{operator: cqr.AND, precedence: 2}, true
+			case "OR":
+				return expressionOperator{operator: cqr.OR, precedence: 5}, true
+			}
+			if proximity := scopusProximityRegexp.FindStringSubmatch(token); proximity != nil {
+				// W/n finds terms within n words in any order, and PRE/n finds the first term before the second.
+				if strings.ToUpper(proximity[1]) == "PRE" {
+					return expressionOperator{operator: fmt.Sprintf("adj%s", proximity[2]), precedence: 3, options: map[string]interface{}{ir.OrderedOption: true}}, true
+				}
+				return expressionOperator{operator: fmt.Sprintf("adj%s", proximity[2]), precedence: 4}, true
+			}
+			return expressionOperator{}, false
+		},
+		prefix: func(token string) ([]string, bool) {
+			if token == "default" || token != strings.ToUpper(token) {
+				return nil, false
+			}
+			f, ok := mapping[token]
+			return f, ok
+		},
+		keyword: func(term string) ir.Keyword {
+			return s.keyword(term, mapping)
+		},
+		// Terms which are not separated by an operator are combined with AND.
+		implicit: &expressionOperator{operator: cqr.AND, precedence: 2},
+	}
+}
+
+// NewScopusParser creates a new parser for Scopus queries.
+func NewScopusParser() QueryParser {
+	return QueryParser{FieldMapping: ScopusFieldMapping, Parser: ScopusTransformer{}}
+}
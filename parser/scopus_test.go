@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"github.com/hscells/transmute/ir"
+	"testing"
+)
+
+var (
+	scopusQueryString = `TITLE-ABS-KEY(dementia OR {heart attack} OR "memory loss") AND TITLE-ABS-KEY(memory W/3 loss) AND TITLE(mild PRE/2 impairment) AND NOT TITLE(review) AND PUBYEAR > 2005`
+)
+
+func TestScopus_BooleanQuery_Terms(t *testing.T) {
+	queryRep := NewScopusParser().Parse(lexerNode(scopusQueryString))
+
+	expected := 9
+	got := len(queryRep.Terms())
+	if expected != got {
+		t.Fatalf("Expected %v terms, got %v", expected, got)
+	}
+
+	if queryRep.Operator != "not" {
+		t.Fatalf("expected AND NOT to bind loosest, got %v", queryRep.Operator)
+	}
+}
+
+func TestScopus_Phrases(t *testing.T) {
+	queryRep := NewScopusParser().Parse(lexerNode(`TITLE-ABS-KEY({heart attack} OR "memory loss")`))
+
+	if len(queryRep.Keywords) != 2 {
+		t.Fatalf("expected two keywords, got %v", queryRep)
+	}
+	if queryRep.Keywords[0].QueryString != `"heart attack"` || queryRep.Keywords[0].Options[ir.PhraseOption] != ir.ExactPhrase {
+		t.Fatalf("expected an exact phrase, got %v", queryRep.Keywords[0])
+	}
+	if queryRep.Keywords[1].Options[ir.PhraseOption] != ir.LoosePhrase {
+		t.Fatalf("expected a loose phrase, got %v", queryRep.Keywords[1])
+	}
+}
+
+func TestScopus_Proximity(t *testing.T) {
+	queryRep := NewScopusParser().Parse(lexerNode(`TITLE(memory W/3 loss) AND TITLE(mild PRE/2 impairment)`))
+
+	if len(queryRep.Children) != 2 {
+		t.Fatalf("expected two proximity queries, got %v", queryRep)
+	}
+	if queryRep.Children[0].Operator != "adj3" || queryRep.Children[0].Options[ir.OrderedOption] != nil {
+		t.Fatalf("expected an unordered proximity query, got %v", queryRep.Children[0])
+	}
+	if queryRep.Children[1].Operator != "adj2" || queryRep.Children[1].Options[ir.OrderedOption] != true {
+		t.Fatalf("expected an ordered proximity query, got %v", queryRep.Children[1])
+	}
+}
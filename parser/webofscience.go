@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/hscells/cqr"
+	"github.com/hscells/transmute/fields"
+	"github.com/hscells/transmute/ir"
+	"github.com/hscells/transmute/lexer"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// WebOfScienceFieldMapping maps Web of Science Core Collection field tags. Web of Science does not index a controlled
+// vocabulary, so the topic (TS) tag is mapped to the title, abstract and keyword fields.
+var WebOfScienceFieldMapping = map[string][]string{
+	"TS":      {fields.TitleAbstract, fields.Keywords},
+	"TI":      {fields.Title},
+	"AB":      {fields.Abstract},
+	"AK":      {fields.Keywords},
+	"KP":      {fields.Keywords},
+	"AU":      {fields.Authors},
+	"AI":      {fields.AuthorIdentifier},
+	"GP":      {fields.AuthorCorporate},
+	"ED":      {fields.Editor},
+	"SO":      {fields.Journal},
+	"PY":      {fields.PublicationDate},
+	"DT":      {fields.PublicationType},
+	"LA":      {fields.Language},
+	"OG":      {fields.Affiliation},
+	"AD":      {fields.Affiliation},
+	"FG":      {fields.GrantNumber},
+	"IS":      {fields.ISBN},
+	"PMID":    {fields.PMID},
+	"ALL":     {fields.AllFields},
+	"default": {fields.TitleAbstract, fields.Keywords},
+}
+
+var (
+	webOfScienceTagRegexp, _  = regexp.Compile(`\b([A-Z]{2,4})\s*=\s*`)
+	webOfScienceNearRegexp, _ = regexp.Compile(`(?i)^NEAR(/[0-9]+)?$`)
+)
+
+// WebOfScienceTransformer is an implementation of a QueryTransformer for Web of Science advanced search queries.
+type WebOfScienceTransformer struct{}
+
+// keyword transforms a term into a keyword.
+func (w WebOfScienceTransformer) keyword(query string) ir.Keyword {
+	query = strings.TrimSpace(query)
+	return ir.Keyword{
+		QueryString: query,
+		Truncated:   isTruncated(query),
+	}
+}
+
+// TransformSingle implements the transformation of a single term, e.g. `TI=dementia`.
+func (w WebOfScienceTransformer) TransformSingle(query string, mapping map[string][]string) ir.Keyword {
+	q := w.TransformNested(query, mapping)
+	if len(q.Keywords) == 0 {
+		return ir.Keyword{}
+	}
+	return q.Keywords[0]
+}
+
+// TransformNested implements the transformation of a Web of Science query. Search sets may be combined by referring
+// to them by number (e.g. `#1 AND #2`), and are expanded into a single query.
+func (w WebOfScienceTransformer) TransformNested(query string, mapping map[string][]string) ir.BooleanQuery {
+	expanded, err := lexer.ExpandReferences(query, "#")
+	if err != nil {
+		log.Println(err)
+		return ir.BooleanQuery{}
+	}
+	// Normalise field tags so they are always written as `TS=`.
+	expanded = webOfScienceTagRegexp.ReplaceAllString(expanded, "$1= ")
+	return parseExpression(expanded, w.dialect(mapping), mapping)
+}
+
+// dialect describes the Web of Science search syntax. Operators are evaluated in the order NEAR/x, SAME, NOT, AND, OR.
+func (w WebOfScienceTransformer) dialect(mapping map[string][]string) expressionDialect {
+	return expressionDialect{
+		operator: func(token string) (expressionOperator, bool) {
+			switch strings.ToUpper(token) {
+			case "OR":
+				return expressionOperator{operator: cqr.OR, precedence: 1}, true
+			case "AND":
+				return expressionOperator{operator: cqr.AND, precedence: 2}, true
+			case "NOT":
+				return expressionOperator{operator: cqr.NOT, precedence: 3}, true
+			case "SAME":
+				return expressionOperator{operator: cqr.AND, precedence: 4, options: map[string]interface{}{ir.SameOption: true}}, true
+			}
+			if near := webOfScienceNearRegexp.FindStringSubmatch(token); near != nil {
+				// Web of Science uses a distance of 15 when none is specified.
+				distance := 15
+				if len(near[1]) > 0 {
+					distance, _ = strconv.Atoi(near[1][1:])
+				}
+				return expressionOperator{operator: fmt.Sprintf("adj%d", distance), precedence: 5}, true
+			}
+			return expressionOperator{}, false
+		},
+		split: func(token string) []string {
+			if i := strings.Index(token, "="); i > 0 && i < len(token)-1 {
+				if _, ok := mapping[token[:i]]; ok {
+					return []string{token[:i+1], token[i+1:]}
+				}
+			}
+			return []string{token}
+		},
+		prefix: func(token string) ([]string, bool) {
+			if !strings.HasSuffix(token, "=") {
+				return nil, false
+			}
+			f, ok := mapping[strings.TrimSuffix(token, "=")]
+			return f, ok
+		},
+		keyword: w.keyword,
+		// Terms which are not separated by an operator are combined with AND.
+		implicit: &expressionOperator{operator: cqr.AND, precedence: 2},
+	}
+}
+
+// NewWebOfScienceParser creates a new parser for Web of Science Core Collection queries.
+func NewWebOfScienceParser() QueryParser {
+	return QueryParser{FieldMapping: WebOfScienceFieldMapping, Parser: WebOfScienceTransformer{}}
+}
@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"github.com/hscells/transmute/ir"
+	"testing"
+)
+
+var (
+	webOfScienceQueryString = `#3 #1 AND #2
+#2 TS=(memory NEAR/3 loss) OR TI="mild cognitive impairment"
+#1 TS=(dementia OR alzheimer*)`
+)
+
+func TestWebOfScience_BooleanQuery_Terms(t *testing.T) {
+	queryRep := NewWebOfScienceParser().Parse(lexerNode(webOfScienceQueryString))
+
+	expected := 5
+	got := len(queryRep.Terms())
+	if expected != got {
+		t.Fatalf("Expected %v terms, got %v", expected, got)
+	}
+
+	if queryRep.Operator != "and" || len(queryRep.Children) != 2 {
+		t.Fatalf("expected the sets to be combined with and, got %v", queryRep)
+	}
+}
+
+func TestWebOfScience_BooleanQuery_FieldCount(t *testing.T) {
+	queryRep := NewWebOfScienceParser().Parse(lexerNode(webOfScienceQueryString))
+
+	counts := queryRep.FieldCount()
+	for field, expected := range map[string]int{
+		"title_abstract": 4,
+		"keywords":       4,
+		"title":          1,
+	} {
+		if got := counts[field]; got != expected {
+			t.Fatalf("Expected %v %v fields, got %v", expected, field, got)
+		}
+	}
+}
+
+func TestWebOfScience_Operators(t *testing.T) {
+	queryRep := NewWebOfScienceParser().Parse(lexerNode(`AD=(Brisbane SAME Queensland) AND TS=dementia NOT TI=review`))
+
+	if queryRep.Operator != "and" {
+		t.Fatalf("expected and, got %v", queryRep.Operator)
+	}
+	var same, not bool
+	for _, child := range queryRep.Children {
+		if child.Operator == "and" && child.Options[ir.SameOption] == true {
+			same = true
+		}
+		if child.Operator == "not" {
+			not = true
+		}
+	}
+	if !same || !not {
+		t.Fatalf("expected a SAME query and a NOT query, got %v", queryRep.Children)
+	}
+}
@@ -27,7 +27,7 @@ var (
 				FormatParenthesis: true,
 			},
 			AddRedundantParenthesis: true,
-			RequiresLexing:          false,
+			RequiresLexing:          true,
 		})
 	Cqr2Medline = pipeline.NewPipeline(
 		parser.NewCQRParser(),